@@ -6,16 +6,67 @@ import (
 )
 
 type Config struct {
-	Mode              string `envconfig:"MODE" default:"debug"`
-	ListenPort        int    `envconfig:"LISTEN" default:"88"`
-	HostAddress       string `envconfig:"HOST_ADDRESS" required:"true"`
-	Database          string `envconfig:"MYSQL_DSN" required:"true"`
-	HsmAddress        string `envconfig:"HSM_ADDRESS" required:"true"`
-	TimeoutTrx        int    `envconfig:"TIMEOUT_TRX" default:"60"`
-	TimeoutInactivity string `envconfig:"TIMEOUT_INACTIVITY" default:"60"`
-	Debug             int    `envconfig:"DEBUG_LOG" default:"0"`
-	EchoTestTime      int    `envconfig:"ECHO_TEST_TIME" default:"30"`
-	LicenseKey        string `envconfig:"LICENSE_KEY"`
+	Mode              string  `envconfig:"MODE" default:"debug"`
+	ListenPort        int     `envconfig:"LISTEN" default:"88"`
+	HostAddress       string  `envconfig:"HOST_ADDRESS" required:"true"` // primary,standby1,...
+	HostProbeInterval int     `envconfig:"HOST_PROBE_INTERVAL" default:"30"`
+	HostProbeSuccess  int     `envconfig:"HOST_PROBE_SUCCESS" default:"3"`
+	HostSessions      int     `envconfig:"HOST_SESSIONS" default:"1"`
+	HostBalance       string  `envconfig:"HOST_BALANCE" default:"round-robin"` // round-robin | least-outstanding
+	AcquirerID        string  `envconfig:"ACQUIRER_ID" default:"628"`
+	HostTLS           bool    `envconfig:"HOST_TLS" default:"false"`
+	HostTLSCAFile     string  `envconfig:"HOST_TLS_CA_FILE"`
+	HostTLSCertFile   string  `envconfig:"HOST_TLS_CERT_FILE"`
+	HostTLSKeyFile    string  `envconfig:"HOST_TLS_KEY_FILE"`
+	HostTLSServerName string  `envconfig:"HOST_TLS_SERVER_NAME"`
+	TLSCertFile       string  `envconfig:"TLS_CERT_FILE"` // TLS listener aktif jika diisi
+	TLSKeyFile        string  `envconfig:"TLS_KEY_FILE"`
+	TLSClientCAFile   string  `envconfig:"TLS_CLIENT_CA_FILE"` // mutual TLS untuk terminal
+	AdminListen       string  `envconfig:"ADMIN_LISTEN"`       // admin API aktif jika diisi, misal 127.0.0.1:8089
+	AdminToken        string  `envconfig:"ADMIN_TOKEN"`
+	Database          string  `envconfig:"MYSQL_DSN" required:"true"`
+	HsmType           string  `envconfig:"HSM_TYPE" default:"thales"` // thales | software
+	HsmAddress        string  `envconfig:"HSM_ADDRESS"`               // wajib untuk thales, beberapa alamat dipisah koma
+	HsmPoolSize       int     `envconfig:"HSM_POOL_SIZE" default:"4"`
+	HsmTimeout        int     `envconfig:"HSM_TIMEOUT" default:"5"`               // detik per command
+	MacTerminal       bool    `envconfig:"MAC_TERMINAL" default:"false"`          // verifikasi MAC request terminal dengan TAK
+	MacHost           bool    `envconfig:"MAC_HOST" default:"false"`              // MAC pesan ke host dengan ZAK
+	DukptKsnField     int     `envconfig:"DUKPT_KSN_FIELD" default:"62"`          // bit berisi KSN untuk terminal DUKPT
+	ZpkRotationCron   string  `envconfig:"ZPK_ROTATION_CRON"`                     // jadwal cron minta ZPK baru, kosong = mati
+	ZpkGraceWindow    int     `envconfig:"ZPK_GRACE_WINDOW" default:"300"`        // detik ZPK dari 0800/102 menunggu 0810 terkirim, dan ZPK lama tetap berlaku setelah ZPK baru aktif
+	PinFormatTerminal string  `envconfig:"PIN_FORMAT_TERMINAL" default:"ISO0"`    // format PIN block terminal jika grup tidak mengatur
+	PinFormatHost     string  `envconfig:"PIN_FORMAT_HOST" default:"ISO0"`        // format ke host, misal "ISO0,bank_x=ISO4"
+	EmvTagRules       string  `envconfig:"EMV_TAG_RULES"`                         // strip/tambah tag bit 55 per host, misal "bank_x:-9F7C,+9F33=E0F0C8"
+	FieldMapFile      string  `envconfig:"FIELD_MAP_FILE"`                        // file JSON aturan mapping field terminal/host, kosong = mati
+	IsoSpecTerminal   string  `envconfig:"ISO_SPEC_TERMINAL" default:"spec87hex"` // spec kanal terminal: nama spec bawaan atau path file JSON
+	IsoSpecHost       string  `envconfig:"ISO_SPEC_HOST" default:"spec87"`        // spec per host, misal "spec87,bank_x=/etc/danus/bank_x.json"
+	IsoVersionHost    string  `envconfig:"ISO_VERSION_HOST" default:"87"`         // versi ISO 8583 per host, misal "87,bank_x=93"
+	KcvRequired       bool    `envconfig:"KCV_REQUIRED" default:"false"`          // tolak key exchange host tanpa KCV
+	TerminalKcv       bool    `envconfig:"TERMINAL_KCV" default:"false"`          // kirim KCV setelah TPK/TAK di bit 48 respons logon
+	HsmLMK            string  `envconfig:"HSM_LMK"`                               // LMK hex untuk software HSM, hanya untuk pengujian
+	TimeoutTrx        int     `envconfig:"TIMEOUT_TRX" default:"60"`
+	TimeoutInactivity string  `envconfig:"TIMEOUT_INACTIVITY" default:"60"`
+	Debug             int     `envconfig:"DEBUG_LOG" default:"0"`
+	EchoTestTime      int     `envconfig:"ECHO_TEST_TIME" default:"30"`
+	LicenseKey        string  `envconfig:"LICENSE_KEY"`
+	StanStore         string  `envconfig:"STAN_STORE" default:"file"` // file | db
+	StanFile          string  `envconfig:"STAN_FILE" default:"stan.json"`
+	StanName          string  `envconfig:"STAN_NAME" default:"host"` // nama baris di tabel stan_sequence
+	StanWidth         int     `envconfig:"STAN_WIDTH" default:"12"`  // maksimal 12, STAN host selalu di-pad ke 12 digit
+	StanMin           int64   `envconfig:"STAN_MIN" default:"1"`
+	StanMax           int64   `envconfig:"STAN_MAX" default:"0"` // 0 = nilai terbesar sesuai STAN_WIDTH
+	StanBlock         int64   `envconfig:"STAN_BLOCK" default:"1"`
+	AutoReversal      bool    `envconfig:"AUTO_REVERSAL" default:"true"`               // 0420 otomatis untuk 0200 yang tidak dijawab host
+	SafSchedule       string  `envconfig:"SAF_SCHEDULE" default:"60,120,300,600,1800"` // jeda kirim ulang 0421 (detik)
+	SafMaxRetry       int     `envconfig:"SAF_MAX_RETRY" default:"10"`
+	SafPollInterval   int     `envconfig:"SAF_POLL_INTERVAL" default:"10"`
+	StanMapTTL        int     `envconfig:"STAN_MAP_TTL" default:"86400"` // detik mapping STAN disimpan di DB
+	OtelEndpoint      string  `envconfig:"OTEL_ENDPOINT"`                // collector OTLP/HTTP host:port, tracing mati jika kosong
+	OtelInsecure      bool    `envconfig:"OTEL_INSECURE" default:"true"`
+	OtelServiceName   string  `envconfig:"OTEL_SERVICE_NAME" default:"danus-h2h"`
+	OtelSampleRatio   float64 `envconfig:"OTEL_SAMPLE_RATIO" default:"1"`
+	FramingTerminal   string  `envconfig:"FRAMING_TERMINAL" default:"binary2+tpdu"` // header panjang, TPDU/NII dan trailer koneksi terminal
+	FramingHost       string  `envconfig:"FRAMING_HOST" default:"binary2"`          // framing per host, misal "binary2,bank_x=ascii4+nii:0003"
 }
 
 func NewParsedConfig() (Config, error) {
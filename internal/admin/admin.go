@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/handler"
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultTransactionLimit = 50
+	MaxTransactionLimit     = 500
+	readyTimeout            = 5 * time.Second
+)
+
+// Operations adalah operasi gateway yang dibuka lewat admin API, diimplementasikan
+// oleh *handler.Handler.
+type Operations interface {
+	Ready(ctx context.Context) error
+	HostStates() []handler.HostState
+	InflightCount() int
+	NetworkManagement(upstream, code string) ([]handler.NetworkResult, error)
+	Reconnect(upstream string) (int, error)
+	FindTransactions(ctx context.Context, filter repo.TransactionFilter) ([]repo.TransactionHistory, error)
+}
+
+// nmmActions memetakan nama action di URL ke kode network management (bit 70).
+var nmmActions = map[string]string{
+	"signon":  handler.NetMgmtTypeSignOn,
+	"signoff": handler.NetMgmtTypeSignOff,
+	"echo":    handler.NetMgmtTypeEcho,
+	"newkey":  handler.NetMgmtTypeNewKey,
+}
+
+type Server struct {
+	ops   Operations
+	token string
+	log   *logrus.Logger
+}
+
+func NewServer(ops Operations, token string, log *logrus.Logger) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("admin -> ADMIN_TOKEN is required")
+	}
+	return &Server{ops: ops, token: token, log: log}, nil
+}
+
+// Handler mengembalikan router admin API. /healthz dan /readyz terbuka untuk
+// probe container dan load balancer, endpoint lain wajib memakai header
+// "Authorization: Bearer <ADMIN_TOKEN>".
+func (s *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /hosts", s.hosts)
+	api.HandleFunc("GET /inflight", s.inflight)
+	api.HandleFunc("POST /hosts/nmm/{action}", s.networkManagement)
+	api.HandleFunc("POST /hosts/reconnect", s.reconnect)
+	api.HandleFunc("GET /transactions", s.transactions)
+	api.Handle("GET /metrics", metrics.Handler())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.health)
+	mux.HandleFunc("GET /readyz", s.ready)
+	mux.Handle("/", s.authenticate(api))
+
+	return mux
+}
+
+// Run menjalankan admin API di address sampai ctx dibatalkan.
+func (s *Server) Run(ctx context.Context, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	s.log.Infof("Admin API listen on %s", listener.Addr())
+	err = server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	// Detail error hanya di log karena endpoint ini tanpa token
+	if err := s.ops.Ready(ctx); err != nil {
+		s.log.Warnf("admin -> not ready: %v", err)
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+func (s *Server) hosts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.ops.HostStates())
+}
+
+func (s *Server) inflight(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]int{"inflight": s.ops.InflightCount()})
+}
+
+func (s *Server) networkManagement(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	code, ok := nmmActions[action]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown action " + action})
+		return
+	}
+
+	upstream := r.URL.Query().Get("upstream")
+	s.log.Infof("admin -> %s requested for upstream %q from %s", action, upstream, r.RemoteAddr)
+
+	results, err := s.ops.NetworkManagement(upstream, code)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) reconnect(w http.ResponseWriter, r *http.Request) {
+	upstream := r.URL.Query().Get("upstream")
+	s.log.Infof("admin -> reconnect requested for upstream %q from %s", upstream, r.RemoteAddr)
+
+	closed, err := s.ops.Reconnect(upstream)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"closed": closed})
+}
+
+func (s *Server) transactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := repo.TransactionFilter{
+		Tid:   query.Get("tid"),
+		Stan:  query.Get("stan"),
+		Rrn:   query.Get("rrn"),
+		Limit: DefaultTransactionLimit,
+	}
+	if filter.Tid == "" && filter.Stan == "" && filter.Rrn == "" {
+		writeError(w, http.StatusBadRequest, errors.New("at least one of tid, stan or rrn is required"))
+		return
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > MaxTransactionLimit {
+			writeError(w, http.StatusBadRequest, errors.New("invalid limit"))
+			return
+		}
+		filter.Limit = n
+	}
+
+	trx, err := s.ops.FindTransactions(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Pesan ISO mentah berisi PAN lengkap dan data track, tidak dikirim lewat API
+	for i := range trx {
+		trx[i].IsoReq = ""
+		trx[i].IsoRes = ""
+	}
+	writeJSON(w, http.StatusOK, trx)
+}
+
+func statusFor(err error) int {
+	if errors.Is(err, handler.ErrUnknownUpstream) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
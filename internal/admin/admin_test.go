@@ -0,0 +1,228 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alfianX/danus-h2h/internal/handler"
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret-token"
+
+type fakeOps struct {
+	readyErr   error
+	nmmCode    string
+	nmmName    string
+	reconnects []string
+	filter     repo.TransactionFilter
+}
+
+func (f *fakeOps) Ready(ctx context.Context) error { return f.readyErr }
+
+func (f *fakeOps) HostStates() []handler.HostState {
+	return []handler.HostState{{
+		Name:      "default",
+		Address:   "10.0.0.1:9000,10.0.0.2:9000",
+		Connected: true,
+		Sessions:  []handler.SessionState{{ID: 1, Connected: true, Endpoint: "10.0.0.2:9000", Standby: true}},
+	}}
+}
+
+func (f *fakeOps) InflightCount() int { return 7 }
+
+func (f *fakeOps) NetworkManagement(upstream, code string) ([]handler.NetworkResult, error) {
+	if upstream == "missing" {
+		return nil, fmt.Errorf("%w: %s", handler.ErrUnknownUpstream, upstream)
+	}
+	f.nmmName, f.nmmCode = upstream, code
+	return []handler.NetworkResult{{Session: "default#1", ResponseCode: "00"}}, nil
+}
+
+func (f *fakeOps) Reconnect(upstream string) (int, error) {
+	f.reconnects = append(f.reconnects, upstream)
+	return 2, nil
+}
+
+func (f *fakeOps) FindTransactions(ctx context.Context, filter repo.TransactionFilter) ([]repo.TransactionHistory, error) {
+	f.filter = filter
+	return []repo.TransactionHistory{{ID: 1, Tid: filter.Tid, Stan: "000045", IsoReq: "0200F23C"}}, nil
+}
+
+func newTestServer(t *testing.T, ops Operations) *httptest.Server {
+	server, err := NewServer(ops, testToken, logrus.New())
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func do(t *testing.T, method, url, token string) (*http.Response, map[string]any) {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	// Respons dari mux (misal 405) bukan JSON
+	var body any
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return res, nil
+	}
+	if obj, ok := body.(map[string]any); ok {
+		return res, obj
+	}
+	return res, map[string]any{"list": body}
+}
+
+func TestNewServerRequiresToken(t *testing.T) {
+	_, err := NewServer(&fakeOps{}, "", logrus.New())
+	assert.Error(t, err)
+}
+
+func TestAuthentication(t *testing.T) {
+	ts := newTestServer(t, &fakeOps{})
+
+	res, _ := do(t, http.MethodGet, ts.URL+"/hosts", "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = do(t, http.MethodGet, ts.URL+"/hosts", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = do(t, http.MethodPost, ts.URL+"/hosts/reconnect", "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = do(t, http.MethodGet, ts.URL+"/metrics", "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = do(t, http.MethodGet, ts.URL+"/hosts", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestProbesWithoutToken(t *testing.T) {
+	ts := newTestServer(t, &fakeOps{})
+
+	res, body := do(t, http.MethodGet, ts.URL+"/healthz", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "ok", body["status"])
+
+	res, body = do(t, http.MethodGet, ts.URL+"/readyz", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "ready", body["status"])
+
+	// Token salah tidak membuat probe gagal
+	res, _ = do(t, http.MethodGet, ts.URL+"/healthz", "wrong")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestReady(t *testing.T) {
+	ops := &fakeOps{}
+	ts := newTestServer(t, ops)
+
+	res, _ := do(t, http.MethodGet, ts.URL+"/readyz", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Alasan tidak siap hanya dicatat di log, tidak dikirim ke client tanpa token
+	ops.readyErr = errors.New("database: dial tcp 10.0.0.5:3306: connection refused")
+	res, body := do(t, http.MethodGet, ts.URL+"/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal(t, "not ready", body["status"])
+	assert.NotContains(t, body, "error")
+}
+
+func TestHostsAndInflight(t *testing.T) {
+	ts := newTestServer(t, &fakeOps{})
+
+	res, body := do(t, http.MethodGet, ts.URL+"/hosts", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	hosts := body["list"].([]any)
+	require.Len(t, hosts, 1)
+	assert.Equal(t, "default", hosts[0].(map[string]any)["name"])
+
+	res, body = do(t, http.MethodGet, ts.URL+"/inflight", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, float64(7), body["inflight"])
+}
+
+func TestNetworkManagement(t *testing.T) {
+	ops := &fakeOps{}
+	ts := newTestServer(t, ops)
+
+	res, _ := do(t, http.MethodPost, ts.URL+"/hosts/nmm/newkey?upstream=default", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, handler.NetMgmtTypeNewKey, ops.nmmCode)
+	assert.Equal(t, "default", ops.nmmName)
+
+	res, _ = do(t, http.MethodPost, ts.URL+"/hosts/nmm/reboot", testToken)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, _ = do(t, http.MethodPost, ts.URL+"/hosts/nmm/echo?upstream=missing", testToken)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, _ = do(t, http.MethodGet, ts.URL+"/hosts/nmm/echo", testToken)
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestReconnect(t *testing.T) {
+	ops := &fakeOps{}
+	ts := newTestServer(t, ops)
+
+	res, body := do(t, http.MethodPost, ts.URL+"/hosts/reconnect?upstream=bank-a", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, float64(2), body["closed"])
+	assert.Equal(t, []string{"bank-a"}, ops.reconnects)
+}
+
+func TestTransactions(t *testing.T) {
+	ops := &fakeOps{}
+	ts := newTestServer(t, ops)
+
+	res, _ := do(t, http.MethodGet, ts.URL+"/transactions", testToken)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, _ = do(t, http.MethodGet, ts.URL+"/transactions?tid=TID00001&limit=9999", testToken)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, body := do(t, http.MethodGet, ts.URL+"/transactions?tid=TID00001&stan=000045&rrn=123456789012", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, body["list"], 1)
+	assert.Equal(t, "", body["list"].([]any)[0].(map[string]any)["iso_req"])
+	assert.Equal(t, repo.TransactionFilter{Tid: "TID00001", Stan: "000045", Rrn: "123456789012", Limit: DefaultTransactionLimit}, ops.filter)
+}
+
+func TestMetrics(t *testing.T) {
+	ts := newTestServer(t, &fakeOps{})
+	metrics.SetInflightSource(func() int { return 3 })
+	metrics.Transactions.WithLabelValues("0210", "310000", "00").Inc()
+
+	res, _ := do(t, http.MethodGet, ts.URL+"/metrics", "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/metrics", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "danus_inflight_requests 3")
+	assert.Contains(t, string(body), `danus_transactions_total{mti="0210",procode="310000",rc="00"} 1`)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -13,10 +14,15 @@ import (
 	"time"
 
 	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/framer"
 	f "github.com/alfianX/danus-h2h/pkg/function"
+	"github.com/alfianX/danus-h2h/pkg/hsm"
 	"github.com/alfianX/danus-h2h/pkg/iso"
 	"github.com/alfianX/danus-h2h/pkg/license"
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+	"github.com/alfianX/danus-h2h/pkg/tracing"
 	"github.com/moov-io/iso8583"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type errorMessage struct {
@@ -26,6 +32,7 @@ type errorMessage struct {
 
 func (h *Handler) ClientHandler(conn net.Conn, sem chan struct{}, wg *sync.WaitGroup) {
 	defer func() {
+		h.endTrace(conn)
 		wg.Done() // Memberi tahu WaitGroup bahwa goroutine selesai
 		<-sem     // Melepaskan semaphore
 	}()
@@ -34,9 +41,12 @@ func (h *Handler) ClientHandler(conn net.Conn, sem chan struct{}, wg *sync.WaitG
 	conn.SetReadDeadline(time.Now().Add(time.Duration(timeoutTime) * time.Second))
 
 	for {
-		header := make([]byte, HeaderLen)
-		_, err := io.ReadFull(conn, header)
+		frame, err := h.terminalFraming().ReadFrame(conn)
 		if err != nil {
+			if errors.Is(err, framer.ErrInvalidFrame) {
+				h.handleErrorAndRespond(conn, "", RCErrFormatError, "client handler - invalid frame:", err)
+				return
+			}
 			if err == io.EOF {
 				return // Keluar dari loop dan defer akan menutup koneksi jika belum
 			}
@@ -59,48 +69,7 @@ func (h *Handler) ClientHandler(conn net.Conn, sem chan struct{}, wg *sync.WaitG
 			return
 		}
 
-		var messageBytes []byte
-		var message []byte
-
-		headerStr := hex.EncodeToString(header)
-		msgLength, err := strconv.ParseInt(headerStr, HexBase, IntBitSize)
-		if err != nil {
-			h.handleErrorAndRespond(conn, "", RCErrFormatError, "client handler - parse int msg length:", err)
-			return
-		}
-
-		if msgLength <= 0 || msgLength > MaxMessageLength {
-			h.handleErrorAndRespond(conn, "", RCErrFormatError, "client handler - invalid message length", nil)
-			return
-		}
-
-		messageBytes = make([]byte, msgLength)
-		_, err = io.ReadFull(conn, messageBytes)
-		if err != nil {
-			if err == io.EOF {
-				return // Keluar dari loop dan defer akan menutup koneksi jika belum
-			}
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				// Mungkin perlu menutup koneksi di sini jika timeout artinya koneksi tidak bisa dipakai lagi
-				conn.Close()
-				return
-			}
-			if opErr, ok := err.(*net.OpError); ok {
-				// Penanganan error khusus net.OpError, misalnya jika koneksi tertutup secara paksa
-				if strings.Contains(opErr.Err.Error(), "connection reset by peer") ||
-					strings.Contains(opErr.Err.Error(), "forcibly closed by the remote host") {
-					// Koneksi sudah tidak valid, cukup keluar
-					return
-				}
-			}
-
-			h.handleErrorAndRespond(conn, "", RCErrGeneral, "client handler - read from client:", err)
-			conn.Close()
-			return
-		}
-
-		message = append(header, messageBytes...)
-		isoRequestString := strings.ToUpper(hex.EncodeToString(message))
+		isoRequestString := strings.ToUpper(hex.EncodeToString(frame.Message))
 
 		conn.SetReadDeadline(time.Now().Add(time.Duration(timeoutTime) * time.Second))
 
@@ -110,20 +79,21 @@ func (h *Handler) ClientHandler(conn net.Conn, sem chan struct{}, wg *sync.WaitG
 			return
 		}
 
-		h.Log.WithField("debug_tag", "dl_in").Debugf("message request [%s]: %s", conn.RemoteAddr().String(), isoRequestString)
+		ctx := h.startTrace(conn, isoRequestString)
 
-		TP := isoRequestString[4:6]
+		h.connLog(conn).WithField("debug_tag", "dl_in").Debugf("message request [%s]: %X%s", conn.RemoteAddr().String(), frame.Header, isoRequestString)
 
-		if TP == "60" {
-			h.tpduConn.Store(conn, isoRequestString[4:14])
-			isoSend, idTrx, direction, err := h.clientPrepare(message[7:])
+		// TPDU harus diawali 0x60, framing NII atau tanpa header tidak dicek
+		if len(frame.Header) != framer.TPDULength || frame.Header[0] == TPDUExpected {
+			h.tpduConn.Store(conn, frame.Header)
+			isoSend, idTrx, u, direction, err := h.clientPrepare(ctx, frame.Message)
 			if err.Err != nil {
-				h.handleErrorAndRespond(conn, isoRequestString[14:], err.RC, "client handler - ", err.Err)
+				h.handleErrorAndRespond(conn, isoRequestString, err.RC, "client handler - ", err.Err)
 				return
 			}
 
 			if direction == 0 {
-				go h.sendSingleHostHandler(conn, isoSend, idTrx)
+				go h.sendSingleHostHandler(ctx, conn, u, isoSend, idTrx)
 			} else {
 				h.sendBackHandler(isoSend, conn)
 				return
@@ -141,29 +111,43 @@ func (h *Handler) ClientHandler(conn net.Conn, sem chan struct{}, wg *sync.WaitG
 	}
 }
 
-// balikan dari fungsi ini 1. message iso, 2. type 0=diteruskan ke host, 1=dibalikan ke client, 3. error
-func (h *Handler) clientPrepare(msg []byte) ([]byte, int64, int, errorMessage) {
+// balikan dari fungsi ini 1. message iso, 2. id transaksi, 3. host tujuan, 4. type 0=diteruskan ke host, 1=dibalikan ke client, 5. error
+func (h *Handler) clientPrepare(ctx context.Context, msg []byte) ([]byte, int64, *upstream, int, errorMessage) {
+	ctx, span := tracing.Start(ctx, "client_prepare")
+	defer span.End()
+
 	isoReqString := strings.ToUpper(hex.EncodeToString(msg))
 	isoSend, err := iso.IsoConvertToAscii([]byte(isoReqString))
 	if err != nil {
-		return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+		return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
 	}
 
 	isomessage := iso8583.NewMessage(iso.Spec87)
 
 	if err := isomessage.Unpack(isoSend); err != nil {
-		return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack iso: %s", err), RC: RCErrGeneral}
+		return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack iso: %s", err), RC: RCErrGeneral}
 	}
 	// iso8583.Describe(isomessage, os.Stdout)
 
 	mti, err := isomessage.GetMTI()
 	if err != nil {
-		return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> get mti: %s", err), RC: RCErrGeneral}
+		return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> get mti: %s", err), RC: RCErrGeneral}
 	}
 
 	stan, err := isomessage.GetString(11)
 	if err != nil {
-		return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack stan: %s", err), RC: RCErrGeneral}
+		return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack stan: %s", err), RC: RCErrGeneral}
+	}
+
+	// Logon belum punya TAK, jadi pesan network management tidak diverifikasi
+	if h.Config.MacTerminal && mti != "0800" {
+		tid, err := isomessage.GetString(41)
+		if err != nil {
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack tid: %s", err), RC: RCErrGeneral}
+		}
+		if err := h.verifyTerminalMAC(ctx, tid, msg); err != nil {
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> verify mac: %w", err), RC: hsmResponseCode(err)}
+		}
 	}
 
 	// var isoSend []byte
@@ -171,119 +155,124 @@ func (h *Handler) clientPrepare(msg []byte) ([]byte, int64, int, errorMessage) {
 	if stan != "" {
 		isoSend, stanHost, err = h.changeStanFromClient(isoSend)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> change stan: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> change stan: %s", err), RC: RCErrGeneral}
 		}
 	} else {
-		return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> stan empty %s", ""), RC: RCErrFormatError}
+		return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> stan empty %s", ""), RC: RCErrFormatError}
 	}
 
 	var idTrx int64
+	var u *upstream
 	if mti == "0800" {
 		bit70, err := isomessage.GetString(70)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack bit 70: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack bit 70: %s", err), RC: RCErrGeneral}
 		}
 
 		isoSend, err = h.networkManagementCore(isomessage, isoSend, stanHost)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
 		}
 
 		if bit70 == NetMgmtTypeLogon {
-			return isoSend, 0, 1, errorMessage{}
+			return isoSend, 0, nil, 1, errorMessage{}
 		}
 	} else if mti == "0200" {
 		t := time.Now().UTC()
 		jdn := f.JulianDayNumber(t)
 		stanHostInt, err := strconv.Atoi(stanHost)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
 		}
 		rrnHost := fmt.Sprintf("%06d%06d", jdn%1000000, stanHostInt)
 
 		err = isomessage.Field(37, rrnHost)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+		}
+
+		card, err := iso.ReadCard(isomessage)
+		if err == nil {
+			err = card.Validate(time.Now())
+		}
+		if err != nil {
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> card: %w", err), RC: cardResponseCode(err)}
+		}
+		if err := h.checkIccData(ctx, isomessage); err != nil {
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> icc data: %w", err), RC: RCErrFormatError}
 		}
 
-		idTrx, err = h.transactionCore(isomessage, isoReqString, stanHost, rrnHost)
+		idTrx, err = h.transactionCore(ctx, isomessage, isoReqString, stanHost, rrnHost)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
 		}
 
 		pinBlock, err := isomessage.GetString(52)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack pinblock: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack pinblock: %s", err), RC: RCErrGeneral}
 		}
 		if pinBlock != "" {
-			pan, err := isomessage.GetString(2)
-			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack pan: %s", err), RC: RCErrGeneral}
-			}
 			tid, err := isomessage.GetString(41)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack tid: %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack tid: %s", err), RC: RCErrGeneral}
 			}
 
-			zpk, err := repo.KeyGetZPK(context.Background(), h.db)
+			// Format PIN block tujuan tergantung host, jadi host ditentukan lebih dulu
+			u, err = h.resolveUpstream(isomessage)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> get zpk from db: %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
 			}
 
-			tpk, err := repo.TerminalKeyGetTPK(context.Background(), h.db, &repo.TerminalKey{Tid: tid})
+			hsmCtx, hsmSpan := tracing.Start(ctx, "hsm.translate_pin", trace.WithSpanKind(trace.SpanKindClient))
+			newPinBlock, err := h.translatePin(hsmCtx, isomessage, u, tid, pinBlock, card.PAN)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> get tpk from db: %s", err), RC: RCErrGeneral}
+				spanError(hsmSpan, err, "hsm translate pin")
 			}
-
-			panStart := len(pan) - 13
-			panEnd := len(pan) - 1
-			panParsed := pan[panStart:panEnd]
-
-			newPinBlock, err := f.HSMTranslatePin(h.Config.HsmAddress, tpk, zpk, pinBlock, panParsed)
+			hsmSpan.End()
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> hsm translate pin: %s", err), RC: "55"}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> translate pin: %w", err), RC: hsmResponseCode(err)}
 			}
 
 			err = isomessage.Field(11, stanHost)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> add stan to iso: %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> add stan to iso: %s", err), RC: RCErrGeneral}
 			}
 
 			err = isomessage.Field(52, newPinBlock)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> add pinblock to iso: %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> add pinblock to iso: %s", err), RC: RCErrGeneral}
 			}
 
 			isoSend, err = isomessage.Pack()
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> pack iso trx : %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> pack iso trx : %s", err), RC: RCErrGeneral}
 			}
 		}
 	} else if mti == "0400" {
 		procode, err := isomessage.GetString(3)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack procode: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack procode: %s", err), RC: RCErrGeneral}
 		}
 
 		amountStr, err := isomessage.GetString(4)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack amount: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack amount: %s", err), RC: RCErrGeneral}
 		}
 		var amount int64
 		if amountStr != "" {
 			amount, err = strconv.ParseInt(amountStr, 10, 64)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> convert amount: %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> convert amount: %s", err), RC: RCErrGeneral}
 			}
 		}
 
 		bit12, err := isomessage.GetString(12)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack bit 12: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack bit 12: %s", err), RC: RCErrGeneral}
 		}
 		bit13, err := isomessage.GetString(13)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack bit 13: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack bit 13: %s", err), RC: RCErrGeneral}
 		}
 		loc, _ := time.LoadLocation("Asia/Jakarta")
 		var trxDate *time.Time
@@ -291,23 +280,23 @@ func (h *Handler) clientPrepare(msg []byte) ([]byte, int64, int, errorMessage) {
 			trxDateStr := strconv.Itoa(time.Now().Year()) + "-" + bit13[:2] + "-" + bit13[2:4] + " " + bit12[:2] + ":" + bit12[2:4] + ":" + bit12[4:6]
 			trxDateOri, err := time.ParseInLocation("2006-01-02 15:04:05", trxDateStr, loc)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> conver datetime: %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> conver datetime: %s", err), RC: RCErrGeneral}
 			}
 			trxDate = &trxDateOri
 		}
 
 		tid, err := isomessage.GetString(41)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack tid: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack tid: %s", err), RC: RCErrGeneral}
 		}
 		mid, err := isomessage.GetString(42)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack mid: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack mid: %s", err), RC: RCErrGeneral}
 		}
 
 		rrn, err := isomessage.GetString(37)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack rrn: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> unpack rrn: %s", err), RC: RCErrGeneral}
 		}
 
 		rrnHostDB, err := repo.TransactionGetRRNHost(context.Background(), h.db, &repo.TransactionHistory{
@@ -320,32 +309,35 @@ func (h *Handler) clientPrepare(msg []byte) ([]byte, int64, int, errorMessage) {
 			TrxDate: trxDate,
 		})
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> get stan host from db: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> get stan host from db: %s", err), RC: RCErrGeneral}
 		}
 
 		if rrnHostDB == "" {
-			delete(h.stanManage, stanHost)
+			h.dropStanMapping(stanHost)
 
 			isoSend, err := iso.CreateIsoResReversal(msg)
 			if err != nil {
-				return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+				return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
 			}
 
-			return isoSend, 0, 1, errorMessage{}
+			return isoSend, 0, nil, 1, errorMessage{}
 		}
 
 		err = isomessage.Field(37, rrnHostDB)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> set rrn to iso: %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> set rrn to iso: %s", err), RC: RCErrGeneral}
 		}
 
-		idTrx, err = h.transactionCore(isomessage, isoReqString, stanHost, rrnHostDB)
+		idTrx, err = h.transactionCore(ctx, isomessage, isoReqString, stanHost, rrnHostDB)
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
 		}
 
-		_, ok := h.reversalAdvice[tid+stanHost]
-		if !ok {
+		repeat, err := h.reversalAdvicePending(tid, fmt.Sprintf("%06s", stan))
+		if err != nil {
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> get reversal advice queue: %s", err), RC: RCErrGeneral}
+		}
+		if !repeat {
 			isomessage.MTI("0420")
 		} else {
 			isomessage.MTI("0421")
@@ -353,54 +345,72 @@ func (h *Handler) clientPrepare(msg []byte) ([]byte, int64, int, errorMessage) {
 
 		isoSend, err = isomessage.Pack()
 		if err != nil {
-			return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> pack iso reversal : %s", err), RC: RCErrGeneral}
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> pack iso reversal : %s", err), RC: RCErrGeneral}
 		}
 	} else {
-		return nil, 0, 1, errorMessage{Err: fmt.Errorf("client prepare -> invalid mti: %s", err), RC: RCErrInvalidTrx}
+		return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> invalid mti: %s", err), RC: RCErrInvalidTrx}
+	}
+
+	if u == nil {
+		u, err = h.resolveUpstream(isomessage)
+		if err != nil {
+			return nil, 0, nil, 1, errorMessage{Err: fmt.Errorf("client prepare -> %s", err), RC: RCErrGeneral}
+		}
 	}
 
-	return isoSend, idTrx, 0, errorMessage{}
+	return isoSend, idTrx, u, 0, errorMessage{}
 }
 
 func (h *Handler) sendBackHandler(msg []byte, conn net.Conn) {
 	if conn != nil {
-		TPDU := "6000000000"
-		clientMsg := strings.ToUpper(hex.EncodeToString(msg))
-		i := len(clientMsg)
-		value, ok := h.tpduConn.Load(conn)
-		if ok {
-			TPDU = value.(string)
+		defer h.endTrace(conn)
+		_, span := tracing.Start(h.connContext(conn), "terminal.response")
+		defer span.End()
+
+		var request []byte
+		if value, ok := h.tpduConn.LoadAndDelete(conn); ok {
+			request = value.([]byte)
 		}
-		h.tpduConn.Delete(conn)
 
-		TPDU = TPDU[:2] + TPDU[6:10] + TPDU[2:6]
-		i = (i + 10) / 2
-		msgWlen := fmt.Sprintf("%04X%s%s", i, TPDU, clientMsg)
-		msgSend, err := hex.DecodeString(msgWlen)
+		terminalFramer := h.terminalFraming()
+		clientMsg := strings.ToUpper(hex.EncodeToString(msg))
+		msgSend, err := terminalFramer.Encode(framer.Frame{Header: terminalFramer.ReplyHeader(request), Message: msg})
 		if err != nil {
-			h.handleErrorAndRespond(conn, "", RCErrGeneral, "send back handler - decode iso:", err)
+			h.handleErrorAndRespond(conn, "", RCErrGeneral, "send back handler - encode frame:", err)
 			return
 		}
 		isoString := strings.ToUpper(hex.EncodeToString(msgSend))
 
 		_, err = conn.Write(msgSend)
 		if err != nil {
+			spanError(span, err, "write data client")
 			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
-				h.Log.Errorf("send back handler -> write data client timeout: %v", err)
+				h.connLog(conn).Errorf("send back handler -> write data client timeout: %v", err)
 				return
 			}
-			h.Log.Errorf("send back handler -> write data client: %v", err)
+			h.connLog(conn).Errorf("send back handler -> write data client: %v", err)
 			return
 		}
 
-		h.Log.WithField("debug_tag", "dl_out").Debugf("message response [%s]: %s", conn.RemoteAddr().String(), isoString)
+		h.connLog(conn).WithField("debug_tag", "dl_out").Debugf("message response [%s]: %s", conn.RemoteAddr().String(), isoString)
+		countTransaction(clientMsg)
 	}
 }
 
-func (h *Handler) changeStanFromClient(msg []byte) ([]byte, string, error) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// countTransaction mencatat respons ke terminal ke metrics per MTI, procode dan RC.
+func countTransaction(clientMsg string) {
+	isomessage := iso8583.NewMessage(iso.TerminalSpec())
+	if err := isomessage.Unpack([]byte(clientMsg)); err != nil {
+		return
+	}
+
+	mti, _ := isomessage.GetMTI()
+	procode, _ := isomessage.GetString(3)
+	rc, _ := isomessage.GetString(39)
+	metrics.Transactions.WithLabelValues(mti, procode, rc).Inc()
+}
 
+func (h *Handler) changeStanFromClient(msg []byte) ([]byte, string, error) {
 	isoStr := string(msg)
 	isomessage := iso8583.NewMessage(iso.Spec87)
 	err := isomessage.Unpack([]byte(isoStr))
@@ -408,26 +418,28 @@ func (h *Handler) changeStanFromClient(msg []byte) ([]byte, string, error) {
 		return nil, "", err
 	}
 
-	stanClient, err := isomessage.GetString(11)
+	mti, err := isomessage.GetMTI()
 	if err != nil {
 		return nil, "", err
 	}
 
-	stanClient = fmt.Sprintf("%06s", stanClient)
-	stanHostInt := h.stan
-	stanHost := fmt.Sprintf("%012d", stanHostInt)
+	stanClient, err := isomessage.GetString(11)
+	if err != nil {
+		return nil, "", err
+	}
 
-	err = isomessage.Field(11, stanHost)
+	tid, err := isomessage.GetString(41)
 	if err != nil {
 		return nil, "", err
 	}
 
-	err = h.editConfig(stanHostInt)
+	stanClient = fmt.Sprintf("%06s", stanClient)
+	stanHost, err := h.takeStan()
 	if err != nil {
 		return nil, "", err
 	}
 
-	err = h.loadConfig()
+	err = isomessage.Field(11, stanHost)
 	if err != nil {
 		return nil, "", err
 	}
@@ -437,13 +449,40 @@ func (h *Handler) changeStanFromClient(msg []byte) ([]byte, string, error) {
 		return nil, "", err
 	}
 
-	stanManage := StanManage{StanClient: stanClient, Duration: time.Now()}
-	h.stanManage[stanHost] = stanManage
+	stanManage := StanManage{StanClient: stanClient, Tid: tid, Duration: time.Now()}
+	if err := h.putStanMapping(stanHost, mti, stanManage); err != nil {
+		return nil, "", err
+	}
 
 	return newMsg, stanHost, nil
 }
 
-func (h *Handler) transactionCore(isomessage *iso8583.Message, msg, stanHost, rrnHost string) (int64, error) {
+// stanHostLength adalah panjang bit 11 di Spec87. STAN_WIDTH hanya membatasi
+// nilai generator, STAN host selalu di-pad ke panjang ini sehingga key mapping
+// dan pending sama dengan STAN yang dibaca dari respons host.
+const stanHostLength = 12
+
+// padStanHost menyamakan STAN dari pesan ke format key mapping dan pending.
+func padStanHost(stan string) string {
+	return fmt.Sprintf("%0*s", stanHostLength, stan)
+}
+
+// takeStan mengambil STAN host berikutnya, dipakai juga untuk pesan yang dibuat gateway sendiri.
+func (h *Handler) takeStan() (string, error) {
+	if h.stanGen == nil {
+		return "", errors.New("take stan -> stan generator is not configured")
+	}
+	stan, err := h.stanGen.Next(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return padStanHost(stan), nil
+}
+
+func (h *Handler) transactionCore(ctx context.Context, isomessage *iso8583.Message, msg, stanHost, rrnHost string) (int64, error) {
+	_, span := tracing.Start(ctx, "db.transaction_core")
+	defer span.End()
+
 	mti, err := isomessage.GetMTI()
 	if err != nil {
 		return 0, fmt.Errorf("transaction core -> get mti: %w", err)
@@ -461,12 +500,14 @@ func (h *Handler) transactionCore(isomessage *iso8583.Message, msg, stanHost, rr
 	if err != nil {
 		return 0, fmt.Errorf("transaction core -> unpack mid: %w", err)
 	}
-	pan, err := isomessage.GetString(2)
+	card, err := iso.ReadCard(isomessage)
 	if err != nil {
-		return 0, fmt.Errorf("transaction core -> unpack pan: %w", err)
+		return 0, fmt.Errorf("transaction core -> %w", err)
 	}
-	if pan != "" {
-		pan = f.MaskPan(pan)
+	pan := f.MaskPan(card.PAN)
+	icc, err := iccData(isomessage)
+	if err != nil {
+		return 0, fmt.Errorf("transaction core -> icc data: %w", err)
 	}
 	amountStr, err := isomessage.GetString(4)
 	if err != nil {
@@ -526,6 +567,11 @@ func (h *Handler) transactionCore(isomessage *iso8583.Message, msg, stanHost, rr
 		RrnHost:      rrnHost,
 		MerchantName: merhcantName,
 		IsoReq:       msg,
+		EmvAc:        icc.GetHex(iso.TagApplicationCryptogram),
+		EmvCid:       icc.GetHex(iso.TagCryptogramInfo),
+		EmvTvr:       icc.GetHex(iso.TagTVR),
+		EmvTrxDate:   icc.GetHex(iso.TagTransactionDate),
+		EmvCurrency:  icc.GetHex(iso.TagCurrencyCode),
 		CreatedAt:    time.Now(),
 	})
 	if err != nil {
@@ -559,16 +605,35 @@ func (h *Handler) networkManagementCore(isomessage *iso8583.Message, msg []byte,
 			return nil, fmt.Errorf("network management -> get tmk: %w", err)
 		}
 
-		twk, tpk, err := f.HSMGenerateKey(h.Config.HsmAddress, tmk)
+		twk, tpk, tpkKcv, err := h.hsm.GenerateTerminalKeys(context.Background(), tmk)
 		if err != nil {
 			return nil, fmt.Errorf("generate key hsm: %w", err)
 		}
+		if err := h.requireKCV("tpk", tpkKcv); err != nil {
+			return nil, err
+		}
 		// twk := "60C49773967F03579F9E28CA7AA30DDD"
 		// tpk := tmk
 
+		// Jika MAC terminal aktif, TAK dikirim setelah TWK di bit 48
+		var tak, takTmk, takKcv string
+		if h.Config.MacTerminal {
+			takTmk, tak, takKcv, err = h.hsm.GenerateTAK(context.Background(), tmk)
+			if err != nil {
+				return nil, fmt.Errorf("generate tak hsm: %w", err)
+			}
+			if err := h.requireKCV("tak", takKcv); err != nil {
+				return nil, err
+			}
+		}
+		bit48 := logonKeyBlock(twk, tpkKcv, takTmk, takKcv, h.Config.TerminalKcv)
+
 		err = repo.TerminalKeySave(context.Background(), h.db, &repo.TerminalKey{
 			Tid:       tid,
 			Tpk:       tpk,
+			TpkKcv:    tpkKcv,
+			Tak:       tak,
+			TakKcv:    takKcv,
 			CreatedAt: time.Now(),
 		})
 		if err != nil {
@@ -579,9 +644,9 @@ func (h *Handler) networkManagementCore(isomessage *iso8583.Message, msg []byte,
 		if err != nil {
 			return nil, fmt.Errorf("network management -> unpack stan: %w", err)
 		}
-		delete(h.stanManage, stanHost)
+		h.dropStanMapping(stanHost)
 
-		isoSend, err = iso.CreateIsoResLogon(msg, twk, stan)
+		isoSend, err = iso.CreateIsoResLogon(msg, bit48, stan)
 		if err != nil {
 			return nil, fmt.Errorf("network management -> create res iso logon: %w", err)
 		}
@@ -616,3 +681,38 @@ func (h *Handler) networkManagementCore(isomessage *iso8583.Message, msg []byte,
 
 	return isoSend, nil
 }
+
+// hsmResponseCode memetakan error HSM ke bit 39. Hanya masalah PIN dari terminal
+// yang dijawab 55, MAC yang salah 63, masalah kunci 81 dan gangguan HSM 96.
+// RC 75 (PIN tries exceeded) tidak pernah dari sini: gateway hanya translate PIN
+// dan HSM tidak menyimpan hitungan salah PIN, RC 75 datang dari host issuer di
+// bit 39 respons dan diteruskan apa adanya ke terminal.
+func hsmResponseCode(err error) string {
+	switch {
+	case errors.Is(err, hsm.ErrMacVerification), errors.Is(err, ErrMacMissing):
+		return RCErrSecurity
+	case errors.Is(err, ErrKsnMissing):
+		return RCErrFormatError
+	case errors.Is(err, hsm.ErrInvalidPinBlock):
+		return RCErrIncorrectPin
+	case errors.Is(err, hsm.ErrPinBlockFormat), isKeyError(err):
+		return RCErrCrypto
+	default:
+		return RCErrGeneral
+	}
+}
+
+// cardResponseCode memetakan error validasi kartu ke bit 39: PAN tidak valid 14,
+// kartu kedaluwarsa 54 dan track 2 yang tidak bisa dibaca 30.
+func cardResponseCode(err error) string {
+	switch {
+	case errors.Is(err, iso.ErrInvalidPAN):
+		return RCErrInvalidCard
+	case errors.Is(err, iso.ErrCardExpired):
+		return RCErrExpiredCard
+	case errors.Is(err, iso.ErrInvalidTrack2):
+		return RCErrFormatError
+	default:
+		return RCErrGeneral
+	}
+}
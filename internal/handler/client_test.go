@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/alfianX/danus-h2h/pkg/hsm"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHsmResponseCode(t *testing.T) {
+	cases := map[string]error{
+		RCErrIncorrectPin: hsm.ErrInvalidPinBlock,
+		RCErrCrypto:       &hsm.Error{Command: "CA", Code: "10", Kind: hsm.ErrKeyParity},
+		RCErrGeneral:      &hsm.Error{Command: "CA", Code: "io", Kind: hsm.ErrHsmUnavailable, Err: errors.New("refused")},
+	}
+	for rc, err := range cases {
+		assert.Equal(t, rc, hsmResponseCode(fmt.Errorf("client prepare -> %w", err)), err.Error())
+	}
+	assert.Equal(t, RCErrCrypto, hsmResponseCode(hsm.ErrPinBlockFormat))
+	assert.Equal(t, RCErrCrypto, hsmResponseCode(hsm.ErrKeyNotFound))
+	assert.Equal(t, RCErrGeneral, hsmResponseCode(hsm.ErrHsmFailure))
+	assert.Equal(t, RCErrSecurity, hsmResponseCode(&hsm.Error{Command: "M8", Code: "01", Kind: hsm.ErrMacVerification}))
+}
+
+func TestCardResponseCode(t *testing.T) {
+	assert.Equal(t, RCErrInvalidCard, cardResponseCode(fmt.Errorf("card: %w", iso.ErrInvalidPAN)))
+	assert.Equal(t, RCErrExpiredCard, cardResponseCode(fmt.Errorf("card: %w", iso.ErrCardExpired)))
+	assert.Equal(t, RCErrFormatError, cardResponseCode(iso.ErrInvalidTrack2))
+	assert.Equal(t, RCErrGeneral, cardResponseCode(errors.New("unpack pan")))
+}
+
+func TestHandleErrorLogsHsmCode(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	h := &Handler{Log: logger}
+	server, client := net.Pipe()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	err := fmt.Errorf("client prepare -> translate pin: %w", &hsm.Error{Command: "CA", Code: "10", Kind: hsm.ErrKeyParity})
+	h.handleErrorAndRespond(server, "", hsmResponseCode(err), "client handler - ", err)
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, "10", entry.Data["hsm_code"])
+
+	h.handleErrorAndRespond(server, "", RCErrGeneral, "client handler - ", errors.New("unpack iso"))
+	_, ok := hook.LastEntry().Data["hsm_code"]
+	assert.False(t, ok)
+}
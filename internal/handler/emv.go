@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/alfianX/danus-h2h/pkg/tracing"
+	"github.com/moov-io/iso8583"
+)
+
+// emvRule adalah perubahan ICC data sebelum diteruskan ke satu host: tag yang
+// dibuang dan tag yang ditambah atau diganti nilainya.
+type emvRule struct {
+	strip []string
+	add   iso.TLVList
+}
+
+// parseEmvRules membaca EMV_TAG_RULES, aturan per nama upstream dipisah ';',
+// misal "bank_x:-9F7C,-DF01,+9F33=E0F0C8;default:-9F6E".
+func parseEmvRules(value string) (map[string]emvRule, error) {
+	rules := make(map[string]emvRule)
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, ops, ok := strings.Cut(part, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid emv tag rule %q", part)
+		}
+
+		var rule emvRule
+		for _, op := range strings.Split(ops, ",") {
+			op = strings.ToUpper(strings.TrimSpace(op))
+			switch {
+			case op == "":
+			case strings.HasPrefix(op, "-") && isHexTag(op[1:]):
+				rule.strip = append(rule.strip, op[1:])
+			case strings.HasPrefix(op, "+"):
+				tag, value, _ := strings.Cut(op[1:], "=")
+				raw, err := hex.DecodeString(value)
+				if err != nil || !isHexTag(tag) {
+					return nil, fmt.Errorf("invalid emv tag rule %q", op)
+				}
+				rule.add = rule.add.Set(tag, raw)
+			default:
+				return nil, fmt.Errorf("invalid emv tag rule %q", op)
+			}
+		}
+		rules[strings.TrimSpace(name)] = rule
+	}
+	return rules, nil
+}
+
+func isHexTag(tag string) bool {
+	_, err := hex.DecodeString(tag)
+	return tag != "" && err == nil
+}
+
+// iccData membaca ICC data dari bit 55, nil jika transaksi bukan chip.
+func iccData(isomessage *iso8583.Message) (iso.TLVList, error) {
+	value, err := isomessage.GetString(55)
+	if err != nil {
+		return nil, fmt.Errorf("unpack bit 55: %w", err)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return iso.DecodeTLVHex(value)
+}
+
+// checkIccData memastikan ICC data transaksi chip bisa diurai dan membawa
+// cryptogram, lalu mencatat tag-nya. Value tag sensitif (PAN, track 2) tidak
+// ikut dicatat.
+func (h *Handler) checkIccData(ctx context.Context, isomessage *iso8583.Message) error {
+	icc, err := iccData(isomessage)
+	if err != nil {
+		return err
+	}
+	if icc == nil {
+		return nil
+	}
+	if err := icc.Require(iso.TagApplicationCryptogram, iso.TagCryptogramInfo); err != nil {
+		return err
+	}
+
+	h.Log.WithFields(tracing.LogFields(ctx)).Debugf("icc data tags %v, cid %s, tvr %s",
+		icc.Tags(), icc.GetHex(iso.TagCryptogramInfo), icc.GetHex(iso.TagTVR))
+	return nil
+}
+
+// applyEmvRules menerapkan aturan EMV_TAG_RULES host tujuan ke bit 55 pesan yang
+// akan dikirim. Pesan tanpa bit 55 atau host tanpa aturan tidak diubah.
+func (h *Handler) applyEmvRules(u *upstream, msg []byte) ([]byte, error) {
+	rule, ok := h.emvRules[u.Name]
+	if !ok {
+		return msg, nil
+	}
+
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	if err := isomessage.Unpack(msg); err != nil {
+		return nil, fmt.Errorf("emv rules -> unpack iso: %w", err)
+	}
+	icc, err := iccData(isomessage)
+	if err != nil {
+		return nil, fmt.Errorf("emv rules -> %w", err)
+	}
+	if icc == nil {
+		return msg, nil
+	}
+
+	icc = icc.Remove(rule.strip...)
+	for _, tlv := range rule.add {
+		icc = icc.Set(tlv.Tag, tlv.Value)
+	}
+	value, err := icc.EncodeHex()
+	if err != nil {
+		return nil, fmt.Errorf("emv rules -> %w", err)
+	}
+	if err := isomessage.Field(55, value); err != nil {
+		return nil, fmt.Errorf("emv rules -> set bit 55: %w", err)
+	}
+	return isomessage.Pack()
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEmvRules(t *testing.T) {
+	rules, err := parseEmvRules("bank_x:-9F7C, -df01,+9F33=E0F0C8; default:-9F6E")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9F7C", "DF01"}, rules["bank_x"].strip)
+	assert.Equal(t, "E0F0C8", rules["bank_x"].add.GetHex("9F33"))
+	assert.Equal(t, []string{"9F6E"}, rules[DefaultUpstreamName].strip)
+
+	for _, value := range []string{"bank_x", "bank_x:9F7C", "bank_x:+9F33=XYZ", ":-9F7C"} {
+		_, err = parseEmvRules(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestApplyEmvRules(t *testing.T) {
+	rules, err := parseEmvRules("bank_x:-9F7C,+9F33=E0F0C8")
+	require.NoError(t, err)
+	h := &Handler{emvRules: rules}
+
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	isomessage.MTI("0200")
+	require.NoError(t, isomessage.Field(11, "000001"))
+	require.NoError(t, isomessage.Field(55, "9F2701809F7C0401020304"))
+	msg, err := isomessage.Pack()
+	require.NoError(t, err)
+
+	// Host tanpa aturan menerima pesan apa adanya
+	out, err := h.applyEmvRules(&upstream{Name: DefaultUpstreamName}, msg)
+	require.NoError(t, err)
+	assert.Equal(t, msg, out)
+
+	out, err = h.applyEmvRules(&upstream{Name: "bank_x"}, msg)
+	require.NoError(t, err)
+	result := iso8583.NewMessage(iso.Spec87)
+	require.NoError(t, result.Unpack(out))
+	icc, err := iccData(result)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9F27", "9F33"}, icc.Tags())
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alfianX/danus-h2h/pkg/framer"
+)
+
+var (
+	defaultTerminalFramer = mustFramer(framer.Options{Length: framer.LengthBinary2, Header: framer.HeaderTPDU, MaxLength: MaxMessageLength})
+	defaultHostFramer     = mustFramer(framer.Options{Length: framer.LengthBinary2})
+)
+
+func mustFramer(opts framer.Options) framer.Framer {
+	f, err := framer.New(opts)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// hostFramingConfig adalah framing koneksi ke host, default dan per nama upstream.
+type hostFramingConfig struct {
+	host  framer.Framer
+	hosts map[string]framer.Framer
+}
+
+// parseTerminalFraming membaca FRAMING_TERMINAL, misal "binary2+tpdu". Tanpa
+// "max" panjang pesan terminal dibatasi MaxMessageLength.
+func parseTerminalFraming(value string) (framer.Framer, error) {
+	if strings.TrimSpace(value) == "" {
+		return defaultTerminalFramer, nil
+	}
+	opts, err := framer.ParseOptions(value)
+	if err != nil {
+		return nil, fmt.Errorf("framing terminal: %w", err)
+	}
+	if opts.MaxLength == 0 {
+		opts.MaxLength = MaxMessageLength
+	}
+	f, err := framer.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("framing terminal: %w", err)
+	}
+	return f, nil
+}
+
+// parseHostFraming membaca FRAMING_HOST, daftar dipisah koma dengan entri tanpa
+// nama sebagai default, misal "binary2,bank_x=ascii4+nii:0003".
+func parseHostFraming(value string) (hostFramingConfig, error) {
+	cfg := hostFramingConfig{
+		host:  defaultHostFramer,
+		hosts: make(map[string]framer.Framer),
+	}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, format, named := strings.Cut(part, "=")
+		if !named {
+			format = name
+		}
+		f, err := framer.Parse(format)
+		if err != nil {
+			return cfg, fmt.Errorf("framing host: %w", err)
+		}
+		if named {
+			cfg.hosts[strings.TrimSpace(name)] = f
+		} else {
+			cfg.host = f
+		}
+	}
+	return cfg, nil
+}
+
+func (c hostFramingConfig) framerFor(name string) framer.Framer {
+	if f, ok := c.hosts[name]; ok {
+		return f
+	}
+	if c.host == nil {
+		return defaultHostFramer
+	}
+	return c.host
+}
+
+// terminalFraming mengembalikan framing koneksi terminal.
+func (h *Handler) terminalFraming() framer.Framer {
+	if h.framing == nil {
+		return defaultTerminalFramer
+	}
+	return h.framing
+}
+
+// hostFraming mengembalikan framing koneksi ke host ini.
+func (u *upstream) hostFraming() framer.Framer {
+	if u.framer == nil {
+		return defaultHostFramer
+	}
+	return u.framer
+}
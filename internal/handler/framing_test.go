@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/alfianX/danus-h2h/pkg/framer"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFraming(t *testing.T) {
+	terminal, err := parseTerminalFraming("")
+	require.NoError(t, err)
+	assert.Same(t, defaultTerminalFramer, terminal)
+
+	// Tanpa max, panjang pesan terminal tetap dibatasi MaxMessageLength
+	terminal, err = parseTerminalFraming("binary4+nii")
+	require.NoError(t, err)
+	_, err = terminal.Encode(framer.Frame{Message: make([]byte, MaxMessageLength)})
+	assert.ErrorIs(t, err, framer.ErrInvalidFrame)
+
+	_, err = parseTerminalFraming("binary3")
+	assert.ErrorIs(t, err, framer.ErrInvalidOptions)
+
+	cfg, err := parseHostFraming("")
+	require.NoError(t, err)
+	assert.Same(t, defaultHostFramer, cfg.framerFor("bank_x"))
+
+	cfg, err = parseHostFraming("binary4, bank_x=ascii4+nii:0003+trailer:03")
+	require.NoError(t, err)
+	out, err := cfg.framerFor(DefaultUpstreamName).Encode(framer.Frame{Message: []byte("0800")})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\x00\x00\x00\x040800"), out)
+	out, err = cfg.framerFor("bank_x").Encode(framer.Frame{Message: []byte("0800")})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0007\x00\x030800\x03"), out)
+
+	_, err = parseHostFraming("bank_x=ascii4+crc")
+	assert.ErrorIs(t, err, framer.ErrInvalidOptions)
+}
+
+func TestSendBackHandlerTPDU(t *testing.T) {
+	h := &Handler{Log: logrus.New()}
+	server, client := net.Pipe()
+	defer client.Close()
+
+	// TPDU request 60 0001 0002 dibalas dengan alamat ditukar
+	h.tpduConn.Store(server, []byte{0x60, 0x00, 0x01, 0x00, 0x02})
+	go h.sendBackHandler([]byte{0x08, 0x10}, server)
+
+	response := make([]byte, 9)
+	_, err := io.ReadFull(client, response)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x07, 0x60, 0x00, 0x02, 0x00, 0x01, 0x08, 0x10}, response)
+
+	_, ok := h.tpduConn.Load(server)
+	assert.False(t, ok)
+}
@@ -1,20 +1,27 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/alfianX/danus-h2h/config"
 	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/internal/sequence"
+	"github.com/alfianX/danus-h2h/pkg/fieldmap"
+	"github.com/alfianX/danus-h2h/pkg/framer"
 	f "github.com/alfianX/danus-h2h/pkg/function"
+	"github.com/alfianX/danus-h2h/pkg/hsm"
 	"github.com/alfianX/danus-h2h/pkg/iso"
 	"github.com/alfianX/danus-h2h/pkg/license"
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+	"github.com/alfianX/danus-h2h/pkg/tlsconf"
+	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 
 	_ "github.com/joho/godotenv/autoload"
@@ -25,24 +32,29 @@ const (
 	MaxMessageLength   = 4096
 	HexBase            = 16
 	IntBitSize         = 64
-	TPDUExpected       = "60"
+	TPDUExpected       = 0x60
 	RCErrGeneral       = "96"
 	RCErrLicense       = "15"
 	RCErrInvalidTrx    = "12"
 	RCErrFormatError   = "30"
+	RCErrHostDown      = "91"
+	RCErrIncorrectPin  = "55"
+	RCErrCrypto        = "81"
+	RCErrSecurity      = "63"
+	RCErrInvalidCard   = "14"
+	RCErrExpiredCard   = "54"
 	NetMgmtTypeLogon   = "101"
 	NetMgmtTypeSignOn  = "001"
 	NetMgmtTypeSignOff = "002"
 	NetMgmtTypeNewKey  = "102"
 	NetMgmtTypeEcho    = "301"
+	StanStoreFile      = "file"
+	StanStoreDB        = "db"
 )
 
-type Stan struct {
-	Stan int64 `json:"stan"`
-}
-
 type StanManage struct {
 	StanClient string
+	Tid        string
 	Duration   time.Time
 }
 
@@ -51,24 +63,29 @@ type HostResponse struct {
 	Err  error
 }
 
-type ReversalAdvice struct {
-	Data []byte
-}
-
 type Handler struct {
-	Config         config.Config
-	sliceChan      chan []byte
-	volumesn       string
-	responseMap    sync.Map
-	tpduConn       sync.Map
-	mu             sync.Mutex
-	stan           int64
-	stanManage     map[string]StanManage
-	reversalAdvice map[string]ReversalAdvice
-	hostConnLock   sync.Mutex
-	hostConn       net.Conn
-	db             *gorm.DB
-	Log            *logrus.Logger
+	Config      config.Config
+	sliceChan   chan []byte
+	volumesn    string
+	responseMap sync.Map
+	tpduConn    sync.Map
+	traceConn   sync.Map
+	mu          sync.Mutex
+	stanGen     sequence.StanGenerator
+	stanManage  map[string]StanManage
+	safSchedule []time.Duration
+	pinFormats  pinFormatConfig
+	emvRules    map[string]emvRule
+	fieldMap    *fieldmap.Engine
+	hostSpecs   hostSpecConfig
+	framing     framer.Framer
+	hostFraming hostFramingConfig
+	hostsLock   sync.Mutex
+	hosts       map[string]*upstream
+	hostTLS     *tlsconf.Client
+	hsm         hsm.HSM
+	db          *gorm.DB
+	Log         *logrus.Logger
 	// lastPingSent     sync.Map
 	// lastPongReceived sync.Map
 }
@@ -90,112 +107,153 @@ func NewHandler(cnf config.Config, log *logrus.Logger) (*Handler, error) {
 		return nil, err
 	}
 
-	fileStan, err := os.Open("stan.json")
-	if err != nil {
-		return nil, err
+	if err := repo.Migrate(db); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
 	}
-	defer fileStan.Close()
 
-	byteValue, err := io.ReadAll(fileStan)
-	if err != nil {
-		return nil, err
+	var stanStore sequence.Reserver
+	switch cnf.StanStore {
+	case StanStoreDB:
+		stanStore = sequence.NewDBReserver(db, cnf.StanName)
+	case StanStoreFile:
+		stanStore = sequence.NewFileReserver(cnf.StanFile)
+	default:
+		return nil, fmt.Errorf("unknown stan store %q", cnf.StanStore)
 	}
 
-	var stan Stan
-	err = json.Unmarshal(byteValue, &stan)
+	if cnf.StanWidth > stanHostLength {
+		return nil, fmt.Errorf("stan width %d does not fit in bit 11 (%d digits)", cnf.StanWidth, stanHostLength)
+	}
+	stanGen, err := sequence.NewStanGenerator(stanStore, sequence.Options{
+		Width:     cnf.StanWidth,
+		Min:       cnf.StanMin,
+		Max:       cnf.StanMax,
+		BlockSize: cnf.StanBlock,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	h := Handler{
-		Config:         cnf,
-		sliceChan:      sc,
-		volumesn:       volumesn,
-		stan:           stan.Stan,
-		stanManage:     make(map[string]StanManage),
-		reversalAdvice: make(map[string]ReversalAdvice),
-		db:             db,
-		Log:            log,
-		// lastPingSent:     sync.Map{},
-		// lastPongReceived: sync.Map{},
+	if cnf.ZpkRotationCron != "" {
+		if _, err := cron.ParseStandard(cnf.ZpkRotationCron); err != nil {
+			return nil, fmt.Errorf("zpk rotation cron %q: %w", cnf.ZpkRotationCron, err)
+		}
 	}
 
-	// go h.checkConnectionStatus()
-
-	return &h, nil
-}
-
-func (h *Handler) loadConfig() error {
-	fileStan, err := os.Open("stan.json")
+	safSchedule, err := parseSafSchedule(cnf.SafSchedule)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer fileStan.Close()
 
-	byteValue, err := io.ReadAll(fileStan)
+	pinFormats, err := parsePinFormats(cnf.PinFormatTerminal, cnf.PinFormatHost)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var stan Stan
-	if err := json.Unmarshal(byteValue, &stan); err != nil {
-		return err
+	emvRules, err := parseEmvRules(cnf.EmvTagRules)
+	if err != nil {
+		return nil, err
 	}
 
-	h.stan = stan.Stan
+	var fieldMap *fieldmap.Engine
+	if cnf.FieldMapFile != "" {
+		fieldMap, err = fieldmap.Load(cnf.FieldMapFile)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// fmt.Println("Configuration reloaded!")
-	return nil
-}
+	if err := loadTerminalSpec(cnf.IsoSpecTerminal); err != nil {
+		return nil, err
+	}
 
-func (h *Handler) editConfig(newStan int64) error {
-	fileStan, err := os.OpenFile("stan.json", os.O_RDWR, 0644)
+	hostSpecs, err := parseHostSpecs(cnf.IsoSpecHost, cnf.IsoVersionHost)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer fileStan.Close()
 
-	byteValue, err := io.ReadAll(fileStan)
+	terminalFramer, err := parseTerminalFraming(cnf.FramingTerminal)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var stan Stan
-	err = json.Unmarshal(byteValue, &stan)
+	hostFraming, err := parseHostFraming(cnf.FramingHost)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if newStan == 999999999999 {
-		newStan = 1
-	} else {
-		newStan = newStan + 1
+	var hostTLS *tlsconf.Client
+	if cnf.HostTLS {
+		hostTLS, err = tlsconf.NewClient(tlsconf.ClientOptions{
+			CAFile:     cnf.HostTLSCAFile,
+			CertFile:   cnf.HostTLSCertFile,
+			KeyFile:    cnf.HostTLSKeyFile,
+			ServerName: cnf.HostTLSServerName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("host tls: %w", err)
+		}
 	}
 
-	stan.Stan = newStan
-
-	if err := fileStan.Truncate(0); err != nil {
-		return err
+	hsmClient, err := hsm.New(hsm.Options{
+		Type:     cnf.HsmType,
+		Address:  cnf.HsmAddress,
+		PoolSize: cnf.HsmPoolSize,
+		Timeout:  time.Duration(cnf.HsmTimeout) * time.Second,
+		LMK:      cnf.HsmLMK,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := fileStan.Seek(0, 0); err != nil {
-		return err
+	h := Handler{
+		Config:      cnf,
+		sliceChan:   sc,
+		volumesn:    volumesn,
+		stanGen:     stanGen,
+		stanManage:  make(map[string]StanManage),
+		safSchedule: safSchedule,
+		pinFormats:  pinFormats,
+		emvRules:    emvRules,
+		fieldMap:    fieldMap,
+		hostSpecs:   hostSpecs,
+		framing:     terminalFramer,
+		hostFraming: hostFraming,
+		hostTLS:     hostTLS,
+		hsm:         hsmClient,
+		db:          db,
+		Log:         log,
+		// lastPingSent:     sync.Map{},
+		// lastPongReceived: sync.Map{},
 	}
 
-	encoder := json.NewEncoder(fileStan)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(stan); err != nil {
-		return err
+	if err := h.loadStanMapping(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	metrics.SetInflightSource(h.InflightCount)
+
+	// go h.checkConnectionStatus()
+
+	return &h, nil
 }
 
 func (h *Handler) handleErrorAndRespond(conn net.Conn, clientMsg, rc string, logMsg string, err error) {
-	h.Log.Errorf("%s %v", logMsg, err)
+	span := trace.SpanFromContext(h.connContext(conn))
+	span.SetAttributes(attribute.String("iso.rc", rc))
+
+	// Error HSM dicatat beserta error code aslinya, misal "10" atau "io"
+	entry := h.connLog(conn)
+	if code := hsm.ErrorCode(err); code != "" {
+		entry = entry.WithField("hsm_code", code)
+		span.SetAttributes(attribute.String("hsm.code", code))
+	}
+	entry.Errorf("%s %v", logMsg, err)
+	spanError(span, err, logMsg)
+
 	msgResponse, buildErr := iso.BuildErrorResponse(clientMsg, rc, 1)
 	if buildErr != nil {
-		h.Log.Errorf("failed to build error response for RC %s: %v", rc, buildErr)
+		h.connLog(conn).Errorf("failed to build error response for RC %s: %v", rc, buildErr)
 		conn.Close() // Tutup koneksi jika bahkan error response tidak bisa dibuat
 		return
 	}
@@ -209,11 +267,24 @@ func (h *Handler) CleanUpTimeoutStan() {
 	for range ticker.C {
 		h.mu.Lock()
 		for stanHost, data := range h.stanManage {
-			if time.Since(data.Duration) > 2*time.Minute { // Contoh: hapus setelah 2 menit
+			if time.Since(data.Duration) > stanMemoryWindow {
 				h.Log.Warnf("Cleaning up timed-out STAN: %s", stanHost)
 				delete(h.stanManage, stanHost)
 			}
 		}
 		h.mu.Unlock()
+
+		if h.db == nil {
+			continue
+		}
+
+		// Mapping di DB disimpan lebih lama untuk respons terlambat dan reversal advice
+		ttl := time.Duration(h.Config.StanMapTTL) * time.Second
+		deleted, err := repo.StanMappingDeleteBefore(context.Background(), h.db, time.Now().Add(-ttl))
+		if err != nil {
+			h.Log.Errorf("clean up stan mapping: %v", err)
+		} else if deleted > 0 {
+			h.Log.Infof("cleaned up %d expired stan mapping", deleted)
+		}
 	}
 }
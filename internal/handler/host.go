@@ -3,68 +3,113 @@ package handler
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alfianX/danus-h2h/internal/repo"
-	f "github.com/alfianX/danus-h2h/pkg/function"
+	"github.com/alfianX/danus-h2h/pkg/fieldmap"
+	"github.com/alfianX/danus-h2h/pkg/framer"
+	"github.com/alfianX/danus-h2h/pkg/hsm"
 	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+	"github.com/alfianX/danus-h2h/pkg/tracing"
 	"github.com/moov-io/iso8583"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ConnectToHost mendaftarkan host default (HOST_ADDRESS) dan semua host di tabel
+// services. Setiap host punya session sendiri yang dikelola oleh connectSession.
 func (h *Handler) ConnectToHost() {
-	h.Log.Infof("Try to connect to host %s...", h.Config.HostAddress)
+	h.defaultUpstream()
+
+	if h.db == nil {
+		return
+	}
+
+	services, err := repo.ServiceGetAll(context.Background(), h.db)
+	if err != nil {
+		h.Log.Errorf("connect to host -> get services: %v", err)
+		return
+	}
+
+	for _, service := range services {
+		h.getUpstream(service.ServiceName, service.ServiceAddress)
+	}
+}
+
+// connectSession mencoba endpoint host sesuai urutan (primary lebih dulu) sampai
+// salah satu berhasil. Jika semua gagal, diulang dengan backoff eksponensial.
+func (h *Handler) connectSession(s *hostSession) {
+	u := s.upstream
+	h.Log.Infof("Try to connect to host %s (%s)...", s, u.Address)
 
 	// Jeda awal sebelum retry
-	time.Sleep(5 * time.Second)
+	time.Sleep(hostConnectDelay)
 
 	// Logika retry dengan backoff eksponensial
-	for backoff := 1 * time.Second; backoff <= 3600*time.Second; backoff *= 2 {
-		hostConn, err := net.Dial("tcp", h.Config.HostAddress)
-		if err == nil {
-			h.Log.Infof("Successfully connected to host %s", h.Config.HostAddress)
-			// h.lastPingSent = sync.Map{}
-			// h.lastPongReceived = sync.Map{}
-			h.hostConnLock.Lock()
-			h.hostConn = hostConn
-			h.hostConnLock.Unlock()
-			// go h.hostHealthCheck()
-			go h.hostHandler()
-			go h.sendNmm()
+	backoff := 1 * time.Second
+	for {
+		for i, endpoint := range u.Endpoints {
+			hostConn, err := h.dialHost(endpoint)
+			if err != nil {
+				h.Log.Errorf("Failed to connect to host %s endpoint %s: %v", s, endpoint, err)
+				continue
+			}
+
+			h.Log.Infof("Successfully connected to host %s endpoint %s", s, endpoint)
+			h.activateConn(s, i, hostConn)
 			return
 		}
 
-		h.Log.Errorf("Failed to connect to host %s: %v. Retrying in %v...", h.Config.HostAddress, err, backoff)
+		h.Log.Errorf("All endpoints of host %s failed. Retrying in %v...", s, backoff)
 		time.Sleep(backoff)
+		if backoff < MaxReconnectBackoff {
+			backoff *= 2
+		}
+	}
+}
+
+// activateConn menjadikan hostConn sebagai koneksi aktif session dan mengembalikan
+// koneksi sebelumnya (jika ada). Jika yang aktif bukan primary, prober dijalankan.
+func (h *Handler) activateConn(s *hostSession, index int, hostConn net.Conn) net.Conn {
+	old := s.swapConn(index, hostConn)
+	metrics.HostUp.WithLabelValues(s.upstream.Name, strconv.Itoa(s.ID)).Set(1)
+
+	go h.hostHandler(s, hostConn)
+	go h.sendNmm(s)
+	if index > 0 {
+		h.Log.Warnf("Host %s running on standby endpoint %s", s, s.upstream.Endpoints[index])
+		go h.probePrimary(s, hostConn)
 	}
 
-	// Jika sampai di sini, artinya semua upaya reconnect gagal
-	h.Log.Fatalf("Fatal: all reconnect attempts to host %s failed. Exiting.", h.Config.HostAddress)
+	return old
 }
 
-func (h *Handler) hostHandler() {
+func (h *Handler) hostHandler(s *hostSession, hostConn net.Conn) {
 	// Defer ini akan membersihkan koneksi dan memicu reconnect
 	// hanya saat hostHandler berhenti karena error.
 	defer func() {
-		h.Log.Warnf("Host handler for %s is stopping. Initiating reconnect...", h.Config.HostAddress)
-		h.hostConnLock.Lock()
-		if h.hostConn != nil {
-			h.hostConn.Close()
-			h.hostConn = nil // Set nil agar goroutine lain tahu koneksi sudah putus
+		current := s.dropConn(hostConn)
+		h.failPending(hostConn, ErrHostDisconnected)
+		if !current {
+			// Koneksi ini sudah digantikan (switchback ke primary), tidak perlu reconnect
+			return
 		}
-		h.hostConnLock.Unlock()
-		go h.ConnectToHost()
-		// go h.checkConnectionStatus()
+		metrics.HostUp.WithLabelValues(s.upstream.Name, strconv.Itoa(s.ID)).Set(0)
+		h.Log.Warnf("Host handler for %s (%s) is stopping. Initiating reconnect...", s, s.upstream.Address)
+		go h.connectSession(s)
 	}()
 
 	for {
-		header := make([]byte, HeaderLen)
-		_, err := io.ReadFull(h.hostConn, header)
+		// Frame yang formatnya salah membuat stream tidak sinkron, koneksi
+		// diputus dan dibuka ulang
+		frame, err := s.upstream.hostFraming().ReadFrame(hostConn)
 		if err != nil {
 			if err == io.EOF {
 				h.Log.Warnf("host handler -> Host connection closed gracefully.")
@@ -74,29 +119,16 @@ func (h *Handler) hostHandler() {
 			return
 		}
 
-		headerStr := hex.EncodeToString(header)
-		msgLength, err := strconv.ParseInt(headerStr, 16, 64)
-		if err != nil {
-			h.Log.Errorf("host handler -> failed to parse message length from host : %v", err)
-			continue
-		}
+		// h.Log.Printf("from host : %s", isoStr)
+		h.Log.WithField("debug_tag", "ul_in").Debugf("message from host %s : %s", s, strings.ToUpper(string(frame.Message)))
 
-		messageBytes := make([]byte, msgLength)
-		_, err = io.ReadFull(h.hostConn, messageBytes)
+		// Pesan host diubah ke Spec87, setelah ini semua proses memakai Spec87
+		hostMsg, err := s.upstream.fromWire(frame.Message)
 		if err != nil {
-			if err == io.EOF {
-				h.Log.Warnf("host handler -> Host connection closed gracefully during message body read.")
-			} else {
-				h.Log.Errorf("host handler -> Error reading message body from host: %v", err)
-			}
-			return
+			h.Log.Errorf("host handler -> failed to convert ISO message: %v", err)
+			continue
 		}
-
-		fullMessage := append(header, messageBytes...)
-		isoStr := strings.ToUpper(string(fullMessage[2:]))
-
-		// h.Log.Printf("from host : %s", isoStr)
-		h.Log.WithField("debug_tag", "ul_in").Debugf("message from host : %s", isoStr)
+		isoStr := strings.ToUpper(string(hostMsg))
 
 		isomessage := iso8583.NewMessage(iso.Spec87)
 		err = isomessage.Unpack([]byte(isoStr))
@@ -112,7 +144,7 @@ func (h *Handler) hostHandler() {
 		}
 
 		if mti == "0800" {
-			go h.networkManagementHandler(fullMessage[2:])
+			go h.networkManagementHandler(s, hostMsg)
 		} else {
 			// if mti == "0810" {
 			// 	bit70, err := isomessage.GetString(70)
@@ -133,187 +165,209 @@ func (h *Handler) hostHandler() {
 				h.Log.Errorf("host handler -> failed to get STAN: %v", err)
 				continue
 			}
-			stan = fmt.Sprintf("%012s", stan)
+			stan = padStanHost(stan)
 			value, ok := h.responseMap.Load(stan)
 			if !ok {
-				h.Log.Warnf("host handler -> Received unexpected host response for stan: %s. Ignoring.", stan)
+				h.saveOrphanResponse(s, stan, isomessage, hostMsg)
 				continue
 			}
-			responseChan, ok := value.(chan HostResponse)
+			pending, ok := value.(*pendingResponse)
 			if !ok {
 				h.Log.Errorf("host handler -> Invalid channel type for stan: %s.", stan)
 				continue
 			}
 
-			responseChan <- HostResponse{Data: fullMessage[2:], Err: nil}
+			pending.deliver(HostResponse{Data: hostMsg, Err: nil})
 		}
 
 	}
 }
 
-func (h *Handler) sendNmm() {
-	serverAddress := fmt.Sprintf("127.0.0.1:%d", h.Config.ListenPort)
-	conn, err := net.Dial("tcp", serverAddress)
+// sendNmm melakukan sign on lalu meminta working key (ZPK) baru ke host
+// setiap kali koneksi session ke host tersebut berhasil dibuat.
+func (h *Handler) sendNmm(s *hostSession) {
+	h.Log.Infof("send sign on to %s..", s)
+	isomessage, err := h.sendNetworkManagement(s, NetMgmtTypeSignOn)
 	if err != nil {
-		h.Log.Errorf("send nmm -> failed to connect to host %s: %v", serverAddress, err)
+		h.Log.Errorf("send nmm -> sign on %s: %v", s, err)
 		return
 	}
 
-	isoSignOn := "0024600019000008008220000100000000040000000000000010080817400000020362800001"
-	msgSignOn, err := hex.DecodeString(isoSignOn)
+	responseCode, err := isomessage.GetString(39)
 	if err != nil {
-		h.Log.Errorf("send nmm -> failed to decode msg sign on: %v", err)
+		h.Log.Errorf("send nmm -> failed to unpack bit 39 response sign on: %v", err)
 		return
 	}
 
-	h.Log.Infoln("send sign on..")
-	_, err = conn.Write(msgSignOn)
-	if err != nil {
-		h.Log.Errorf("send nmm -> failed to write sign on: %v", err)
+	if responseCode != "00" {
+		h.Log.Errorf("send nmm -> response sign on %s: %s", s, responseCode)
 		return
 	}
 
-	header := make([]byte, HeaderLen)
-	_, err = io.ReadFull(conn, header)
-	if err != nil {
-		h.Log.Errorf("send nmm -> failed read header response sign on: %v", err)
+	// Working key cukup diminta sekali per host, lewat session pertama
+	if s.ID != 1 {
 		return
 	}
 
-	headerStr := hex.EncodeToString(header)
-	msgLength, err := strconv.ParseInt(headerStr, HexBase, IntBitSize)
-	if err != nil {
-		h.Log.Errorf("send nmm -> failed to parse length sign on: %v", err)
-		return
+	if err := h.requestNewKey(s, repo.KeySourceConnect); err != nil {
+		h.Log.Errorf("send nmm -> %v", err)
 	}
+}
 
-	messageBytes := make([]byte, msgLength)
-	_, err = io.ReadFull(conn, messageBytes)
+// requestNewKey meminta ZPK baru ke host lalu menyimpannya (terenkripsi di bawah LMK) ke DB.
+// Balasan 0810 dengan RC 00 berarti host sudah memakai kunci baru, jadi ZPK
+// langsung dikonfirmasi.
+func (h *Handler) requestNewKey(s *hostSession, source string) error {
+	h.Log.Infof("send new key to %s..", s)
+	isomessage, err := h.sendNetworkManagement(s, NetMgmtTypeNewKey)
 	if err != nil {
-		h.Log.Errorf("send nmm -> failed to read response sign on: %v", err)
-		return
+		return fmt.Errorf("new key %s: %w", s, err)
 	}
 
-	conn.Close()
+	responseCode, err := isomessage.GetString(39)
+	if err != nil {
+		return fmt.Errorf("unpack bit 39 response new key: %w", err)
+	}
 
-	fullMessage := append(header, messageBytes...)
-	isoResString := strings.ToUpper(hex.EncodeToString(fullMessage))
+	if responseCode != "00" {
+		return fmt.Errorf("response new key %s: %s", s, responseCode)
+	}
 
-	isomessage := iso8583.NewMessage(iso.Spec87Hex)
-	if err := isomessage.Unpack([]byte(isoResString[14:])); err != nil {
-		h.Log.Errorf("send nmm -> failed to unpack response sign on: %v", err)
-		return
+	de48, err := isomessage.GetString(48)
+	if err != nil {
+		return fmt.Errorf("unpack bit 48: %w", err)
 	}
 
-	responseCode, err := isomessage.GetString(39)
+	zpk, err := h.importHostKeys(s.upstream, de48, source, time.Now())
 	if err != nil {
-		h.Log.Errorf("send nmm -> failed to unpack bit 39 response sign on: %v", err)
-		return
+		return err
 	}
+	return h.confirmHostZPK(s.upstream, zpk)
+}
 
-	if responseCode == "00" {
-		conn, err := net.Dial("tcp", serverAddress)
-		if err != nil {
-			h.Log.Errorf("send nmm -> failed to connect to host %s: %v", serverAddress, err)
-			return
-		}
+// confirmHostZPK mengaktifkan ZPK tahap setelah host mengonfirmasinya. ZPK
+// lama tetap berlaku selama ZPK_GRACE_WINDOW untuk transaksi yang sedang berjalan.
+func (h *Handler) confirmHostZPK(u *upstream, zpk string) error {
+	if err := repo.HostKeyConfirmZPK(context.Background(), h.db, u.Name, zpk, h.zpkGraceWindow()); err != nil {
+		return fmt.Errorf("confirm zpk host %s: %w", u.Name, err)
+	}
+	h.Log.Infof("zpk host %s confirmed", u.Name)
+	return nil
+}
 
-		isoNewKey := "0024600019000008008220000100000000040000000000000010080817400000020362800102"
-		msgIsoNewKey, err := hex.DecodeString(isoNewKey)
-		if err != nil {
-			h.Log.Errorf("send nmm -> failed to decode msg new key: %v", err)
-			return
-		}
+// zpkGraceWindow adalah lama ZPK tahap menunggu konfirmasi host dan lama ZPK
+// lama tetap berlaku setelah ZPK baru aktif.
+func (h *Handler) zpkGraceWindow() time.Duration {
+	return time.Duration(h.Config.ZpkGraceWindow) * time.Second
+}
 
-		h.Log.Infoln("send new key..")
-		_, err = conn.Write(msgIsoNewKey)
-		if err != nil {
-			h.Log.Errorf("send nmm -> failed to write new key: %v", err)
-			return
+// importHostKeys menyimpan kunci dari bit 48 key exchange host (lihat parseHostKeys)
+// ke baris host_key upstream tersebut di bawah LMK. Kunci dibuka dengan ZMK
+// upstream itu sendiri. KCV dari host dicocokkan dengan KCV hasil HSM, kunci
+// ditolak seluruhnya jika ada yang tidak cocok. ZPK disimpan sebagai ZPK tahap
+// yang aktif mulai activateAt atau saat dikonfirmasi host (lihat
+// repo.HostKey.ActiveZPK), dan dikembalikan untuk konfirmasi.
+func (h *Handler) importHostKeys(u *upstream, de48, source string, activateAt time.Time) (string, error) {
+	keys, err := parseHostKeys(de48, h.Config.MacHost, h.Config.KcvRequired)
+	if err != nil {
+		if errors.Is(err, ErrKeyCheckValue) {
+			h.alertKeyCheck("zpk", err)
 		}
+		return "", err
+	}
 
-		header := make([]byte, HeaderLen)
-		_, err = io.ReadFull(conn, header)
-		if err != nil {
-			h.Log.Errorf("send nmm -> failed read header response new key: %v", err)
-			return
-		}
+	hostKey, err := repo.HostKeyGet(context.Background(), h.db, u.Name)
+	if err != nil {
+		return "", fmt.Errorf("get zmk host %s: %w", u.Name, err)
+	}
+	zmk := hostKey.Zmk
+	if zmk == "" {
+		return "", fmt.Errorf("host %s: %w: zmk", u.Name, hsm.ErrKeyNotFound)
+	}
 
-		headerStr := hex.EncodeToString(header)
-		msgLength, err := strconv.ParseInt(headerStr, HexBase, IntBitSize)
-		if err != nil {
-			h.Log.Errorf("send nmm -> failed to parse length new key: %v", err)
-			return
-		}
+	zpkEnc, zpkKcv, err := h.hsm.ImportZPK(context.Background(), zmk, keys.Zpk)
+	if err != nil {
+		return "", fmt.Errorf("save zpk to hsm: %w", err)
+	}
+	if err := checkKCV("zpk", keys.ZpkKcv, zpkKcv); err != nil {
+		h.alertKeyCheck("zpk", err)
+		return "", err
+	}
 
-		messageBytes := make([]byte, msgLength)
-		_, err = io.ReadFull(conn, messageBytes)
+	var zakEnc, zakKcv string
+	if h.Config.MacHost {
+		zakEnc, zakKcv, err = h.hsm.ImportZAK(context.Background(), zmk, keys.Zak)
 		if err != nil {
-			h.Log.Errorf("send nmm -> failed to read response new key: %v", err)
-			return
+			return "", fmt.Errorf("save zak to hsm: %w", err)
 		}
-
-		conn.Close()
-
-		fullMessage := append(header, messageBytes...)
-		isoResString := strings.ToUpper(hex.EncodeToString(fullMessage))
-
-		isomessage := iso8583.NewMessage(iso.Spec87Hex)
-		if err := isomessage.Unpack([]byte(isoResString[14:])); err != nil {
-			h.Log.Errorf("send nmm -> failed to unpack response new key: %v", err)
-			return
+		if err := checkKCV("zak", keys.ZakKcv, zakKcv); err != nil {
+			h.alertKeyCheck("zak", err)
+			return "", err
 		}
+	}
+
+	err = repo.HostKeyStageZPK(context.Background(), h.db, u.Name, &repo.KeyHistory{
+		KeyType:     repo.KeyTypeZPK,
+		Key:         zpkEnc,
+		Kcv:         zpkKcv,
+		Source:      source,
+		ActivatedAt: activateAt,
+	}, h.zpkGraceWindow())
+	if err != nil {
+		return "", fmt.Errorf("update zpk to db: %w", err)
+	}
+	h.Log.Infof("zpk host %s received (%s), kcv %s, active at %s", u.Name, source, zpkKcv, activateAt.Format(time.RFC3339))
 
-		responseCode, err := isomessage.GetString(39)
+	if h.Config.MacHost {
+		err = repo.HostKeyUpdateZAK(context.Background(), h.db, u.Name, zakEnc, zakKcv)
 		if err != nil {
-			h.Log.Errorf("send nmm -> failed to unpack bit 39 response sign on: %v", err)
-			return
+			return "", fmt.Errorf("update zak to db: %w", err)
 		}
+	}
 
-		if responseCode == "00" {
-			de48, err := isomessage.GetString(48)
-			if err != nil {
-				h.Log.Errorf("send nmm -> unpack bist 48: %v", err)
-				return
-			}
-			zpk := de48[:32]
-
-			zmk, err := repo.KeyGetZMK(context.Background(), h.db)
-			if err != nil {
-				h.Log.Errorf("send nmm -> get zmk: %v", err)
-				return
-			}
-
-			zpkEnc, err := f.HSMSaveZPK(h.Config.HsmAddress, zmk, zpk)
-			if err != nil {
-				h.Log.Errorf("send nmm -> save zpk to hsm: %v", err)
-				return
-			}
+	return zpkEnc, nil
+}
 
-			err = repo.KeyUpdateZPK(context.Background(), h.db, zpkEnc)
-			if err != nil {
-				h.Log.Errorf("send nmm -> update zpk to db: %v", err)
-				return
-			}
+// sendNetworkManagement membuat pesan 0800 dengan kode bit 70 tertentu,
+// mengirimnya ke host dan mengembalikan pesan 0810 dari host.
+func (h *Handler) sendNetworkManagement(s *hostSession, code string) (*iso8583.Message, error) {
+	stanHost, err := h.takeStan()
+	if err != nil {
+		return nil, fmt.Errorf("send network management -> next stan: %w", err)
+	}
 
-			return
-		} else {
-			h.Log.Errorf("send nmm -> response new key: %s", responseCode)
-			return
-		}
-	} else {
-		h.Log.Errorf("send nmm -> response sign on: %s", responseCode)
-		return
+	var isoSend []byte
+	switch code {
+	case NetMgmtTypeSignOn:
+		isoSend, err = iso.CreateIsoSignOn(stanHost, h.Config.AcquirerID)
+	case NetMgmtTypeSignOff:
+		isoSend, err = iso.CreateIsoSignOff(stanHost, h.Config.AcquirerID)
+	case NetMgmtTypeNewKey:
+		isoSend, err = iso.CreateIsoNewKey(stanHost, h.Config.AcquirerID)
+	case NetMgmtTypeEcho:
+		isoSend, err = iso.CreateIsoEchoTest(stanHost, h.Config.AcquirerID)
+	default:
+		return nil, fmt.Errorf("send network management -> invalid code %s", code)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("send network management -> create iso %s: %w", code, err)
+	}
+
+	return h.hostRequest(s, isoSend, time.Duration(h.Config.TimeoutTrx)*time.Second)
 }
 
-func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64) {
-	h.hostConnLock.Lock()
-	hostConn := h.hostConn
-	h.hostConnLock.Unlock()
+func (h *Handler) sendSingleHostHandler(ctx context.Context, conn net.Conn, u *upstream, msg []byte, idTrx int64) {
+	_, span := tracing.Start(ctx, "host.request", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("upstream", u.Name)))
+	defer span.End()
 
+	var hostConn net.Conn
+	s := u.pickSession(h.Config.HostBalance)
+	if s != nil {
+		hostConn = s.getConn()
+	}
 	if hostConn == nil {
-		h.handleErrorAndRespond(conn, "", "91", "send single host handler -> host is not connected", fmt.Errorf("host not connected"))
+		h.handleErrorAndRespond(conn, "", RCErrHostDown, "send single host handler -> host is not connected", fmt.Errorf("host not connected"))
 		return
 	}
 
@@ -333,43 +387,44 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 		h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - unpack bit 11:", err)
 		return
 	}
-	stan = fmt.Sprintf("%012s", stan)
-
-	// 1. Buat channel respons unik untuk transaksi ini
-	responseChan := make(chan HostResponse, 1)
-
-	// 2. Simpan channel ini ke map
-	h.responseMap.Store(stan, responseChan)
-	defer h.responseMap.Delete(stan) // Penting: Pastikan channel dihapus dari map
-
-	isoString := strings.ToUpper(string(msg))
-	hostMsg := hex.EncodeToString([]byte(isoString))
-	i := len(msg)
-	msgWlen := fmt.Sprintf("%04X%s", i, hostMsg)
-	msgSend, err := hex.DecodeString(msgWlen)
-	if err != nil {
-		h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - fail decode iso:", err)
-		return
-	}
-
-	h.Log.WithField("debug_tag", "ul_out").Debugf("message to host : %s", isoString)
+	stan = padStanHost(stan)
+	span.SetAttributes(attribute.String("iso.mti", mti), attribute.String("iso.stan_host", stan))
 
-	iso8583.Describe(isomessage, os.Stdout)
+	// Buat channel respons unik untuk transaksi ini dan simpan ke map
+	responseChan := h.registerPending(stan, s, hostConn)
+	defer h.releasePending(stan) // Penting: Pastikan channel dihapus dari map
 
-	_, err = hostConn.Write(msgSend)
+	start := time.Now()
+	err = h.writeToHost(s, hostConn, msg)
 	if err != nil {
+		spanError(span, err, "write to host")
 		h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - fail write to host:", err)
 		return
 	}
 
-	timeout60 := time.After(60 * time.Second)
-	timeout120 := time.After(120 * time.Second)
+	// Reversal advice yang tidak dijawab dalam TIMEOUT_TRX masuk antrian SAF,
+	// transaksi lain dijawab timeout ke terminal setelah dua kali TIMEOUT_TRX
+	timeoutTrx := time.Duration(h.Config.TimeoutTrx) * time.Second
+	if timeoutTrx <= 0 {
+		timeoutTrx = 60 * time.Second
+	}
+	timeoutAdvice := time.After(timeoutTrx)
+	timeoutFinal := time.After(2 * timeoutTrx)
 
 	for {
 		select {
 		case response := <-responseChan:
+			metrics.HostLatency.WithLabelValues(u.Name, mti).Observe(time.Since(start).Seconds())
 			if response.Err != nil {
-				h.handleErrorAndRespond(conn, "", RCErrGeneral, "response from host had an error", response.Err)
+				spanError(span, response.Err, "response from host")
+				rc := RCErrGeneral
+				if errors.Is(response.Err, ErrHostDisconnected) {
+					rc = RCErrHostDown
+					if mti == "0200" {
+						go h.autoReverse(u, idTrx, "host link dropped")
+					}
+				}
+				h.handleErrorAndRespond(conn, "", rc, "response from host had an error", response.Err)
 				return
 			} else {
 				isoResponse := response.Data
@@ -384,16 +439,12 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 					return
 				}
 
-				iso8583.Describe(isomessageRes, os.Stdout)
-
-				tx := h.db.Begin()
-				defer tx.Commit()
-
 				bit39, err := isomessageRes.GetString(39)
 				if err != nil {
 					h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - unpack bit 39:", err)
 					return
 				}
+				span.SetAttributes(attribute.String("iso.rc", bit39))
 
 				isoResponse, err = h.changeStanFromHost(isoResponse)
 				if err != nil {
@@ -401,6 +452,12 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 					return
 				}
 
+				isoResponse, err = h.mapFields(fieldmap.DirectionResponse, u, isoResponse)
+				if err != nil {
+					h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - ", err)
+					return
+				}
+
 				isoResponse, err = iso.IsoConvertToHex([]byte(isoResponse))
 				if err != nil {
 					h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - ", err)
@@ -408,6 +465,12 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 				}
 				isoResponseString = hex.EncodeToString(isoResponse)
 
+				tx := h.db.Begin()
+				if tx.Error != nil {
+					h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - begin tx:", tx.Error)
+					return
+				}
+
 				if idTrx != 0 {
 					err = repo.TransactionHistoryUpdateResponse(context.Background(), tx, &repo.TransactionHistory{
 						ID:           idTrx,
@@ -416,13 +479,14 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 						UpdatedAt:    time.Now(),
 					})
 					if err != nil {
+						tx.Rollback()
 						h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - update response trx:", err)
 						return
 					}
 				}
 
 				if mti == "0421" && bit39 == "00" {
-					isomessageRes := iso8583.NewMessage(iso.Spec87Hex)
+					isomessageRes := iso8583.NewMessage(iso.TerminalSpec())
 					err = isomessageRes.Unpack([]byte(isoResponseString))
 					if err != nil {
 						tx.Rollback()
@@ -443,19 +507,27 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 					}
 					stanClient = fmt.Sprintf("%06s", stanClient)
 
-					delete(h.reversalAdvice, tid+stanClient)
+					err = repo.SafMarkApproved(context.Background(), tx, tid, stanClient)
+					if err != nil {
+						tx.Rollback()
+						h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - mark reversal advice approved:", err)
+						return
+					}
 				}
 
-				tx.Commit()
+				if err := tx.Commit().Error; err != nil {
+					h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - commit tx:", err)
+					return
+				}
 
 				go h.sendBackHandler(isoResponse, conn)
 
 				return
 			}
-		case <-timeout60:
+		case <-timeoutAdvice:
 			switch mti {
 			case "0420":
-				stanData, ok := h.stanManage[stan]
+				stanData, ok := h.getStanMapping(stan)
 				if !ok {
 					h.handleErrorAndRespond(conn, "", RCErrGeneral, "send single host handler - stan "+stan+" not found in map", err)
 					return
@@ -467,8 +539,10 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 					return
 				}
 
-				reversalAvc := ReversalAdvice{Data: msg}
-				h.reversalAdvice[tid+stanClient] = reversalAvc
+				err = h.enqueueReversalAdvice(u, idTrx, 0, tid, stanClient, msg)
+				if err != nil {
+					h.Log.Errorf("send single host handler - %v", err)
+				}
 				return
 			case "0421":
 				return
@@ -476,18 +550,19 @@ func (h *Handler) sendSingleHostHandler(conn net.Conn, msg []byte, idTrx int64)
 
 			}
 
-			timeout60 = nil
-		case <-timeout120:
+			timeoutAdvice = nil
+		case <-timeoutFinal:
+			spanError(span, nil, "timeout waiting for host response")
 			h.handleErrorAndRespond(conn, "", "T0", "send single host handler - timeout", fmt.Errorf("timeout waiting for host response"))
+			if mti == "0200" {
+				go h.autoReverse(u, idTrx, "timeout waiting for host response")
+			}
 			return
 		}
 	}
 }
 
 func (h *Handler) changeStanFromHost(msg []byte) ([]byte, error) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	isoStr := string(msg)
 	isomessage := iso8583.NewMessage(iso.Spec87)
 	err := isomessage.Unpack([]byte(isoStr))
@@ -499,15 +574,14 @@ func (h *Handler) changeStanFromHost(msg []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	stanHost = fmt.Sprintf("%012s", stanHost)
+	stanHost = padStanHost(stanHost)
 
-	stanData, ok := h.stanManage[stanHost]
+	stanData, ok := h.popStanMapping(stanHost)
 	if !ok {
 		return nil, fmt.Errorf("stan %s not found in map", stanHost)
 	}
 
 	stanClient := stanData.StanClient
-	delete(h.stanManage, stanHost)
 
 	err = isomessage.Field(11, stanClient)
 	if err != nil {
@@ -522,7 +596,7 @@ func (h *Handler) changeStanFromHost(msg []byte) ([]byte, error) {
 	return newMsg, nil
 }
 
-func (h *Handler) networkManagementHandler(msg []byte) {
+func (h *Handler) networkManagementHandler(s *hostSession, msg []byte) {
 	isoStr := string(msg)
 	isomessage := iso8583.NewMessage(iso.Spec87)
 	err := isomessage.Unpack([]byte(isoStr))
@@ -538,6 +612,7 @@ func (h *Handler) networkManagementHandler(msg []byte) {
 	}
 
 	var isoResponse []byte
+	var stagedZpk string
 	if nmiCode == "301" || nmiCode == "001" || nmiCode == "002" {
 		isoResponse, err = iso.CreateIsoResNman(msg)
 		if err != nil {
@@ -545,28 +620,17 @@ func (h *Handler) networkManagementHandler(msg []byte) {
 			return
 		}
 	} else if nmiCode == "102" {
-		zmk, err := repo.KeyGetZMK(context.Background(), h.db)
-		if err != nil {
-			h.Log.Errorf("network management handler -> get zmk: %v", err)
-			return
-		}
-
 		de48, err := isomessage.GetString(48)
 		if err != nil {
 			h.Log.Errorf("network management handler -> unpack bist 48: %v", err)
 			return
 		}
-		zpk := de48[:32]
 
-		zpkEnc, err := f.HSMSaveZPK(h.Config.HsmAddress, zmk, zpk)
+		// Host baru memakai kunci ini setelah menerima 0810, sampai itu ZPK lama
+		// tetap dipakai. Jika 0810 gagal terkirim ZPK baru aktif setelah grace window.
+		stagedZpk, err = h.importHostKeys(s.upstream, de48, repo.KeySourceHost, time.Now().Add(h.zpkGraceWindow()))
 		if err != nil {
-			h.Log.Errorf("network management handler -> save zpk to hsm: %v", err)
-			return
-		}
-
-		err = repo.KeyUpdateZPK(context.Background(), h.db, zpkEnc)
-		if err != nil {
-			h.Log.Errorf("network management handler -> update zpk to db: %v", err)
+			h.Log.Errorf("network management handler -> %v", err)
 			return
 		}
 
@@ -577,109 +641,76 @@ func (h *Handler) networkManagementHandler(msg []byte) {
 		}
 	}
 
-	hostResMsg := hex.EncodeToString(isoResponse)
-	i := len(isoResponse)
-	msgWlen := fmt.Sprintf("%04X%s", i, hostResMsg)
-	msgSend, err := hex.DecodeString(msgWlen)
+	// h.Log.Printf("send response to host: %s", string(isoResponse))
+	h.Log.WithField("debug_tag", "ul_out").Debugf("response to host %s : %s", s, isoResponse)
+
+	isoResponse, err = s.upstream.toWire(isoResponse)
 	if err != nil {
-		h.Log.Errorf("network management handler -> decode msg: %v", err)
+		h.Log.Errorf("network management handler -> %v", err)
 		return
 	}
 
-	// h.Log.Printf("send response to host: %s", string(isoResponse))
-	h.Log.WithField("debug_tag", "ul_out").Debugf("response to host : %s", isoResponse)
+	msgSend, err := s.upstream.hostFraming().Encode(framer.Frame{Message: isoResponse})
+	if err != nil {
+		h.Log.Errorf("network management handler -> encode frame: %v", err)
+		return
+	}
 
-	_, err = h.hostConn.Write(msgSend)
+	hostConn := s.getConn()
+	if hostConn == nil {
+		h.Log.Errorf("network management handler -> host %s not connected", s)
+		return
+	}
+
+	_, err = hostConn.Write(msgSend)
 	if err != nil {
 		h.Log.Errorf("network management handler -> write response nm to host: %v", err)
 		return
 	}
+
+	if stagedZpk != "" {
+		if err := h.confirmHostZPK(s.upstream, stagedZpk); err != nil {
+			h.Log.Errorf("network management handler -> %v", err)
+		}
+	}
 }
 
 func (h *Handler) HostHealthCheck(ctx context.Context) {
 	h.Log.Info("Starting host health check goroutine.")
-	ticker := time.NewTicker(time.Duration(h.Config.EchoTestTime) * time.Second)
+	interval := time.Duration(h.Config.EchoTestTime) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			h.hostConnLock.Lock()
-			hostConn := h.hostConn
-			h.hostConnLock.Unlock()
-
-			if hostConn != nil {
-				serverAddress := fmt.Sprintf("127.0.0.1:%d", h.Config.ListenPort)
-				conn, err := net.Dial("tcp", serverAddress)
-				if err != nil {
-					h.Log.Errorf("cron echo test -> failed to connect to host %s: %v", serverAddress, err)
-					continue
-				}
-
-				isoEchoTest := "0024600019000008008220000100000000040000000000000010080817400000020362800301"
-				msgEchoTest, err := hex.DecodeString(isoEchoTest)
-				if err != nil {
-					h.Log.Errorf("cron echo test -> failed to decode msg echo test: %v", err)
-					continue
-				}
-
-				h.Log.Info("send echo test..")
-				_, err = conn.Write(msgEchoTest)
-				if err != nil {
-					h.Log.Errorf("cron echo test -> failed to write echo test: %v", err)
-					continue
-				}
+			for _, u := range h.upstreams() {
+				for _, s := range u.sessions {
+					if s.getConn() == nil {
+						continue
+					}
 
-				header := make([]byte, 2)
-				_, err = io.ReadFull(conn, header)
-				if err != nil {
-					if err == io.EOF {
-						h.Log.Warnf("cron echo test -> Host connection closed gracefully.")
-					} else {
-						h.Log.Errorf("cron echo test -> failed read header response echo test: %v", err)
+					h.Log.Infof("send echo test to %s..", s)
+					isomessage, err := h.sendNetworkManagement(s, NetMgmtTypeEcho)
+					if err != nil {
+						h.Log.Errorf("cron echo test -> %s: %v", s, err)
+						continue
 					}
-					continue
-				}
 
-				headerStr := hex.EncodeToString(header)
-				msgLength, err := strconv.ParseInt(headerStr, 16, 64)
-				if err != nil {
-					h.Log.Errorf("cron echo test -> failed to parse length echo test: %v", err)
-					continue
-				}
+					responseCode, err := isomessage.GetString(39)
+					if err != nil {
+						h.Log.Errorf("cron echo test -> failed to unpack bit 39 response echo test: %v", err)
+						continue
+					}
 
-				messageBytes := make([]byte, msgLength)
-				_, err = io.ReadFull(conn, messageBytes)
-				if err != nil {
-					if err == io.EOF {
-						h.Log.Warnf("cron echo test -> Host connection closed gracefully.")
+					if responseCode == "00" {
+						h.Log.Infof("echo test %s ok", s)
 					} else {
-						h.Log.Errorf("cron echo test -> failed read body response echo test: %v", err)
+						h.Log.Infof("echo test %s not ok, rc %s", s, responseCode)
 					}
-					continue
-				}
-
-				conn.Close()
-
-				fullMessage := append(header, messageBytes...)
-				isoResString := strings.ToUpper(hex.EncodeToString(fullMessage))
-
-				isomessage := iso8583.NewMessage(iso.Spec87Hex)
-				if err := isomessage.Unpack([]byte(isoResString[14:])); err != nil {
-					h.Log.Errorf("cron echo test -> failed to unpack response echo test: %v", err)
-					continue
-				}
-
-				responseCode, err := isomessage.GetString(39)
-				if err != nil {
-					h.Log.Errorf("cron echo test -> failed to unpack bit 39 response echo test: %v", err)
-					continue
-				}
-
-				if responseCode == "00" {
-					h.Log.Info("echo test ok")
-				} else {
-					h.Log.Infof("echo test not ok, rc %s", responseCode)
 				}
 			}
 		case <-ctx.Done(): // ✅ Deteksi sinyal pembatalan
@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/internal/sequence"
+	"github.com/alfianX/danus-h2h/pkg/framer"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// Session di test langsung menghubungi host palsu
+	hostConnectDelay = 0
+}
+
+// fakeHost adalah host palsu di 127.0.0.1. 0800 dijawab 0810 RC 00 (new key
+// dijawab RC 96 agar tidak perlu DB), pesan lain diteruskan ke handle atau
+// dijawab RC 00 jika handle nil.
+type fakeHost struct {
+	listener net.Listener
+	accepted atomic.Int32
+	handle   func(conn net.Conn, isomessage *iso8583.Message)
+}
+
+func newFakeHost(t *testing.T, handle func(conn net.Conn, isomessage *iso8583.Message)) *fakeHost {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeHost{listener: listener, handle: handle}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.accepted.Add(1)
+			t.Cleanup(func() { conn.Close() })
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeHost) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeHost) serve(conn net.Conn) {
+	for {
+		frame, err := defaultHostFramer.ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		isomessage := iso8583.NewMessage(iso.Spec87)
+		if err := isomessage.Unpack(frame.Message); err != nil {
+			return
+		}
+
+		mti, _ := isomessage.GetMTI()
+		code, _ := isomessage.GetString(70)
+		switch {
+		case mti == "0800" && code == NetMgmtTypeNewKey:
+			replyFakeHost(conn, isomessage, "96")
+		case mti == "0800":
+			replyFakeHost(conn, isomessage, "00")
+		case f.handle != nil:
+			f.handle(conn, isomessage)
+		default:
+			replyFakeHost(conn, isomessage, "00")
+		}
+	}
+}
+
+func replyFakeHost(conn net.Conn, isomessage *iso8583.Message, rc string) {
+	mti, _ := isomessage.GetMTI()
+	response, err := iso.MTI(mti).Response()
+	if err != nil {
+		return
+	}
+	isomessage.MTI(string(response))
+	if isomessage.Field(39, rc) != nil {
+		return
+	}
+	msg, err := isomessage.Pack()
+	if err != nil {
+		return
+	}
+	defaultHostFramer.WriteFrame(conn, framer.Frame{Message: msg})
+}
+
+// deadAddress mengembalikan alamat yang menolak koneksi.
+func deadAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func newHostTestHandler(t *testing.T) *Handler {
+	stanGen, err := sequence.NewStanGenerator(sequence.NewFileReserver(filepath.Join(t.TempDir(), "stan.json")), sequence.Options{})
+	require.NoError(t, err)
+
+	logger, _ := logtest.NewNullLogger()
+	h := &Handler{Log: logger, stanGen: stanGen}
+	h.Config.TimeoutTrx = 30
+	h.Config.HostSessions = 1
+	h.Config.HostProbeInterval = 1
+	h.Config.HostProbeSuccess = 1
+	h.Config.HostBalance = BalanceRoundRobin
+	return h
+}
+
+func waitConnected(t *testing.T, s *hostSession) SessionState {
+	require.Eventually(t, func() bool { return s.state().Connected }, 5*time.Second, 10*time.Millisecond)
+	return s.state()
+}
+
+func newRouteMessage(t *testing.T, mti, pan, procode string) *iso8583.Message {
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	isomessage.MTI(mti)
+	require.NoError(t, isomessage.Field(2, pan))
+	require.NoError(t, isomessage.Field(3, procode))
+	require.NoError(t, isomessage.Field(11, "000001"))
+	return isomessage
+}
+
+func TestRouteUpstream(t *testing.T) {
+	h := newHostTestHandler(t)
+	hostDefault, hostA, hostB, hostP := newFakeHost(t, nil), newFakeHost(t, nil), newFakeHost(t, nil), newFakeHost(t, nil)
+	h.Config.HostAddress = hostDefault.addr()
+
+	services := []repo.Services{
+		{ServiceName: "bank_a", ServicePrefix: "41", ServiceAddress: hostA.addr()},
+		{ServiceName: "bank_b", ServicePrefix: "4111", ServiceAddress: hostB.addr()},
+		{ServiceName: "bank_p", ServicePrefix: "38", ServiceAddress: hostP.addr(), ServiceMethod: repo.ServiceMethodProcode},
+	}
+
+	tests := []struct {
+		name    string
+		mti     string
+		pan     string
+		procode string
+		want    string
+		host    *fakeHost
+	}{
+		{"prefix terpanjang", "0200", "4111111111111111", "000000", "bank_b", hostB},
+		{"prefix pendek", "0200", "4122222222222222", "000000", "bank_a", hostA},
+		{"procode", "0200", "5222222222222222", "380000", "bank_p", hostP},
+		{"tidak cocok", "0200", "5222222222222222", "000000", DefaultUpstreamName, hostDefault},
+		{"network management", "0800", "4111111111111111", "000000", DefaultUpstreamName, hostDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := h.routeUpstream(services, newRouteMessage(t, tt.mti, tt.pan, tt.procode))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, u.Name)
+			assert.Equal(t, tt.host.addr(), u.Address)
+
+			// Session upstream terhubung ke host hasil routing
+			waitConnected(t, u.sessions[0])
+			assert.Positive(t, tt.host.accepted.Load())
+		})
+	}
+
+	// Tanpa services semua transaksi ke upstream default
+	u, err := h.routeUpstream(nil, newRouteMessage(t, "0200", "4111111111111111", "000000"))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultUpstreamName, u.Name)
+}
+
+func TestGetUpstreamByName(t *testing.T) {
+	h := newHostTestHandler(t)
+	var err error
+	h.hostSpecs, err = parseHostSpecs("bank_b=spec93", "bank_b=93")
+	require.NoError(t, err)
+	host := newFakeHost(t, nil)
+
+	// Dua service dengan alamat host sama tetap punya upstream sendiri
+	a := h.getUpstream("bank_a", host.addr())
+	b := h.getUpstream("bank_b", host.addr())
+	require.NotSame(t, a, b)
+	assert.Equal(t, "bank_a", a.Name)
+	assert.Equal(t, "bank_b", b.Name)
+	assert.Same(t, iso.Spec87, a.wireSpec())
+	assert.Equal(t, iso.Version93, b.messageVersion())
+	assert.Same(t, a, h.getUpstream("bank_a", host.addr()))
+	assert.Len(t, h.upstreams(), 2)
+}
+
+func TestConnectSessionFailover(t *testing.T) {
+	h := newHostTestHandler(t)
+	h.Config.HostProbeInterval = 60
+	standby := newFakeHost(t, nil)
+
+	u := h.getUpstream("bank_x", deadAddress(t)+","+standby.addr())
+	state := waitConnected(t, u.sessions[0])
+	assert.Equal(t, standby.addr(), state.Endpoint)
+	assert.True(t, state.Standby)
+	assert.Equal(t, int32(1), standby.accepted.Load())
+}
+
+func TestProbePrimarySwitchback(t *testing.T) {
+	h := newHostTestHandler(t)
+	primary, standby := newFakeHost(t, nil), newFakeHost(t, nil)
+
+	u := &upstream{Name: "bank_x", Address: primary.addr() + "," + standby.addr(), Endpoints: []string{primary.addr(), standby.addr()}}
+	s := &hostSession{upstream: u, ID: 1}
+	u.sessions = []*hostSession{s}
+
+	// Session mulai di standby, seperti setelah primary gagal saat connect
+	standbyConn, err := h.dialHost(standby.addr())
+	require.NoError(t, err)
+	h.activateConn(s, 1, standbyConn)
+	assert.True(t, s.state().Standby)
+
+	// Echo test ke primary berhasil (HOST_PROBE_SUCCESS 1), trafik pindah ke primary
+	require.Eventually(t, func() bool { return !s.state().Standby }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, primary.addr(), s.state().Endpoint)
+	assert.Positive(t, primary.accepted.Load())
+
+	// Request berikutnya dijawab lewat koneksi primary
+	isoSend, err := newRouteMessage(t, "0200", "4111111111111111", "000000").Pack()
+	require.NoError(t, err)
+	response, err := h.hostRequest(s, isoSend, 5*time.Second)
+	require.NoError(t, err)
+	rc, _ := response.GetString(39)
+	assert.Equal(t, "00", rc)
+}
+
+func TestHostDisconnectRespondsHostDown(t *testing.T) {
+	h := newHostTestHandler(t)
+	// Host menutup koneksi saat menerima transaksi, tanpa menjawab
+	host := newFakeHost(t, func(conn net.Conn, isomessage *iso8583.Message) {
+		conn.Close()
+	})
+
+	u := &upstream{Name: "bank_x", Address: host.addr(), Endpoints: []string{host.addr()}}
+	s := &hostSession{upstream: u, ID: 1}
+	u.sessions = []*hostSession{s}
+	hostConn, err := h.dialHost(host.addr())
+	require.NoError(t, err)
+	h.activateConn(s, 0, hostConn)
+
+	// STAN berbeda dari sign on yang dikirim activateConn
+	isomessage := newRouteMessage(t, "0200", "4111111111111111", "000000")
+	require.NoError(t, isomessage.Field(11, "900001"))
+	isoSend, err := isomessage.Pack()
+	require.NoError(t, err)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	start := time.Now()
+	go h.sendSingleHostHandler(context.Background(), server, u, isoSend, 0)
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	frame, err := defaultTerminalFramer.ReadFrame(client)
+	require.NoError(t, err)
+
+	// Request yang menunggu digagalkan saat koneksi putus, tidak menunggu TIMEOUT_TRX
+	assert.Less(t, time.Since(start), time.Duration(h.Config.TimeoutTrx)*time.Second/2)
+	response := iso8583.NewMessage(iso.TerminalSpec())
+	require.NoError(t, response.Unpack([]byte(hex.EncodeToString(frame.Message))))
+	rc, _ := response.GetString(39)
+	assert.Equal(t, RCErrHostDown, rc)
+	assert.Eventually(t, func() bool { return h.countPending(hostConn) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestStanNarrowWidthRoundTrip(t *testing.T) {
+	h := newHostTestHandler(t)
+	stanGen, err := sequence.NewStanGenerator(sequence.NewFileReserver(filepath.Join(t.TempDir(), "stan6.json")), sequence.Options{Width: 6})
+	require.NoError(t, err)
+	h.stanGen = stanGen
+	h.stanManage = make(map[string]StanManage)
+	host := newFakeHost(t, nil)
+
+	u := &upstream{Name: "bank_x", Address: host.addr(), Endpoints: []string{host.addr()}}
+	s := &hostSession{upstream: u, ID: 1}
+	u.sessions = []*hostSession{s}
+
+	// Echo test probe dicocokkan dengan STAN yang sudah di-pad ke panjang bit 11
+	probeConn, err := h.probeEndpoint(u, host.addr())
+	require.NoError(t, err)
+	probeConn.Close()
+
+	hostConn, err := h.dialHost(host.addr())
+	require.NoError(t, err)
+	h.activateConn(s, 0, hostConn)
+
+	req := packTestMessage(t, "0200", map[int]string{2: "4111111111111111", 3: "000000", 11: "000123", 41: "TID00001"})
+	for i := 0; i < 2; i++ {
+		isoSend, stanHost, err := h.changeStanFromClient(req)
+		require.NoError(t, err)
+		assert.Len(t, stanHost, stanHostLength)
+
+		response, err := h.hostRequest(s, isoSend, 5*time.Second)
+		require.NoError(t, err)
+		res, err := response.Pack()
+		require.NoError(t, err)
+
+		toClient, err := h.changeStanFromHost(res)
+		require.NoError(t, err)
+		isomessage := iso8583.NewMessage(iso.Spec87)
+		require.NoError(t, isomessage.Unpack(toClient))
+		stan, _ := isomessage.GetString(11)
+		assert.Equal(t, "123", stan)
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+)
+
+const (
+	keyLength = 32
+	kcvLength = 6
+)
+
+var ErrKeyCheckValue = errors.New("key check value mismatch")
+
+// hostKeys adalah isi bit 48 key exchange host. Tiap kunci 32 hex boleh diikuti
+// KCV 6 hex: ZPK[KCV] lalu ZAK[KCV] jika MAC ke host aktif.
+type hostKeys struct {
+	Zpk    string
+	ZpkKcv string
+	Zak    string
+	ZakKcv string
+}
+
+// parseHostKeys mengenali panjang bit 48 secara pasti: 32 (ZPK), 38 (ZPK+KCV),
+// 64 (ZPK+ZAK) dan 76 (ZPK+KCV+ZAK+KCV). Panjang lain di atas 32 tetap diterima
+// seperti sebelumnya, hanya 32 hex pertama yang dipakai sebagai ZPK.
+func parseHostKeys(de48 string, withZak, kcvRequired bool) (hostKeys, error) {
+	var keys hostKeys
+
+	switch len(de48) {
+	case keyLength:
+		keys.Zpk = de48
+	case keyLength + kcvLength:
+		keys.Zpk, keys.ZpkKcv = de48[:keyLength], de48[keyLength:]
+	case 2 * keyLength:
+		keys.Zpk, keys.Zak = de48[:keyLength], de48[keyLength:]
+	case 2 * (keyLength + kcvLength):
+		keys.Zpk, keys.ZpkKcv = de48[:keyLength], de48[keyLength:keyLength+kcvLength]
+		rest := de48[keyLength+kcvLength:]
+		keys.Zak, keys.ZakKcv = rest[:keyLength], rest[keyLength:]
+	default:
+		if len(de48) < keyLength {
+			return keys, fmt.Errorf("invalid bit 48 length: %d", len(de48))
+		}
+		keys.Zpk = de48[:keyLength]
+	}
+
+	if withZak && keys.Zak == "" {
+		return keys, fmt.Errorf("invalid bit 48 length: %d, zak expected", len(de48))
+	}
+	if kcvRequired && keys.ZpkKcv == "" {
+		return keys, fmt.Errorf("bit 48 has no kcv: %w", ErrKeyCheckValue)
+	}
+	return keys, nil
+}
+
+// checkKCV membandingkan KCV yang diharapkan dengan hasil HSM. KCV kosong dari
+// sisi pengirim berarti tidak bisa dicek dan dilewati.
+func checkKCV(name, expected, actual string) error {
+	if expected == "" {
+		return nil
+	}
+	if len(actual) < kcvLength || !strings.EqualFold(expected[:min(len(expected), kcvLength)], actual[:kcvLength]) {
+		return fmt.Errorf("%s %w: expected %s, got %s", name, ErrKeyCheckValue, expected, actual)
+	}
+	return nil
+}
+
+// requireKCV memastikan kunci yang dibuat HSM punya KCV sebelum disimpan. KCV
+// tidak dihitung ulang dari kunci di bawah LMK yang sama karena hasilnya pasti
+// sama; pembanding yang independen adalah terminal, yang menerima KCV di bit 48
+// logon (TERMINAL_KCV) dan mencocokkannya dengan kunci hasil decrypt TMK.
+func (h *Handler) requireKCV(name, kcv string) error {
+	if len(kcv) < kcvLength {
+		err := fmt.Errorf("%s has no kcv: %w", name, ErrKeyCheckValue)
+		h.alertKeyCheck(name, err)
+		return err
+	}
+	return nil
+}
+
+// logonKeyBlock menyusun bit 48 respons logon: TPK di bawah TMK lalu TAK di
+// bawah TMK jika MAC terminal aktif. Dengan withKcv tiap kunci diikuti KCV 6
+// hex, sama seperti layout bit 48 dari host.
+func logonKeyBlock(tpk, tpkKcv, tak, takKcv string, withKcv bool) string {
+	block := tpk
+	if withKcv {
+		block += tpkKcv[:kcvLength]
+	}
+	if tak != "" {
+		block += tak
+		if withKcv {
+			block += takKcv[:kcvLength]
+		}
+	}
+	return block
+}
+
+// alertKeyCheck mencatat penolakan kunci karena KCV, dipantau lewat log alert
+// dan metric key_check_failures_total.
+func (h *Handler) alertKeyCheck(name string, err error) {
+	metrics.KeyCheckFailures.WithLabelValues(name).Inc()
+	h.Log.WithField("alert", "key_check_value").Errorf("%s rejected: %v", name, err)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHostKeys(t *testing.T) {
+	zpk := strings.Repeat("1", 32)
+	zak := strings.Repeat("2", 32)
+
+	tests := []struct {
+		name        string
+		de48        string
+		withZak     bool
+		kcvRequired bool
+		want        hostKeys
+	}{
+		{"32 zpk", zpk, false, false, hostKeys{Zpk: zpk}},
+		{"35 zpk with trailing data", zpk + "ABC", false, false, hostKeys{Zpk: zpk}},
+		{"37 zpk with trailing data", zpk + "ABCDE", false, false, hostKeys{Zpk: zpk}},
+		{"38 zpk kcv", zpk + "ABCDEF", false, true, hostKeys{Zpk: zpk, ZpkKcv: "ABCDEF"}},
+		{"50 zpk with trailing data", zpk + strings.Repeat("9", 18), false, false, hostKeys{Zpk: zpk}},
+		{"64 zpk zak", zpk + zak, true, false, hostKeys{Zpk: zpk, Zak: zak}},
+		{"76 zpk kcv zak kcv", zpk + "ABCDEF" + zak + "123456", true, true, hostKeys{Zpk: zpk, ZpkKcv: "ABCDEF", Zak: zak, ZakKcv: "123456"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseHostKeys(tt.de48, tt.withZak, tt.kcvRequired)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+
+	// Tanpa MAC ke host, ZAK di bit 48 tetap terbaca tapi tidak wajib
+	keys, err := parseHostKeys(zpk+"ABCDEF"+zak+"123456", false, false)
+	require.NoError(t, err)
+	assert.Equal(t, "ABCDEF", keys.ZpkKcv)
+
+	_, err = parseHostKeys(zpk, false, true)
+	assert.ErrorIs(t, err, ErrKeyCheckValue)
+
+	_, err = parseHostKeys(zpk+"ABCDE", false, true)
+	assert.ErrorIs(t, err, ErrKeyCheckValue)
+
+	_, err = parseHostKeys(zpk+"ABCDEF", true, false)
+	assert.ErrorContains(t, err, "zak expected")
+
+	_, err = parseHostKeys(zpk[:16], false, false)
+	assert.ErrorContains(t, err, "invalid bit 48 length")
+}
+
+func TestCheckKCV(t *testing.T) {
+	assert.NoError(t, checkKCV("zpk", "abcdef", "ABCDEF"))
+	assert.NoError(t, checkKCV("zpk", "", "ABCDEF"))
+	assert.ErrorIs(t, checkKCV("zpk", "ABCDEF", "123456"), ErrKeyCheckValue)
+	assert.ErrorIs(t, checkKCV("zpk", "ABCDEF", ""), ErrKeyCheckValue)
+}
+
+func TestRequireKCV(t *testing.T) {
+	h := &Handler{Log: logrus.New()}
+	assert.NoError(t, h.requireKCV("tpk", "ABCDEF"))
+	assert.ErrorIs(t, h.requireKCV("tpk", ""), ErrKeyCheckValue)
+	assert.ErrorIs(t, h.requireKCV("tak", "ABC"), ErrKeyCheckValue)
+}
+
+func TestLogonKeyBlock(t *testing.T) {
+	tpk := strings.Repeat("1", 32)
+	tak := strings.Repeat("2", 32)
+
+	assert.Equal(t, tpk, logonKeyBlock(tpk, "ABCDEF", "", "", false))
+	assert.Equal(t, tpk+tak, logonKeyBlock(tpk, "ABCDEF", tak, "123456", false))
+	assert.Equal(t, tpk+"ABCDEF", logonKeyBlock(tpk, "ABCDEF", "", "", true))
+
+	// Layout sama dengan bit 48 dari host sehingga bisa dibaca parseHostKeys
+	block := logonKeyBlock(tpk, "ABCDEF", tak, "123456", true)
+	keys, err := parseHostKeys(block, true, true)
+	require.NoError(t, err)
+	assert.Equal(t, hostKeys{Zpk: tpk, ZpkKcv: "ABCDEF", Zak: tak, ZakKcv: "123456"}, keys)
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/hsm"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+)
+
+const macLength = 8
+
+var ErrMacMissing = errors.New("mac missing")
+
+// terminalMAC mengambil data dan MAC dari pesan binary terminal (MTI 2 byte BCD
+// lalu bitmap). MAC ada di bit 64, atau bit 128 jika ada secondary bitmap, dan
+// selalu menjadi field terakhir pesan.
+func terminalMAC(raw []byte) ([]byte, string, error) {
+	if len(raw) < 10+macLength {
+		return nil, "", ErrMacMissing
+	}
+
+	present := raw[9]&0x01 != 0
+	if raw[2]&0x80 != 0 {
+		if len(raw) < 18+macLength {
+			return nil, "", ErrMacMissing
+		}
+		present = raw[17]&0x01 != 0
+	}
+	if !present {
+		return nil, "", ErrMacMissing
+	}
+
+	data := raw[:len(raw)-macLength]
+	return data, strings.ToUpper(hex.EncodeToString(raw[len(raw)-macLength:])), nil
+}
+
+// verifyTerminalMAC memeriksa MAC request terminal dengan TAK yang dibuat saat logon.
+func (h *Handler) verifyTerminalMAC(ctx context.Context, tid string, raw []byte) error {
+	data, mac, err := terminalMAC(raw)
+	if err != nil {
+		return err
+	}
+
+	tak, err := repo.TerminalKeyGetTAK(ctx, h.db, tid)
+	if err != nil {
+		return fmt.Errorf("get tak: %w", err)
+	}
+	if tak == "" {
+		return fmt.Errorf("tid %s: %w", tid, hsm.ErrKeyNotFound)
+	}
+
+	return h.hsm.VerifyMAC(ctx, tak, data, mac)
+}
+
+// signHostMessage mengganti MAC pesan ke host dengan MAC dari ZAK host tersebut.
+// Pesan sudah dalam spec wire host. Pesan network management dikirim tanpa MAC.
+func (h *Handler) signHostMessage(ctx context.Context, u *upstream, msg []byte) ([]byte, error) {
+	isomessage := iso8583.NewMessage(u.wireSpec())
+	if err := isomessage.Unpack(msg); err != nil {
+		return nil, fmt.Errorf("sign host message -> unpack iso: %w", err)
+	}
+	mti, err := isomessage.GetMTI()
+	if err != nil {
+		return nil, fmt.Errorf("sign host message -> get mti: %w", err)
+	}
+	if iso.MTI(mti).IsNetwork() {
+		return msg, nil
+	}
+
+	isomessage.UnsetField(64)
+	isomessage.UnsetField(128)
+	field := 64
+	for id := range isomessage.GetFields() {
+		if id > 64 {
+			field = 128
+			break
+		}
+	}
+
+	key, err := repo.HostKeyGet(ctx, h.db, u.Name)
+	if err != nil {
+		return nil, fmt.Errorf("sign host message -> get zak host %s: %w", u.Name, err)
+	}
+	zak := key.Zak
+	if zak == "" {
+		return nil, fmt.Errorf("sign host message -> host %s: %w: zak", u.Name, hsm.ErrKeyNotFound)
+	}
+
+	data, err := macInput(isomessage, field)
+	if err != nil {
+		return nil, fmt.Errorf("sign host message -> %w", err)
+	}
+	mac, err := h.hsm.GenerateMAC(ctx, zak, data)
+	if err != nil {
+		return nil, fmt.Errorf("sign host message -> %w", err)
+	}
+	macBytes, err := hex.DecodeString(mac)
+	if err != nil {
+		return nil, fmt.Errorf("sign host message -> decode mac: %w", err)
+	}
+	if err := setMAC(isomessage, field, macBytes); err != nil {
+		return nil, fmt.Errorf("sign host message -> %w", err)
+	}
+
+	return isomessage.Pack()
+}
+
+// macInput mengembalikan data yang di-MAC: pesan ter-pack tanpa field MAC.
+// Field MAC selalu di akhir pesan, panjangnya diambil dari hasil pack field itu
+// di spec host (16 byte ASCII hex, 8 byte binary, atau dengan prefix panjang).
+func macInput(isomessage *iso8583.Message, field int) ([]byte, error) {
+	if err := setMAC(isomessage, field, make([]byte, macLength)); err != nil {
+		return nil, err
+	}
+	packed, err := isomessage.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("pack iso: %w", err)
+	}
+	packedMac, err := isomessage.GetField(field).Pack()
+	if err != nil {
+		return nil, fmt.Errorf("pack mac: %w", err)
+	}
+	if len(packedMac) > len(packed) {
+		return nil, fmt.Errorf("packed mac longer than message")
+	}
+	return packed[:len(packed)-len(packedMac)], nil
+}
+
+// setMAC mengisi field MAC sesuai spec host: 8 byte apa adanya (binary atau
+// codec hex), atau 16 karakter hex jika field menyimpan MAC sebagai teks hex.
+func setMAC(isomessage *iso8583.Message, field int, mac []byte) error {
+	var err error
+	for _, value := range []string{string(mac), strings.ToUpper(hex.EncodeToString(mac))} {
+		if err = isomessage.Field(field, value); err != nil {
+			continue
+		}
+		if _, err = isomessage.GetField(field).Pack(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("set mac bit %d: %w", field, err)
+}
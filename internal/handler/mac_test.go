@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"github.com/moov-io/iso8583/encoding"
+	"github.com/moov-io/iso8583/field"
+	"github.com/moov-io/iso8583/prefix"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminalMAC(t *testing.T) {
+	mac := []byte{0xA1, 0xC7, 0x2E, 0x74, 0xEA, 0x3F, 0xA9, 0xB6}
+
+	// 0200 dengan bit 64 di primary bitmap
+	primary := append([]byte{0x02, 0x00, 0x20, 0, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00, 0x01}, mac...)
+	data, got, err := terminalMAC(primary)
+	require.NoError(t, err)
+	assert.Equal(t, primary[:13], data)
+	assert.Equal(t, "A1C72E74EA3FA9B6", got)
+
+	// Secondary bitmap: MAC harus di bit 128
+	secondary := append([]byte{0x04, 0x00, 0x80, 0, 0, 0, 0, 0, 0, 0x01, 0, 0, 0, 0, 0, 0, 0, 0x01}, mac...)
+	data, got, err = terminalMAC(secondary)
+	require.NoError(t, err)
+	assert.Len(t, data, 18)
+	assert.Equal(t, "A1C72E74EA3FA9B6", got)
+
+	secondary[17] = 0
+	_, _, err = terminalMAC(secondary)
+	assert.ErrorIs(t, err, ErrMacMissing)
+
+	noMac := append([]byte{0x02, 0x00, 0x20, 0, 0, 0, 0, 0, 0, 0x00, 0x00, 0x00, 0x01}, mac...)
+	_, _, err = terminalMAC(noMac)
+	assert.ErrorIs(t, err, ErrMacMissing)
+	assert.Equal(t, RCErrSecurity, hsmResponseCode(err))
+}
+
+func TestMacInput(t *testing.T) {
+	newMessage := func(spec *iso8583.MessageSpec) *iso8583.Message {
+		isomessage := iso8583.NewMessage(spec)
+		isomessage.MTI("0200")
+		require.NoError(t, isomessage.Field(3, "000000"))
+		require.NoError(t, isomessage.Field(11, "000045"))
+		require.NoError(t, isomessage.Field(41, "TID00001"))
+		return isomessage
+	}
+	unsigned := func(spec *iso8583.MessageSpec) []byte {
+		packed, err := newMessage(spec).Pack()
+		require.NoError(t, err)
+		return packed
+	}
+
+	// Spec87: MAC disimpan 16 karakter hex, 32 byte di wire
+	data, err := macInput(newMessage(iso.Spec87), 64)
+	require.NoError(t, err)
+	assert.Len(t, data, len(unsigned(iso.Spec87)))
+	assert.Equal(t, string(unsigned(iso.Spec87)[20:]), string(data[20:]))
+
+	// Spec87Hex: codec mac-hex, 8 byte menjadi 16 karakter hex
+	data, err = macInput(newMessage(iso.Spec87Hex), 64)
+	require.NoError(t, err)
+	assert.Len(t, data, len(unsigned(iso.Spec87Hex)))
+
+	// Host dengan bit 64 binary 8 byte
+	binarySpec := &iso8583.MessageSpec{Name: "binary mac", Fields: make(map[int]field.Field)}
+	for id, f := range iso.Spec87.Fields {
+		binarySpec.Fields[id] = f
+	}
+	binarySpec.Fields[64] = field.NewString(&field.Spec{
+		Length:      8,
+		Description: "Message Authentication Code (MAC)",
+		Enc:         encoding.Binary,
+		Pref:        prefix.Binary.Fixed,
+	})
+	isomessage := newMessage(binarySpec)
+	data, err = macInput(isomessage, 64)
+	require.NoError(t, err)
+	assert.Len(t, data, len(unsigned(binarySpec)))
+	assert.Equal(t, string(unsigned(binarySpec)[20:]), string(data[20:]))
+
+	// MAC terpasang di akhir pesan tepat setelah data yang di-MAC
+	mac := []byte{0xA1, 0xC7, 0x2E, 0x74, 0xEA, 0x3F, 0xA9, 0xB6}
+	require.NoError(t, setMAC(isomessage, 64, mac))
+	signed, err := isomessage.Pack()
+	require.NoError(t, err)
+	assert.Equal(t, append(append([]byte{}, data...), mac...), signed)
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+)
+
+// mapFields menjalankan aturan FIELD_MAP_FILE untuk arah dan host tersebut ke
+// pesan Spec87. Pesan hanya di-pack ulang jika ada aturan yang berlaku.
+func (h *Handler) mapFields(direction string, u *upstream, msg []byte) ([]byte, error) {
+	if h.fieldMap == nil {
+		return msg, nil
+	}
+
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	if err := isomessage.Unpack(msg); err != nil {
+		return nil, fmt.Errorf("field map -> unpack iso: %w", err)
+	}
+	changed, err := h.fieldMap.Apply(direction, u.Name, isomessage)
+	if err != nil {
+		return nil, fmt.Errorf("field map -> %w", err)
+	}
+	if !changed {
+		return msg, nil
+	}
+	return isomessage.Pack()
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+)
+
+// Fungsi-fungsi di file ini dipakai oleh admin API.
+
+type SessionState struct {
+	ID          int    `json:"id"`
+	Connected   bool   `json:"connected"`
+	Endpoint    string `json:"endpoint,omitempty"`
+	Standby     bool   `json:"standby"`
+	Outstanding int64  `json:"outstanding"`
+}
+
+type HostState struct {
+	Name      string         `json:"name"`
+	Address   string         `json:"address"`
+	Connected bool           `json:"connected"`
+	Sessions  []SessionState `json:"sessions"`
+}
+
+type NetworkResult struct {
+	Session      string `json:"session"`
+	ResponseCode string `json:"response_code,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+var ErrUnknownUpstream = errors.New("unknown upstream")
+
+func (s *hostSession) state() SessionState {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+
+	state := SessionState{ID: s.ID, Connected: s.conn != nil, Outstanding: s.outstanding.Load()}
+	if s.conn != nil {
+		state.Endpoint = s.upstream.Endpoints[s.active]
+		state.Standby = s.active > 0
+	}
+	return state
+}
+
+func (h *Handler) HostStates() []HostState {
+	var states []HostState
+	for _, u := range h.upstreams() {
+		state := HostState{Name: u.Name, Address: u.Address}
+		for _, s := range u.sessions {
+			sessionState := s.state()
+			state.Connected = state.Connected || sessionState.Connected
+			state.Sessions = append(state.Sessions, sessionState)
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// InflightCount adalah jumlah request yang sedang menunggu respons host.
+func (h *Handler) InflightCount() int {
+	count := 0
+	h.responseMap.Range(func(key, value any) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// Ready mengembalikan error jika DB tidak bisa dihubungi atau tidak ada host yang terhubung.
+func (h *Handler) Ready(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+
+	for _, state := range h.HostStates() {
+		if state.Connected {
+			return nil
+		}
+	}
+	return errors.New("no host connected")
+}
+
+// selectUpstreams mengembalikan upstream dengan nama tertentu, atau semua jika name kosong.
+func (h *Handler) selectUpstreams(name string) ([]*upstream, error) {
+	var list []*upstream
+	for _, u := range h.upstreams() {
+		if name == "" || u.Name == name {
+			list = append(list, u)
+		}
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownUpstream, name)
+	}
+	return list, nil
+}
+
+// NetworkManagement mengirim sign on, sign off atau echo ke setiap session yang terhubung.
+// Untuk new key, permintaan dikirim sekali per upstream.
+func (h *Handler) NetworkManagement(name, code string) ([]NetworkResult, error) {
+	switch code {
+	case NetMgmtTypeSignOn, NetMgmtTypeSignOff, NetMgmtTypeEcho, NetMgmtTypeNewKey:
+	default:
+		return nil, fmt.Errorf("unsupported network management code %s", code)
+	}
+
+	list, err := h.selectUpstreams(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []NetworkResult
+	for _, u := range list {
+		for _, s := range u.sessions {
+			if s.getConn() == nil {
+				continue
+			}
+
+			result := NetworkResult{Session: s.String()}
+			if code == NetMgmtTypeNewKey {
+				if err := h.requestNewKey(s, repo.KeySourceManual); err != nil {
+					result.Error = err.Error()
+				} else {
+					result.ResponseCode = "00"
+				}
+				results = append(results, result)
+				break
+			}
+
+			isomessage, err := h.sendNetworkManagement(s, code)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.ResponseCode, _ = isomessage.GetString(39)
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Reconnect menutup koneksi session yang terhubung, read loop akan menyambung ulang
+// mulai dari endpoint primary. Mengembalikan jumlah koneksi yang ditutup.
+func (h *Handler) Reconnect(name string) (int, error) {
+	list, err := h.selectUpstreams(name)
+	if err != nil {
+		return 0, err
+	}
+
+	closed := 0
+	for _, u := range list {
+		for _, s := range u.sessions {
+			conn := s.getConn()
+			if conn == nil {
+				continue
+			}
+			h.Log.Warnf("reconnect %s requested by admin", s)
+			conn.Close()
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+func (h *Handler) FindTransactions(ctx context.Context, filter repo.TransactionFilter) ([]repo.TransactionHistory, error) {
+	return repo.TransactionHistoryFind(ctx, h.db, filter)
+}
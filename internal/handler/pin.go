@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/hsm"
+	"github.com/moov-io/iso8583"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrKsnMissing = errors.New("ksn missing")
+
+// translatePin mengubah PIN block terminal ke ZPK host tujuan, dari format PIN block
+// grup terminal ke format host tujuan. Terminal yang grupnya punya BDK memakai
+// DUKPT dengan KSN dari request, field KSN lalu dihapus karena tidak dikirim ke
+// host. Terminal lain memakai TPK hasil logon. ZPK dipilih lewat translateUnderZPK.
+func (h *Handler) translatePin(ctx context.Context, isomessage *iso8583.Message, u *upstream, tid, pinBlock, pan string) (string, error) {
+	group, err := repo.TerminalGroupGetByTid(ctx, h.db, tid)
+	if err != nil {
+		return "", fmt.Errorf("get terminal group: %w", err)
+	}
+
+	formats := hsm.PinFormats{
+		Source:      h.pinFormats.terminal,
+		Destination: h.pinFormats.forHost(u.Name),
+	}
+	if group != nil && group.PinFormat != "" {
+		formats.Source, err = hsm.ParsePinFormat(group.PinFormat)
+		if err != nil {
+			return "", fmt.Errorf("group %s: %w", group.Name, err)
+		}
+	}
+
+	loadKey := func() (*repo.HostKey, error) {
+		return repo.HostKeyGet(ctx, h.db, u.Name)
+	}
+
+	if group == nil || group.Bdk == "" {
+		tpk, err := repo.TerminalKeyGetTPK(ctx, h.db, &repo.TerminalKey{Tid: tid})
+		if err != nil {
+			return "", fmt.Errorf("get tpk from db: %w", err)
+		}
+		return h.translateUnderZPK(ctx, u, loadKey, func(zpk string) (string, error) {
+			return h.hsm.TranslatePIN(ctx, tpk, zpk, pinBlock, pan, formats)
+		})
+	}
+
+	ksn, err := requestKsn(isomessage, h.Config.DukptKsnField)
+	if err != nil {
+		return "", fmt.Errorf("group %s: %w", group.Name, err)
+	}
+	isomessage.UnsetField(h.Config.DukptKsnField)
+
+	return h.translateUnderZPK(ctx, u, loadKey, func(zpk string) (string, error) {
+		return h.hsm.TranslatePINDukpt(ctx, group.Bdk, ksn, zpk, pinBlock, pan, formats)
+	})
+}
+
+// translateUnderZPK menjalankan translate dengan ZPK aktif host (HostKey.ActiveZPK).
+// Jika HSM menolak kunci itu, misalnya karena rotasi selesai saat transaksi
+// berjalan, host_key dibaca ulang dan translate diulang sekali dengan ZPK aktif
+// terbaru atau ZPK lama yang masih dalam grace window (HostKey.GraceZPK).
+func (h *Handler) translateUnderZPK(ctx context.Context, u *upstream, loadKey func() (*repo.HostKey, error), translate func(zpk string) (string, error)) (string, error) {
+	key, err := loadKey()
+	if err != nil {
+		return "", fmt.Errorf("get zpk host %s from db: %w", u.Name, err)
+	}
+	zpk, zpkKcv := key.ActiveZPK(time.Now())
+	if zpk == "" {
+		return "", fmt.Errorf("host %s: %w: zpk", u.Name, hsm.ErrKeyNotFound)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("hsm.zpk_kcv", zpkKcv))
+
+	pinBlock, err := translate(zpk)
+	if err == nil || !isKeyError(err) {
+		return pinBlock, err
+	}
+
+	key, reloadErr := loadKey()
+	if reloadErr != nil {
+		return "", err
+	}
+	now := time.Now()
+	activeZpk, activeKcv := key.ActiveZPK(now)
+	graceZpk, graceKcv := key.GraceZPK(now)
+	for _, retry := range [][2]string{{activeZpk, activeKcv}, {graceZpk, graceKcv}} {
+		if retry[0] == "" || retry[0] == zpk {
+			continue
+		}
+		h.Log.Warnf("translate pin -> zpk host %s kcv %s rejected, retry with kcv %s: %v", u.Name, zpkKcv, retry[1], err)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("hsm.zpk_kcv", retry[1]))
+		return translate(retry[0])
+	}
+	return "", err
+}
+
+// pinFormatConfig adalah format PIN block default terminal (grup tanpa
+// pin_format) dan format PIN block per host.
+type pinFormatConfig struct {
+	terminal string
+	host     string            // default semua host
+	hosts    map[string]string // per nama upstream
+}
+
+// parsePinFormats membaca PIN_FORMAT_TERMINAL dan PIN_FORMAT_HOST. Format host
+// berupa daftar dipisah koma, entri tanpa nama adalah default, misal
+// "ISO0,bank_x=ISO4".
+func parsePinFormats(terminal, host string) (pinFormatConfig, error) {
+	var cfg pinFormatConfig
+	var err error
+
+	cfg.terminal, err = hsm.ParsePinFormat(terminal)
+	if err != nil {
+		return cfg, fmt.Errorf("pin format terminal: %w", err)
+	}
+
+	cfg.host = hsm.PinFormatISO0
+	cfg.hosts = make(map[string]string)
+	for _, part := range strings.Split(host, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, named := strings.Cut(part, "=")
+		if !named {
+			value = name
+		}
+		format, err := hsm.ParsePinFormat(value)
+		if err != nil {
+			return cfg, fmt.Errorf("pin format host: %w", err)
+		}
+		if named {
+			cfg.hosts[strings.TrimSpace(name)] = format
+		} else {
+			cfg.host = format
+		}
+	}
+	return cfg, nil
+}
+
+func (c pinFormatConfig) forHost(name string) string {
+	if format, ok := c.hosts[name]; ok {
+		return format
+	}
+	return c.host
+}
+
+func isKeyError(err error) bool {
+	return errors.Is(err, hsm.ErrKeyParity) || errors.Is(err, hsm.ErrInvalidKey) || errors.Is(err, hsm.ErrKeyNotFound)
+}
+
+// requestKsn membaca KSN 20 hex dari field, terminal bisa mengirimnya sebagai
+// hex atau 10 byte binary.
+func requestKsn(isomessage *iso8583.Message, field int) (string, error) {
+	value, err := isomessage.GetString(field)
+	if err != nil {
+		return "", fmt.Errorf("unpack bit %d: %w", field, err)
+	}
+
+	switch len(value) {
+	case 20:
+		if _, err := hex.DecodeString(value); err == nil {
+			return strings.ToUpper(value), nil
+		}
+	case 10:
+		return strings.ToUpper(hex.EncodeToString([]byte(value))), nil
+	case 0:
+		return "", ErrKsnMissing
+	}
+	return "", fmt.Errorf("%w: invalid ksn length %d", ErrKsnMissing, len(value))
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/hsm"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestKsn(t *testing.T) {
+	isomessage := iso8583.NewMessage(iso.Spec87)
+
+	_, err := requestKsn(isomessage, 62)
+	assert.ErrorIs(t, err, ErrKsnMissing)
+	assert.Equal(t, RCErrFormatError, hsmResponseCode(err))
+
+	require.NoError(t, isomessage.Field(62, "ffff9876543210e00001"))
+	ksn, err := requestKsn(isomessage, 62)
+	require.NoError(t, err)
+	assert.Equal(t, "FFFF9876543210E00001", ksn)
+
+	require.NoError(t, isomessage.Field(62, "\xff\xff\x98\x76\x54\x32\x10\xe0\x00\x01"))
+	ksn, err = requestKsn(isomessage, 62)
+	require.NoError(t, err)
+	assert.Equal(t, "FFFF9876543210E00001", ksn)
+
+	require.NoError(t, isomessage.Field(62, "FFFF98765432"))
+	_, err = requestKsn(isomessage, 62)
+	assert.ErrorIs(t, err, ErrKsnMissing)
+}
+
+func TestZPKRotationMidFlight(t *testing.T) {
+	now := time.Now()
+	grace := 5 * time.Minute
+	key := repo.HostKey{Upstream: "bank_x", Zpk: "UOLD", ZpkKcv: "111111"}
+
+	// Transaksi membaca host_key sebelum host mengirim 0800/102
+	inFlight := key
+
+	key.StageZPK("UNEW", "222222", now.Add(grace), now, grace)
+
+	// Sebelum 0810 terkirim host masih memakai ZPK lama, transaksi yang sedang
+	// berjalan maupun yang baru masuk sama-sama memakai ZPK lama
+	zpk, kcv := inFlight.ActiveZPK(now.Add(time.Second))
+	assert.Equal(t, "UOLD", zpk)
+	assert.Equal(t, "111111", kcv)
+	zpk, _ = key.ActiveZPK(now.Add(time.Second))
+	assert.Equal(t, "UOLD", zpk)
+	zpk, _ = key.GraceZPK(now.Add(time.Second))
+	assert.Empty(t, zpk)
+
+	// Konfirmasi untuk key exchange lain ditolak, ZPK tahap tidak berubah
+	assert.ErrorIs(t, key.ConfirmZPK("UOTHER", now, grace), repo.ErrZpkNotStaged)
+	zpk, _ = key.ActiveZPK(now.Add(time.Second))
+	assert.Equal(t, "UOLD", zpk)
+
+	// Setelah konfirmasi ZPK lama masih berlaku selama grace window
+	require.NoError(t, key.ConfirmZPK("UNEW", now.Add(2*time.Second), grace))
+	zpk, kcv = key.ActiveZPK(now.Add(2 * time.Second))
+	assert.Equal(t, "UNEW", zpk)
+	assert.Equal(t, "222222", kcv)
+	assert.Empty(t, key.ZpkNext)
+	zpk, kcv = key.GraceZPK(now.Add(grace))
+	assert.Equal(t, "UOLD", zpk)
+	assert.Equal(t, "111111", kcv)
+	zpk, _ = key.GraceZPK(now.Add(2*time.Second + grace))
+	assert.Empty(t, zpk)
+	assert.NoError(t, key.ConfirmZPK("UNEW", now.Add(3*time.Second), grace), "konfirmasi ulang")
+
+	// 0810 tidak terkirim: ZPK baru aktif setelah grace window, ZPK aktif
+	// sebelumnya menjadi ZPK grace
+	key.StageZPK("UNEXT", "333333", now.Add(time.Minute), now, grace)
+	zpk, _ = key.ActiveZPK(now.Add(59 * time.Second))
+	assert.Equal(t, "UNEW", zpk)
+	zpk, _ = key.ActiveZPK(now.Add(time.Minute))
+	assert.Equal(t, "UNEXT", zpk)
+	zpk, _ = key.GraceZPK(now.Add(time.Minute))
+	assert.Equal(t, "UNEW", zpk)
+
+	// Key exchange berikutnya menjadikan ZPK tahap yang sudah aktif sebagai ZPK aktif
+	key.StageZPK("ULAST", "444444", now.Add(3*time.Minute), now.Add(2*time.Minute), grace)
+	assert.Equal(t, "UNEXT", key.Zpk)
+	assert.Equal(t, "UNEW", key.ZpkPrev)
+	zpk, _ = key.ActiveZPK(now.Add(2 * time.Minute))
+	assert.Equal(t, "UNEXT", zpk)
+
+	// ZPK tahap yang belum aktif diganti tanpa pernah dipakai
+	key.StageZPK("UREPLACE", "555555", now.Add(4*time.Minute), now.Add(2*time.Minute), grace)
+	assert.Equal(t, "UNEXT", key.Zpk)
+	assert.Equal(t, "UREPLACE", key.ZpkNext)
+}
+
+func TestTranslateUnderZPKRotationMidFlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		grace time.Duration
+		want  string
+	}{
+		// ZPK lama masih berlaku, translate pertama berhasil
+		{"dalam grace window", 5 * time.Minute, "PIN-UOLD"},
+		// ZPK lama langsung tidak berlaku, translate diulang dengan ZPK baru
+		{"tanpa grace window", 0, "PIN-UNEW"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{Log: logrus.New()}
+			u := &upstream{Name: "bank_x"}
+
+			var mu sync.Mutex
+			stored := repo.HostKey{Upstream: "bank_x", Zpk: "UOLD", ZpkKcv: "111111"}
+			loadKey := func() (*repo.HostKey, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				key := stored
+				return &key, nil
+			}
+
+			// HSM palsu hanya menerima ZPK aktif atau ZPK dalam grace window
+			started, rotated := make(chan struct{}), make(chan struct{})
+			var calls atomic.Int32
+			translate := func(zpk string) (string, error) {
+				if calls.Add(1) == 1 {
+					close(started)
+					<-rotated
+				}
+				key, _ := loadKey()
+				now := time.Now()
+				active, _ := key.ActiveZPK(now)
+				previous, _ := key.GraceZPK(now)
+				if zpk != active && zpk != previous {
+					return "", fmt.Errorf("hsm -> zpk %s: %w", zpk, hsm.ErrKeyNotFound)
+				}
+				return "PIN-" + zpk, nil
+			}
+
+			result := make(chan string, 1)
+			go func() {
+				pinBlock, err := h.translateUnderZPK(context.Background(), u, loadKey, translate)
+				assert.NoError(t, err)
+				result <- pinBlock
+			}()
+
+			// Rotasi dari gateway selesai saat translate sedang berjalan
+			<-started
+			now := time.Now()
+			mu.Lock()
+			stored.StageZPK("UNEW", "222222", now, now, tt.grace)
+			require.NoError(t, stored.ConfirmZPK("UNEW", now, tt.grace))
+			mu.Unlock()
+			close(rotated)
+
+			select {
+			case pinBlock := <-result:
+				assert.Equal(t, tt.want, pinBlock)
+			case <-time.After(5 * time.Second):
+				t.Fatal("translate tidak selesai")
+			}
+		})
+	}
+}
+
+func TestParsePinFormats(t *testing.T) {
+	cfg, err := parsePinFormats("ISO1", "ISO3, bank_x=ISO4")
+	require.NoError(t, err)
+	assert.Equal(t, hsm.PinFormatISO1, cfg.terminal)
+	assert.Equal(t, hsm.PinFormatISO3, cfg.forHost(DefaultUpstreamName))
+	assert.Equal(t, hsm.PinFormatISO4, cfg.forHost("bank_x"))
+
+	cfg, err = parsePinFormats("", "")
+	require.NoError(t, err)
+	assert.Equal(t, hsm.PinFormatISO0, cfg.forHost("bank_x"))
+
+	_, err = parsePinFormats("ISO0", "bank_x=ISO2")
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+)
+
+// autoReverse mengirim 0420 untuk transaksi 0200 yang tidak mendapat respons host
+// (timeout atau koneksi host putus), karena host mungkin sudah memproses transaksi.
+// Jika 0420 tidak langsung di-approve, reversal dilanjutkan lewat antrian SAF.
+func (h *Handler) autoReverse(u *upstream, idTrx int64, reason string) {
+	if !h.Config.AutoReversal || idTrx == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	trx, err := repo.TransactionHistoryGetByID(ctx, h.db, idTrx)
+	if err != nil {
+		h.Log.Errorf("auto reversal -> get transaction %d: %v", idTrx, err)
+		return
+	}
+
+	h.Log.Warnf("auto reversal -> transaction %d tid %s stan %s: %s", idTrx, trx.Tid, trx.Stan, reason)
+	h.updateReversalStatus(idTrx, repo.ReversalStatusPending, "")
+
+	stanHost, err := h.takeStan()
+	if err != nil {
+		h.Log.Errorf("auto reversal -> transaction %d next stan: %v", idTrx, err)
+		return
+	}
+
+	isoSend, err := iso.CreateIsoReversal(trx.IsoReq, trx.StanHost, stanHost, trx.RrnHost)
+	if err != nil {
+		h.Log.Errorf("auto reversal -> transaction %d create iso reversal: %v", idTrx, err)
+		h.updateReversalStatus(idTrx, repo.ReversalStatusFailed, "")
+		return
+	}
+
+	var rc string
+	if s := u.pickSession(h.Config.HostBalance); s != nil {
+		isomessageRes, err := h.hostRequest(s, isoSend, time.Duration(h.Config.TimeoutTrx)*time.Second)
+		if err != nil {
+			h.Log.Warnf("auto reversal -> transaction %d send 0420: %v", idTrx, err)
+		} else {
+			rc, _ = isomessageRes.GetString(39)
+		}
+	}
+
+	if rc == "00" {
+		h.Log.Infof("auto reversal -> transaction %d reversed", idTrx)
+		h.updateReversalStatus(idTrx, repo.ReversalStatusReversed, rc)
+		return
+	}
+
+	err = h.enqueueReversalAdvice(u, 0, idTrx, trx.Tid, fmt.Sprintf("%06s", trx.Stan), isoSend)
+	if err != nil {
+		h.Log.Errorf("auto reversal -> transaction %d: %v", idTrx, err)
+		h.updateReversalStatus(idTrx, repo.ReversalStatusFailed, rc)
+		return
+	}
+	h.updateReversalStatus(idTrx, repo.ReversalStatusSAF, rc)
+}
+
+func (h *Handler) updateReversalStatus(idTrx int64, status, rc string) {
+	now := time.Now()
+	data := &repo.TransactionHistory{ID: idTrx, ReversalStatus: status, ReversalRC: rc, UpdatedAt: now}
+	if status == repo.ReversalStatusReversed {
+		data.ReversedAt = &now
+	}
+
+	if err := repo.TransactionHistoryUpdateReversal(context.Background(), h.db, data); err != nil {
+		h.Log.Errorf("update reversal status transaction %d: %v", idTrx, err)
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/robfig/cron/v3"
+)
+
+// RunKeyRotation meminta ZPK baru ke setiap host sesuai jadwal ZPK_ROTATION_CRON
+// sampai ctx dibatalkan.
+func (h *Handler) RunKeyRotation(ctx context.Context) {
+	if h.Config.ZpkRotationCron == "" {
+		return
+	}
+
+	c := cron.New()
+	// Jadwal sudah divalidasi di NewHandler
+	c.AddFunc(h.Config.ZpkRotationCron, h.rotateZPK)
+	c.Start()
+	h.Log.Infof("ZPK rotation scheduled at %q", h.Config.ZpkRotationCron)
+
+	<-ctx.Done()
+	<-c.Stop().Done()
+	h.Log.Info("ZPK rotation scheduler stopped.")
+}
+
+func (h *Handler) rotateZPK() {
+	list, err := h.selectUpstreams("")
+	if err != nil {
+		h.Log.Errorf("zpk rotation -> %v", err)
+		return
+	}
+
+	for _, u := range list {
+		s := u.pickSession(h.Config.HostBalance)
+		if s == nil {
+			h.Log.Warnf("zpk rotation -> host %s not connected, skipped", u.Name)
+			continue
+		}
+		if err := h.requestNewKey(s, repo.KeySourceScheduled); err != nil {
+			h.Log.Errorf("zpk rotation -> %v", err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"gorm.io/gorm"
+)
+
+const safBatchSize = 50
+
+// parseSafSchedule membaca jeda antar pengiriman ulang dalam detik, misal "60,120,300".
+// Jika percobaan melebihi jumlah jeda, jeda terakhir dipakai terus.
+func parseSafSchedule(schedule string) ([]time.Duration, error) {
+	var delays []time.Duration
+	for _, part := range strings.Split(schedule, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(part)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid saf schedule %q", schedule)
+		}
+		delays = append(delays, time.Duration(seconds)*time.Second)
+	}
+	if len(delays) == 0 {
+		return nil, fmt.Errorf("invalid saf schedule %q", schedule)
+	}
+	return delays, nil
+}
+
+// safDelay mengembalikan jeda sebelum pengiriman berikutnya setelah attempts kali kirim.
+func safDelay(schedule []time.Duration, attempts int) time.Duration {
+	if attempts >= len(schedule) {
+		return schedule[len(schedule)-1]
+	}
+	return schedule[attempts]
+}
+
+// enqueueReversalAdvice menyimpan 0420 yang tidak dijawab host ke antrian SAF agar
+// dikirim ulang sebagai 0421 sampai host membalas 0430 approved.
+// reversalOf diisi id transaksi 0200 jika antrian berasal dari reversal otomatis.
+func (h *Handler) enqueueReversalAdvice(u *upstream, idTrx, reversalOf int64, tid, stanClient string, msg []byte) error {
+	stanHost := ""
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	if err := isomessage.Unpack(msg); err == nil {
+		stanHost, _ = isomessage.GetString(11)
+	}
+
+	now := time.Now()
+	err := repo.SafSave(context.Background(), h.db, &repo.SafQueue{
+		TransactionID:   idTrx,
+		ReversalOf:      reversalOf,
+		UpstreamName:    u.Name,
+		UpstreamAddress: u.Address,
+		Tid:             tid,
+		StanClient:      stanClient,
+		StanHost:        stanHost,
+		IsoReq:          string(msg),
+		Status:          repo.SafStatusPending,
+		NextAttemptAt:   now.Add(safDelay(h.safSchedule, 0)),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue reversal advice -> %w", err)
+	}
+
+	h.Log.Infof("reversal advice tid %s stan %s queued for repeat to %s", tid, stanClient, u.Name)
+	return nil
+}
+
+// reversalAdvicePending mengecek apakah reversal terminal masih ada di antrian SAF,
+// sehingga pengiriman berikutnya harus berupa repeat (0421).
+func (h *Handler) reversalAdvicePending(tid, stanClient string) (bool, error) {
+	_, err := repo.SafGetOpen(context.Background(), h.db, tid, stanClient)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RunSAF mengirim ulang antrian reversal advice yang sudah jatuh tempo.
+func (h *Handler) RunSAF(ctx context.Context) {
+	h.Log.Info("Starting store and forward goroutine.")
+
+	if h.db == nil {
+		return
+	}
+
+	if reset, err := repo.SafResetSending(ctx, h.db); err != nil {
+		h.Log.Errorf("saf -> reset sending: %v", err)
+	} else if reset > 0 {
+		h.Log.Warnf("saf -> %d advice left in sending state, requeued", reset)
+	}
+
+	interval := time.Duration(h.Config.SafPollInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			safs, err := repo.SafGetDue(ctx, h.db, time.Now(), safBatchSize)
+			if err != nil {
+				h.Log.Errorf("saf -> get due advice: %v", err)
+				continue
+			}
+			for _, saf := range safs {
+				h.processSaf(saf)
+			}
+		case <-ctx.Done():
+			h.Log.Info("Store and forward goroutine received context done signal. Stopping.")
+			return
+		}
+	}
+}
+
+func (h *Handler) processSaf(saf repo.SafQueue) {
+	ctx := context.Background()
+
+	claimed, err := repo.SafClaim(ctx, h.db, saf.ID)
+	if err != nil {
+		h.Log.Errorf("saf -> claim %d: %v", saf.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	u := h.getUpstream(saf.UpstreamName, saf.UpstreamAddress)
+	s := u.pickSession(h.Config.HostBalance)
+	if s == nil {
+		// Host sedang down, tidak dihitung sebagai percobaan
+		saf.Status = repo.SafStatusPending
+		saf.NextAttemptAt = time.Now().Add(safDelay(h.safSchedule, 0))
+		saf.LastError = "host not connected"
+		if err := repo.SafUpdateAttempt(ctx, h.db, &saf); err != nil {
+			h.Log.Errorf("saf -> update %d: %v", saf.ID, err)
+		}
+		return
+	}
+
+	rc, sendErr := h.sendReversalAdvice(s, &saf)
+	saf.Attempts++
+	saf.LastRC = rc
+	saf.LastError = ""
+	if sendErr != nil {
+		saf.LastError = sendErr.Error()
+	}
+
+	now := time.Now()
+	switch {
+	case sendErr == nil && rc == "00":
+		saf.Status = repo.SafStatusApproved
+		saf.CompletedAt = &now
+		h.Log.Infof("saf -> reversal advice tid %s stan %s approved after %d attempt", saf.Tid, saf.StanClient, saf.Attempts)
+
+		if saf.TransactionID != 0 {
+			err := repo.TransactionHistoryUpdateResponse(ctx, h.db, &repo.TransactionHistory{
+				ID:           saf.TransactionID,
+				ResponseCode: rc,
+				UpdatedAt:    now,
+			})
+			if err != nil {
+				h.Log.Errorf("saf -> update transaction %d: %v", saf.TransactionID, err)
+			}
+		}
+		if saf.ReversalOf != 0 {
+			h.updateReversalStatus(saf.ReversalOf, repo.ReversalStatusReversed, rc)
+		}
+	case saf.Attempts >= h.Config.SafMaxRetry:
+		saf.Status = repo.SafStatusDead
+		saf.CompletedAt = &now
+		h.Log.Errorf("saf -> reversal advice tid %s stan %s moved to dead letter after %d attempt, last rc %q error %q",
+			saf.Tid, saf.StanClient, saf.Attempts, saf.LastRC, saf.LastError)
+		if saf.ReversalOf != 0 {
+			h.updateReversalStatus(saf.ReversalOf, repo.ReversalStatusFailed, rc)
+		}
+	default:
+		saf.Status = repo.SafStatusPending
+		saf.NextAttemptAt = now.Add(safDelay(h.safSchedule, saf.Attempts))
+		h.Log.Warnf("saf -> reversal advice tid %s stan %s attempt %d not approved (rc %q, error %q), next at %s",
+			saf.Tid, saf.StanClient, saf.Attempts, saf.LastRC, saf.LastError, saf.NextAttemptAt.Format(time.DateTime))
+	}
+
+	if err := repo.SafUpdateAttempt(ctx, h.db, &saf); err != nil {
+		h.Log.Errorf("saf -> update %d: %v", saf.ID, err)
+	}
+}
+
+// sendReversalAdvice mengirim isi antrian sebagai 0421 dengan STAN host baru dan
+// mengembalikan response code dari host.
+func (h *Handler) sendReversalAdvice(s *hostSession, saf *repo.SafQueue) (string, error) {
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	if err := isomessage.Unpack([]byte(saf.IsoReq)); err != nil {
+		return "", fmt.Errorf("unpack iso: %w", err)
+	}
+
+	stanHost, err := h.takeStan()
+	if err != nil {
+		return "", fmt.Errorf("next stan: %w", err)
+	}
+	saf.StanHost = stanHost
+
+	isomessage.MTI("0421")
+	if err := isomessage.Field(11, stanHost); err != nil {
+		return "", fmt.Errorf("set stan: %w", err)
+	}
+
+	isoSend, err := isomessage.Pack()
+	if err != nil {
+		return "", fmt.Errorf("pack iso: %w", err)
+	}
+
+	isomessageRes, err := h.hostRequest(s, isoSend, time.Duration(h.Config.TimeoutTrx)*time.Second)
+	if err != nil {
+		return "", err
+	}
+
+	return isomessageRes.GetString(39)
+}
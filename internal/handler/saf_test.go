@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSafSchedule(t *testing.T) {
+	schedule, err := parseSafSchedule("60, 120,300")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 5 * time.Minute}, schedule)
+
+	for _, invalid := range []string{"", " , ", "60,abc", "0", "-10"} {
+		_, err := parseSafSchedule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSafDelay(t *testing.T) {
+	schedule := []time.Duration{time.Minute, 2 * time.Minute, 5 * time.Minute}
+
+	assert.Equal(t, time.Minute, safDelay(schedule, 0))
+	assert.Equal(t, 2*time.Minute, safDelay(schedule, 1))
+	assert.Equal(t, 5*time.Minute, safDelay(schedule, 2))
+	assert.Equal(t, 5*time.Minute, safDelay(schedule, 10), "jeda terakhir dipakai terus")
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+)
+
+// hostSpecConfig adalah versi ISO dan spec di sisi wire per host. Di dalam
+// gateway pesan host selalu Spec87 versi 1987, konversi hanya terjadi saat
+// tulis dan baca socket.
+type hostSpecConfig struct {
+	host     *iso8583.MessageSpec            // default semua host
+	hosts    map[string]*iso8583.MessageSpec // per nama upstream
+	version  iso.Version
+	versions map[string]iso.Version
+}
+
+// parseHostSpecs membaca ISO_SPEC_HOST dan ISO_VERSION_HOST, daftar dipisah
+// koma dengan entri tanpa nama sebagai default. Spec berupa nama spec bawaan
+// atau path file JSON, misal "spec87,bank_x=/etc/danus/bank_x.json", versi
+// misal "87,bank_x=93".
+func parseHostSpecs(specs, versions string) (hostSpecConfig, error) {
+	cfg := hostSpecConfig{
+		host:     iso.Spec87,
+		hosts:    make(map[string]*iso8583.MessageSpec),
+		version:  iso.Version87,
+		versions: make(map[string]iso.Version),
+	}
+	for _, part := range strings.Split(specs, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, source, named := strings.Cut(part, "=")
+		if !named {
+			source = name
+		}
+		spec, err := iso.ResolveSpec(source)
+		if err != nil {
+			return cfg, fmt.Errorf("iso spec host: %w", err)
+		}
+		if named {
+			cfg.hosts[strings.TrimSpace(name)] = spec
+		} else {
+			cfg.host = spec
+		}
+	}
+
+	for _, part := range strings.Split(versions, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, named := strings.Cut(part, "=")
+		if !named {
+			value = name
+		}
+		version, err := iso.ParseVersion(strings.TrimSpace(value))
+		if err != nil {
+			return cfg, fmt.Errorf("iso version host: %w", err)
+		}
+		if _, err := iso.VersionSpec(version); err != nil {
+			return cfg, fmt.Errorf("iso version host: %w", err)
+		}
+		if named {
+			cfg.versions[strings.TrimSpace(name)] = version
+		} else {
+			cfg.version = version
+		}
+	}
+	return cfg, nil
+}
+
+func (c hostSpecConfig) versionFor(name string) iso.Version {
+	if version, ok := c.versions[name]; ok {
+		return version
+	}
+	if c.version == 0 {
+		return iso.Version87
+	}
+	return c.version
+}
+
+// specFor mengembalikan spec wire host. Host versi selain 1987 tanpa spec
+// sendiri memakai spec bawaan versinya, misal Spec93.
+func (c hostSpecConfig) specFor(name string) *iso8583.MessageSpec {
+	if spec, ok := c.hosts[name]; ok {
+		return spec
+	}
+	if version := c.versionFor(name); version != iso.Version87 {
+		spec, _ := iso.VersionSpec(version)
+		return spec
+	}
+	if c.host == nil {
+		return iso.Spec87
+	}
+	return c.host
+}
+
+// loadTerminalSpec memasang ISO_SPEC_TERMINAL sebagai spec kanal terminal.
+func loadTerminalSpec(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	spec, err := iso.ResolveSpec(value)
+	if err != nil {
+		return fmt.Errorf("iso spec terminal: %w", err)
+	}
+	iso.SetTerminalSpec(spec)
+	return nil
+}
+
+// wireSpec mengembalikan spec pesan di koneksi ke host ini.
+func (u *upstream) wireSpec() *iso8583.MessageSpec {
+	if u.spec == nil {
+		return iso.Spec87
+	}
+	return u.spec
+}
+
+// messageVersion mengembalikan versi ISO host, default 1987.
+func (u *upstream) messageVersion() iso.Version {
+	if u.version == 0 {
+		return iso.Version87
+	}
+	return u.version
+}
+
+// toWire mengubah pesan Spec87 ke versi dan spec host sebelum ditulis ke socket.
+func (u *upstream) toWire(msg []byte) ([]byte, error) {
+	version := u.messageVersion()
+	msg, err := iso.ToVersion(msg, version, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", u.Name, err)
+	}
+	spec, err := iso.VersionSpec(version)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", u.Name, err)
+	}
+	out, err := iso.Convert(msg, spec, u.wireSpec())
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", u.Name, err)
+	}
+	return out, nil
+}
+
+// fromWire mengubah pesan yang dibaca dari host ke Spec87 versi 1987.
+func (u *upstream) fromWire(msg []byte) ([]byte, error) {
+	version := u.messageVersion()
+	spec, err := iso.VersionSpec(version)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", u.Name, err)
+	}
+	msg, err = iso.Convert(msg, u.wireSpec(), spec)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", u.Name, err)
+	}
+	out, err := iso.FromVersion(msg, version)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", u.Name, err)
+	}
+	return out, nil
+}
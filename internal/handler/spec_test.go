@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHostSpecs(t *testing.T) {
+	cfg, err := parseHostSpecs("", "")
+	require.NoError(t, err)
+	assert.Same(t, iso.Spec87, cfg.specFor("bank_x"))
+
+	path := filepath.Join("..", "..", "pkg", "iso", "specs", "spec87x.json")
+	cfg, err = parseHostSpecs("spec87hex, bank_x="+path, "")
+	require.NoError(t, err)
+	assert.Same(t, iso.Spec87Hex, cfg.specFor(DefaultUpstreamName))
+	assert.NotNil(t, cfg.specFor("bank_x"))
+	assert.NotSame(t, iso.Spec87Hex, cfg.specFor("bank_x"))
+
+	_, err = parseHostSpecs("bank_x=/tidak/ada.json", "")
+	assert.Error(t, err)
+
+	// Host 93 tanpa spec sendiri memakai Spec93
+	cfg, err = parseHostSpecs("bank_y="+path, "87,bank_x=93,bank_y=1993")
+	require.NoError(t, err)
+	assert.Equal(t, iso.Version87, cfg.versionFor(DefaultUpstreamName))
+	assert.Same(t, iso.Spec87, cfg.specFor(DefaultUpstreamName))
+	assert.Equal(t, iso.Version93, cfg.versionFor("bank_x"))
+	assert.Same(t, iso.Spec93, cfg.specFor("bank_x"))
+	assert.Equal(t, iso.Version93, cfg.versionFor("bank_y"))
+	assert.NotSame(t, iso.Spec93, cfg.specFor("bank_y"))
+
+	_, err = parseHostSpecs("", "bank_x=2003")
+	assert.ErrorIs(t, err, iso.ErrUnsupportedVersion)
+	_, err = parseHostSpecs("", "88")
+	assert.Error(t, err)
+}
+
+func TestUpstreamWireSpec(t *testing.T) {
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	isomessage.MTI("0200")
+	require.NoError(t, isomessage.Field(3, "000000"))
+	require.NoError(t, isomessage.Field(11, "000045"))
+	require.NoError(t, isomessage.Field(41, "TID00001"))
+	msg, err := isomessage.Pack()
+	require.NoError(t, err)
+
+	// Tanpa spec host pesan tidak diubah
+	u := &upstream{Name: DefaultUpstreamName}
+	out, err := u.toWire(msg)
+	require.NoError(t, err)
+	assert.Equal(t, msg, out)
+
+	u = &upstream{Name: "bank_x", spec: iso.Spec87Hex}
+	wire, err := u.toWire(msg)
+	require.NoError(t, err)
+	assert.NotEqual(t, string(msg), string(wire))
+
+	back, err := u.fromWire(wire)
+	require.NoError(t, err)
+	assert.Equal(t, string(msg), string(back))
+
+	_, err = u.fromWire([]byte("0200"))
+	assert.ErrorContains(t, err, "host bank_x")
+
+	// Host 93: MTI 1200 dan function code bit 24 di wire, kembali 0200 di dalam
+	u = &upstream{Name: "bank_y", spec: iso.Spec93, version: iso.Version93}
+	wire, err = u.toWire(msg)
+	require.NoError(t, err)
+	wireMessage := iso8583.NewMessage(iso.Spec93)
+	require.NoError(t, wireMessage.Unpack(wire))
+	mti, _ := wireMessage.GetMTI()
+	assert.Equal(t, "1200", mti)
+	function, _ := wireMessage.GetString(24)
+	assert.Equal(t, "200", function)
+
+	back, err = u.fromWire(wire)
+	require.NoError(t, err)
+	assert.Equal(t, string(msg), string(back))
+}
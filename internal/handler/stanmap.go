@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/moov-io/iso8583"
+	"gorm.io/gorm"
+)
+
+// stanMemoryWindow adalah lama mapping STAN disimpan di memori. Setelah itu
+// mapping hanya dicari di tabel stan_mapping sampai STAN_MAP_TTL habis.
+const stanMemoryWindow = 2 * time.Minute
+
+// putStanMapping menyimpan mapping STAN host -> STAN client ke DB lalu ke memori.
+func (h *Handler) putStanMapping(stanHost, mti string, data StanManage) error {
+	if h.db != nil {
+		err := repo.StanMappingSave(context.Background(), h.db, &repo.StanMapping{
+			StanHost:   stanHost,
+			StanClient: data.StanClient,
+			Tid:        data.Tid,
+			Mti:        mti,
+			CreatedAt:  data.Duration,
+		})
+		if err != nil {
+			return fmt.Errorf("save stan mapping: %w", err)
+		}
+	}
+
+	h.mu.Lock()
+	h.stanManage[stanHost] = data
+	h.mu.Unlock()
+
+	return nil
+}
+
+// getStanMapping mencari mapping di memori, jika tidak ada (misal setelah restart
+// atau respons terlambat) dicari di DB.
+func (h *Handler) getStanMapping(stanHost string) (StanManage, bool) {
+	h.mu.Lock()
+	data, ok := h.stanManage[stanHost]
+	h.mu.Unlock()
+	if ok || h.db == nil {
+		return data, ok
+	}
+
+	mapping, err := repo.StanMappingGet(context.Background(), h.db, stanHost)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			h.Log.Errorf("get stan mapping %s: %v", stanHost, err)
+		}
+		return StanManage{}, false
+	}
+
+	return StanManage{StanClient: mapping.StanClient, Tid: mapping.Tid, Duration: mapping.CreatedAt}, true
+}
+
+// popStanMapping mengambil lalu menghapus mapping.
+func (h *Handler) popStanMapping(stanHost string) (StanManage, bool) {
+	data, ok := h.getStanMapping(stanHost)
+	if ok {
+		h.dropStanMapping(stanHost)
+	}
+	return data, ok
+}
+
+func (h *Handler) dropStanMapping(stanHost string) {
+	h.mu.Lock()
+	delete(h.stanManage, stanHost)
+	h.mu.Unlock()
+
+	if h.db == nil {
+		return
+	}
+	if err := repo.StanMappingDelete(context.Background(), h.db, stanHost); err != nil {
+		h.Log.Errorf("delete stan mapping %s: %v", stanHost, err)
+	}
+}
+
+// loadStanMapping memuat kembali mapping yang masih baru ke memori saat startup.
+func (h *Handler) loadStanMapping() error {
+	mappings, err := repo.StanMappingGetSince(context.Background(), h.db, time.Now().Add(-stanMemoryWindow))
+	if err != nil {
+		return fmt.Errorf("load stan mapping: %w", err)
+	}
+
+	h.mu.Lock()
+	for _, mapping := range mappings {
+		h.stanManage[mapping.StanHost] = StanManage{StanClient: mapping.StanClient, Tid: mapping.Tid, Duration: mapping.CreatedAt}
+	}
+	h.mu.Unlock()
+
+	if len(mappings) > 0 {
+		h.Log.Infof("rehydrated %d in-flight stan mapping", len(mappings))
+	}
+	return nil
+}
+
+// saveOrphanResponse mencatat respons host yang tidak ditunggu oleh request manapun.
+func (h *Handler) saveOrphanResponse(s *hostSession, stanHost string, isomessage *iso8583.Message, raw []byte) {
+	mti, _ := isomessage.GetMTI()
+	rc, _ := isomessage.GetString(39)
+	tid, _ := isomessage.GetString(41)
+
+	data, ok := h.popStanMapping(stanHost)
+	if ok && data.Tid != "" {
+		tid = data.Tid
+	}
+
+	h.Log.Warnf("orphan host response from %s mti %s stan %s (client stan %s) rc %s", s, mti, stanHost, data.StanClient, rc)
+
+	if h.db == nil {
+		return
+	}
+	err := repo.OrphanResponseSave(context.Background(), h.db, &repo.OrphanResponse{
+		Upstream:     s.String(),
+		Mti:          mti,
+		StanHost:     stanHost,
+		StanClient:   data.StanClient,
+		Tid:          tid,
+		ResponseCode: rc,
+		IsoRes:       string(raw),
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		h.Log.Errorf("save orphan response stan %s: %v", stanHost, err)
+	}
+}
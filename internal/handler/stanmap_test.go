@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alfianX/danus-h2h/internal/sequence"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func packTestMessage(t *testing.T, mti string, fields map[int]string) []byte {
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	isomessage.MTI(mti)
+	for id, value := range fields {
+		require.NoError(t, isomessage.Field(id, value))
+	}
+	msg, err := isomessage.Pack()
+	require.NoError(t, err)
+	return msg
+}
+
+func TestStanMappingRoundTrip(t *testing.T) {
+	gen, err := sequence.NewStanGenerator(sequence.NewFileReserver(filepath.Join(t.TempDir(), "stan.json")), sequence.Options{})
+	require.NoError(t, err)
+
+	h := &Handler{stanGen: gen, stanManage: make(map[string]StanManage), Log: logrus.New()}
+
+	req := packTestMessage(t, "0200", map[int]string{3: "000000", 11: "000123", 41: "TID00001"})
+	_, stanHost, err := h.changeStanFromClient(req)
+	require.NoError(t, err)
+	assert.Equal(t, "000000000001", stanHost)
+
+	data, ok := h.getStanMapping(stanHost)
+	require.True(t, ok)
+	assert.Equal(t, "000123", data.StanClient)
+	assert.Equal(t, "TID00001", data.Tid)
+
+	res := packTestMessage(t, "0210", map[int]string{11: stanHost, 39: "00", 41: "TID00001"})
+	toClient, err := h.changeStanFromHost(res)
+	require.NoError(t, err)
+
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	require.NoError(t, isomessage.Unpack(toClient))
+	stan, err := isomessage.GetString(11)
+	require.NoError(t, err)
+	assert.Equal(t, "123", stan)
+
+	_, ok = h.getStanMapping(stanHost)
+	assert.False(t, ok, "mapping dihapus setelah respons host")
+
+	_, err = h.changeStanFromHost(res)
+	assert.Error(t, err)
+}
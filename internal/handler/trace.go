@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"net"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/alfianX/danus-h2h/pkg/tracing"
+	"github.com/moov-io/iso8583"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startTrace memulai root span untuk satu pesan terminal (hex spec terminal tanpa TPDU).
+// Trace ID diturunkan dari TID+STAN, context disimpan per koneksi seperti TPDU
+// agar sendBackHandler dan log error bisa memakainya.
+func (h *Handler) startTrace(conn net.Conn, msgHex string) context.Context {
+	isomessage := iso8583.NewMessage(iso.TerminalSpec())
+	var ctx context.Context
+	var span trace.Span
+	if err := isomessage.Unpack([]byte(msgHex)); err == nil {
+		mti, _ := isomessage.GetMTI()
+		tid, _ := isomessage.GetString(41)
+		stan, _ := isomessage.GetString(11)
+		ctx, span = tracing.StartTransaction(context.Background(), tid, stan, "terminal.request",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("iso.mti", mti),
+				attribute.String("iso.tid", tid),
+				attribute.String("iso.stan", stan),
+			))
+	} else {
+		ctx, span = tracing.Start(context.Background(), "terminal.request", trace.WithSpanKind(trace.SpanKindServer))
+	}
+	span.SetAttributes(attribute.String("net.peer", conn.RemoteAddr().String()))
+
+	h.traceConn.Store(conn, ctx)
+	return ctx
+}
+
+func (h *Handler) connContext(conn net.Conn) context.Context {
+	if conn != nil {
+		if value, ok := h.traceConn.Load(conn); ok {
+			return value.(context.Context)
+		}
+	}
+	return context.Background()
+}
+
+func (h *Handler) connLog(conn net.Conn) *logrus.Entry {
+	return h.Log.WithFields(tracing.LogFields(h.connContext(conn)))
+}
+
+// endTrace menutup root span pesan terakhir di koneksi ini.
+func (h *Handler) endTrace(conn net.Conn) {
+	value, ok := h.traceConn.LoadAndDelete(conn)
+	if !ok {
+		return
+	}
+	trace.SpanFromContext(value.(context.Context)).End()
+}
+
+func spanError(span trace.Span, err error, description string) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.SetStatus(codes.Error, description)
+}
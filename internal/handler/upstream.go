@@ -0,0 +1,485 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/fieldmap"
+	"github.com/alfianX/danus-h2h/pkg/framer"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+)
+
+const (
+	DefaultUpstreamName     = "default"
+	MaxReconnectBackoff     = 60 * time.Second
+	HostDialTimeout         = 10 * time.Second
+	BalanceRoundRobin       = "round-robin"
+	BalanceLeastOutstanding = "least-outstanding"
+)
+
+var ErrHostDisconnected = errors.New("host disconnected")
+
+// hostConnectDelay adalah jeda sebelum session mulai menghubungi host.
+var hostConnectDelay = 5 * time.Second
+
+// upstream adalah satu host tujuan (bank acquirer) beserta session yang dikelolanya.
+// Upstream dikenali dari nama service karena kunci, spec, versi dan framing host
+// dicari berdasarkan nama. Beberapa service dengan nama sama memakai satu upstream.
+// Address boleh berisi beberapa endpoint dipisah koma, endpoint pertama adalah primary
+// dan sisanya standby sesuai urutan.
+type upstream struct {
+	Name      string
+	Address   string
+	Endpoints []string
+	spec      *iso8583.MessageSpec // spec wire host, nil berarti Spec87
+	version   iso.Version          // versi ISO host, 0 berarti 1987
+	framer    framer.Framer        // framing koneksi host, nil berarti binary2
+	sessions  []*hostSession
+	next      atomic.Uint32
+}
+
+// hostSession adalah satu koneksi TCP ke host. Satu upstream punya HOST_SESSIONS
+// session dan masing-masing punya read loop (hostHandler) sendiri.
+type hostSession struct {
+	upstream    *upstream
+	ID          int
+	connLock    sync.Mutex
+	conn        net.Conn
+	active      int // index endpoint yang sedang terhubung
+	outstanding atomic.Int64
+}
+
+// pendingResponse adalah isi responseMap: channel respons, session dan koneksi host
+// tempat request dikirim, agar request bisa digagalkan saat koneksi itu putus.
+type pendingResponse struct {
+	ch       chan HostResponse
+	session  *hostSession
+	hostConn net.Conn
+}
+
+// deliver tidak pernah blocking, respons kedua untuk STAN yang sama dibuang.
+func (p *pendingResponse) deliver(response HostResponse) {
+	select {
+	case p.ch <- response:
+	default:
+	}
+}
+
+func parseEndpoints(address string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(address, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// pickSession memilih session yang sedang terhubung, round-robin atau yang paling
+// sedikit request menunggu respons (least-outstanding). Nil jika tidak ada.
+func (u *upstream) pickSession(balance string) *hostSession {
+	if balance == BalanceLeastOutstanding {
+		var best *hostSession
+		for _, s := range u.sessions {
+			if s.getConn() == nil {
+				continue
+			}
+			if best == nil || s.outstanding.Load() < best.outstanding.Load() {
+				best = s
+			}
+		}
+		return best
+	}
+
+	n := len(u.sessions)
+	start := int(u.next.Add(1))
+	for i := 0; i < n; i++ {
+		s := u.sessions[(start+i)%n]
+		if s.getConn() != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *hostSession) String() string {
+	return fmt.Sprintf("%s#%d", s.upstream.Name, s.ID)
+}
+
+func (s *hostSession) getConn() net.Conn {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	return s.conn
+}
+
+// swapConn memasang koneksi baru dan mengembalikan koneksi lama.
+func (s *hostSession) swapConn(index int, conn net.Conn) net.Conn {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+
+	old := s.conn
+	s.conn = conn
+	s.active = index
+	return old
+}
+
+// dropConn menutup conn. Hasilnya true jika conn masih koneksi aktif session.
+func (s *hostSession) dropConn(conn net.Conn) bool {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+
+	conn.Close()
+	if s.conn != conn {
+		return false
+	}
+	s.conn = nil // Set nil agar goroutine lain tahu koneksi sudah putus
+	return true
+}
+
+// getUpstream mengembalikan upstream dengan nama tertentu. Session upstream baru
+// langsung dihubungkan ke host di goroutine terpisah.
+func (h *Handler) getUpstream(name, address string) *upstream {
+	h.hostsLock.Lock()
+	defer h.hostsLock.Unlock()
+
+	if h.hosts == nil {
+		h.hosts = make(map[string]*upstream)
+	}
+
+	u, ok := h.hosts[name]
+	if ok {
+		if u.Address != address {
+			h.Log.Warnf("upstream %s already uses %s, address %s ignored", name, u.Address, address)
+		}
+		return u
+	}
+
+	u = &upstream{Name: name, Address: address, Endpoints: parseEndpoints(address), spec: h.hostSpecs.specFor(name), version: h.hostSpecs.versionFor(name), framer: h.hostFraming.framerFor(name)}
+	for i := 0; i < max(1, h.Config.HostSessions); i++ {
+		u.sessions = append(u.sessions, &hostSession{upstream: u, ID: i + 1})
+	}
+	h.hosts[name] = u
+
+	for _, s := range u.sessions {
+		go h.connectSession(s)
+	}
+
+	return u
+}
+
+func (h *Handler) defaultUpstream() *upstream {
+	return h.getUpstream(DefaultUpstreamName, h.Config.HostAddress)
+}
+
+// upstreams mengembalikan salinan daftar upstream yang sudah terdaftar.
+func (h *Handler) upstreams() []*upstream {
+	h.hostsLock.Lock()
+	defer h.hostsLock.Unlock()
+
+	list := make([]*upstream, 0, len(h.hosts))
+	for _, u := range h.hosts {
+		list = append(list, u)
+	}
+	return list
+}
+
+// resolveUpstream menentukan host tujuan dari prefix PAN/BIN atau processing code
+// berdasarkan tabel services. Jika tidak ada yang cocok, dipakai HOST_ADDRESS.
+func (h *Handler) resolveUpstream(isomessage *iso8583.Message) (*upstream, error) {
+	if h.db == nil {
+		return h.routeUpstream(nil, isomessage)
+	}
+
+	services, err := repo.ServiceGetAll(context.Background(), h.db)
+	if err != nil {
+		return nil, fmt.Errorf("resolve upstream -> get services: %w", err)
+	}
+	return h.routeUpstream(services, isomessage)
+}
+
+// routeUpstream memilih upstream dari daftar services, lihat repo.MatchService.
+// Pesan network management selalu ke upstream default.
+func (h *Handler) routeUpstream(services []repo.Services, isomessage *iso8583.Message) (*upstream, error) {
+	mti, err := isomessage.GetMTI()
+	if err != nil {
+		return nil, fmt.Errorf("resolve upstream -> get mti: %w", err)
+	}
+
+	if iso.MTI(mti).IsNetwork() || len(services) == 0 {
+		return h.defaultUpstream(), nil
+	}
+
+	card, err := iso.ReadCard(isomessage)
+	if err != nil {
+		return nil, fmt.Errorf("resolve upstream -> %w", err)
+	}
+	procode, err := isomessage.GetString(3)
+	if err != nil {
+		return nil, fmt.Errorf("resolve upstream -> unpack procode: %w", err)
+	}
+
+	service, ok := repo.MatchService(services, card.PAN, procode)
+	if !ok {
+		return h.defaultUpstream(), nil
+	}
+
+	return h.getUpstream(service.ServiceName, service.ServiceAddress), nil
+}
+
+// dialHost membuka koneksi ke endpoint host, memakai TLS jika HOST_TLS aktif.
+func (h *Handler) dialHost(endpoint string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: HostDialTimeout}
+	if h.hostTLS == nil {
+		return dialer.Dial("tcp", endpoint)
+	}
+
+	tlsConfig, err := h.hostTLS.Config()
+	if err != nil {
+		return nil, fmt.Errorf("dial host -> tls config: %w", err)
+	}
+	return tls.DialWithDialer(dialer, "tcp", endpoint, tlsConfig)
+}
+
+func (h *Handler) registerPending(stan string, s *hostSession, hostConn net.Conn) chan HostResponse {
+	responseChan := make(chan HostResponse, 1)
+	s.outstanding.Add(1)
+	h.responseMap.Store(stan, &pendingResponse{ch: responseChan, session: s, hostConn: hostConn})
+	return responseChan
+}
+
+func (h *Handler) releasePending(stan string) {
+	value, ok := h.responseMap.LoadAndDelete(stan)
+	if !ok {
+		return
+	}
+	if pending, ok := value.(*pendingResponse); ok {
+		pending.session.outstanding.Add(-1)
+	}
+}
+
+// failPending menggagalkan semua request yang masih menunggu respons di hostConn.
+func (h *Handler) failPending(hostConn net.Conn, err error) {
+	h.responseMap.Range(func(key, value any) bool {
+		pending, ok := value.(*pendingResponse)
+		if ok && pending.hostConn == hostConn {
+			h.Log.Warnf("fail pending -> stan %v: %v", key, err)
+			pending.deliver(HostResponse{Err: err})
+		}
+		return true
+	})
+}
+
+func (h *Handler) countPending(hostConn net.Conn) int {
+	count := 0
+	h.responseMap.Range(func(key, value any) bool {
+		pending, ok := value.(*pendingResponse)
+		if ok && pending.hostConn == hostConn {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// writeToHost mengirim pesan Spec87 ke host. Semua pesan ke host lewat sini,
+// termasuk reversal otomatis dan SAF, jadi aturan EMV_TAG_RULES dan
+// FIELD_MAP_FILE host tujuan diterapkan di sini sebelum konversi ke format host.
+func (h *Handler) writeToHost(s *hostSession, hostConn net.Conn, msg []byte) error {
+	msg, err := h.applyEmvRules(s.upstream, msg)
+	if err != nil {
+		return err
+	}
+
+	msg, err = h.mapFields(fieldmap.DirectionRequest, s.upstream, msg)
+	if err != nil {
+		return err
+	}
+
+	msg, err = s.upstream.toWire(msg)
+	if err != nil {
+		return err
+	}
+
+	if h.Config.MacHost {
+		msg, err = h.signHostMessage(context.Background(), s.upstream, msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	msg = []byte(strings.ToUpper(string(msg)))
+	msgSend, err := s.upstream.hostFraming().Encode(framer.Frame{Message: msg})
+	if err != nil {
+		return err
+	}
+
+	h.Log.WithField("debug_tag", "ul_out").Debugf("message to host %s : %s", s, msg)
+
+	_, err = hostConn.Write(msgSend)
+	return err
+}
+
+// hostRequest mengirim pesan ISO (Spec87) yang dibuat oleh gateway sendiri lewat
+// session tertentu dan menunggu balasan dengan STAN yang sama.
+func (h *Handler) hostRequest(s *hostSession, msg []byte, timeout time.Duration) (*iso8583.Message, error) {
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	if err := isomessage.Unpack(msg); err != nil {
+		return nil, fmt.Errorf("host request -> unpack iso: %w", err)
+	}
+	stan, err := isomessage.GetString(11)
+	if err != nil {
+		return nil, fmt.Errorf("host request -> unpack stan: %w", err)
+	}
+	stan = padStanHost(stan)
+
+	hostConn := s.getConn()
+	if hostConn == nil {
+		return nil, fmt.Errorf("host request -> host %s: %w", s, ErrHostDisconnected)
+	}
+
+	responseChan := h.registerPending(stan, s, hostConn)
+	defer h.releasePending(stan)
+
+	if err := h.writeToHost(s, hostConn, msg); err != nil {
+		return nil, fmt.Errorf("host request -> write to host: %w", err)
+	}
+
+	select {
+	case response := <-responseChan:
+		if response.Err != nil {
+			return nil, fmt.Errorf("host request -> response: %w", response.Err)
+		}
+		isomessageRes := iso8583.NewMessage(iso.Spec87)
+		if err := isomessageRes.Unpack(response.Data); err != nil {
+			return nil, fmt.Errorf("host request -> unpack response: %w", err)
+		}
+		return isomessageRes, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("host request -> timeout waiting for host %s", s)
+	}
+}
+
+// probePrimary berjalan selama session memakai endpoint standby. Endpoint primary
+// dicek dengan echo test, dan setelah HOST_PROBE_SUCCESS kali berturut-turut berhasil
+// trafik session dipindahkan kembali ke primary.
+func (h *Handler) probePrimary(s *hostSession, standbyConn net.Conn) {
+	interval := time.Duration(h.Config.HostProbeInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	primary := s.upstream.Endpoints[0]
+	success := 0
+
+	for range ticker.C {
+		if s.getConn() != standbyConn {
+			// Koneksi standby sudah putus, reconnect akan mencoba primary lebih dulu
+			return
+		}
+
+		probeConn, err := h.probeEndpoint(s.upstream, primary)
+		if err != nil {
+			success = 0
+			h.Log.Warnf("probe primary -> host %s endpoint %s: %v", s, primary, err)
+			continue
+		}
+
+		success++
+		h.Log.Infof("probe primary -> host %s endpoint %s echo ok (%d/%d)", s, primary, success, h.Config.HostProbeSuccess)
+		if success < h.Config.HostProbeSuccess {
+			probeConn.Close()
+			continue
+		}
+
+		h.Log.Infof("Switching host %s back to primary endpoint %s", s, primary)
+		old := h.activateConn(s, 0, probeConn)
+		if old != nil {
+			go h.retireConn(old)
+		}
+		return
+	}
+}
+
+// probeEndpoint membuka koneksi baru ke endpoint dan mengirim echo test. Jika host
+// membalas 0810 dengan RC 00, koneksi dikembalikan dalam keadaan terbuka.
+func (h *Handler) probeEndpoint(u *upstream, endpoint string) (net.Conn, error) {
+	conn, err := h.dialHost(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	stanHost, err := h.takeStan()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("next stan: %w", err)
+	}
+
+	isoSend, err := iso.CreateIsoEchoTest(stanHost, h.Config.AcquirerID)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("create iso echo test: %w", err)
+	}
+	isoSend, err = u.toWire(isoSend)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(time.Duration(h.Config.TimeoutTrx) * time.Second))
+	isoSend = []byte(strings.ToUpper(string(isoSend)))
+	if err := u.hostFraming().WriteFrame(conn, framer.Frame{Message: isoSend}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("write echo test: %w", err)
+	}
+
+	frame, err := u.hostFraming().ReadFrame(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read echo test response: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	response, err := u.fromWire(frame.Message)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	if err := isomessage.Unpack(response); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unpack echo test response: %w", err)
+	}
+
+	mti, _ := isomessage.GetMTI()
+	stan, _ := isomessage.GetString(11)
+	responseCode, _ := isomessage.GetString(39)
+	if mti != "0810" || padStanHost(stan) != stanHost || responseCode != "00" {
+		conn.Close()
+		return nil, fmt.Errorf("echo test response mti %s stan %s rc %s", mti, stan, responseCode)
+	}
+
+	return conn, nil
+}
+
+// retireConn menutup koneksi lama setelah switchback, menunggu request yang
+// masih berjalan di koneksi itu selesai paling lama TIMEOUT_TRX.
+func (h *Handler) retireConn(hostConn net.Conn) {
+	deadline := time.Now().Add(time.Duration(h.Config.TimeoutTrx) * time.Second)
+	for h.countPending(hostConn) > 0 && time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+	}
+	hostConn.Close()
+}
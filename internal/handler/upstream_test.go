@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"github.com/alfianX/danus-h2h/pkg/fieldmap"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUpstream(t *testing.T, sessions int) *upstream {
+	u := &upstream{Name: "test", Address: "127.0.0.1:1", Endpoints: []string{"127.0.0.1:1"}}
+	for i := 0; i < sessions; i++ {
+		client, server := net.Pipe()
+		t.Cleanup(func() {
+			client.Close()
+			server.Close()
+		})
+		u.sessions = append(u.sessions, &hostSession{upstream: u, ID: i + 1, conn: client})
+	}
+	return u
+}
+
+func TestPickSession(t *testing.T) {
+	t.Run("RoundRobin", func(t *testing.T) {
+		u := newTestUpstream(t, 3)
+
+		picked := map[int]int{}
+		for i := 0; i < 6; i++ {
+			picked[u.pickSession(BalanceRoundRobin).ID]++
+		}
+
+		assert.Equal(t, map[int]int{1: 2, 2: 2, 3: 2}, picked)
+	})
+
+	t.Run("RoundRobinSkipsDisconnected", func(t *testing.T) {
+		u := newTestUpstream(t, 3)
+		u.sessions[1].conn = nil
+
+		for i := 0; i < 6; i++ {
+			assert.NotEqual(t, 2, u.pickSession(BalanceRoundRobin).ID)
+		}
+	})
+
+	t.Run("LeastOutstanding", func(t *testing.T) {
+		u := newTestUpstream(t, 3)
+		u.sessions[0].outstanding.Store(5)
+		u.sessions[1].outstanding.Store(1)
+		u.sessions[2].outstanding.Store(3)
+
+		assert.Equal(t, 2, u.pickSession(BalanceLeastOutstanding).ID)
+	})
+
+	t.Run("NoneConnected", func(t *testing.T) {
+		u := newTestUpstream(t, 2)
+		for _, s := range u.sessions {
+			s.conn = nil
+		}
+
+		assert.Nil(t, u.pickSession(BalanceRoundRobin))
+		assert.Nil(t, u.pickSession(BalanceLeastOutstanding))
+	})
+}
+
+func TestFailPending(t *testing.T) {
+	h := &Handler{Log: logrus.New()}
+	u := newTestUpstream(t, 2)
+	s1, s2 := u.sessions[0], u.sessions[1]
+
+	ch1 := h.registerPending("000000000001", s1, s1.conn)
+	ch2 := h.registerPending("000000000002", s2, s2.conn)
+	assert.Equal(t, int64(1), s1.outstanding.Load())
+
+	h.failPending(s1.conn, ErrHostDisconnected)
+
+	select {
+	case response := <-ch1:
+		assert.True(t, errors.Is(response.Err, ErrHostDisconnected))
+	default:
+		t.Fatal("pending request on dropped session was not failed")
+	}
+
+	select {
+	case <-ch2:
+		t.Fatal("pending request on healthy session must not be failed")
+	default:
+	}
+
+	h.releasePending("000000000001")
+	h.releasePending("000000000002")
+	assert.Equal(t, int64(0), s1.outstanding.Load())
+	assert.Equal(t, int64(0), s2.outstanding.Load())
+}
+
+// TestWriteToHostAppliesRules memastikan pesan yang dibuat gateway sendiri
+// (0420 reversal otomatis dan 0421 SAF) juga melewati EMV_TAG_RULES dan
+// FIELD_MAP_FILE, bukan hanya pesan dari terminal.
+func TestWriteToHostAppliesRules(t *testing.T) {
+	rules, err := parseEmvRules("test:-9F7C")
+	require.NoError(t, err)
+	fieldMap, err := fieldmap.Parse([]byte(`{"rules":[{"direction":"request","host":["test"],"mti":["0420","0421"],
+		"actions":[{"op":"set","field":18,"value":"6011"}]}]}`))
+	require.NoError(t, err)
+	h := &Handler{Log: logrus.New(), emvRules: rules, fieldMap: fieldMap}
+
+	for _, mti := range []string{"0420", "0421"} {
+		t.Run(mti, func(t *testing.T) {
+			u := newTestUpstream(t, 0)
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			s := &hostSession{upstream: u, ID: 1, conn: client}
+
+			isomessage := iso8583.NewMessage(iso.Spec87)
+			isomessage.MTI(mti)
+			require.NoError(t, isomessage.Field(11, "000001"))
+			require.NoError(t, isomessage.Field(55, "9F2701809F7C0401020304"))
+			msg, err := isomessage.Pack()
+			require.NoError(t, err)
+
+			written := make(chan error, 1)
+			go func() { written <- h.writeToHost(s, client, msg) }()
+
+			frame, err := defaultHostFramer.ReadFrame(server)
+			require.NoError(t, err)
+			require.NoError(t, <-written)
+
+			sent := iso8583.NewMessage(iso.Spec87)
+			require.NoError(t, sent.Unpack(frame.Message))
+			icc, err := iccData(sent)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"9F27"}, icc.Tags())
+			bit18, _ := sent.GetString(18)
+			assert.Equal(t, "6011", bit18)
+		})
+	}
+}
+
+func TestDefaultHostKey(t *testing.T) {
+	// Baris host_key hasil migrasi tabel key lama harus dipakai upstream default
+	assert.Equal(t, DefaultUpstreamName, repo.DefaultHostKey)
+}
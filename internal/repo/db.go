@@ -37,3 +37,68 @@ func InitDSN(dsn string) (*gorm.DB, error) {
 
 	return db, nil
 }
+
+// Migrate membuat tabel-tabel tambahan gateway jika belum ada. Tabel lama hanya
+// ditambah kolom baru, struktur kolom yang sudah ada tidak diubah.
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(&StanSequence{}, &StanMapping{}, &OrphanResponse{}, &SafQueue{}, &TerminalGroup{}, &TerminalGroupMember{}, &KeyHistory{}, &HostKey{})
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []string{"ReversalStatus", "ReversalRC", "ReversedAt", "EmvAc", "EmvCid", "EmvTvr", "EmvTrxDate", "EmvCurrency"} {
+		if db.Migrator().HasColumn(&TransactionHistory{}, column) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(&TransactionHistory{}, column); err != nil {
+			return fmt.Errorf("add column transaction_history.%s: %w", column, err)
+		}
+	}
+
+	keyColumns := []struct {
+		model  any
+		column string
+	}{
+		{&TerminalKey{}, "Tak"},
+		{&TerminalKey{}, "TpkKcv"},
+		{&TerminalKey{}, "TakKcv"},
+	}
+	for _, c := range keyColumns {
+		if db.Migrator().HasColumn(c.model, c.column) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(c.model, c.column); err != nil {
+			return fmt.Errorf("add column %s: %w", c.column, err)
+		}
+	}
+
+	return migrateDefaultHostKey(db)
+}
+
+// migrateDefaultHostKey menyalin ZMK dan ZPK dari tabel key lama ke baris
+// upstream default jika baris itu belum ada, agar instalasi satu host tetap
+// jalan tanpa mengisi host_key.
+func migrateDefaultHostKey(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&HostKey{}).Where("upstream = ?", DefaultHostKey).Count(&count).Error; err != nil {
+		return fmt.Errorf("count host key: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var key Key
+	result := db.Select("zmk", "zpk").Limit(1).Find(&key)
+	if result.Error != nil {
+		return fmt.Errorf("get legacy key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	err := db.Create(&HostKey{Upstream: DefaultHostKey, Zmk: key.Zmk, Zpk: key.Zpk, UpdatedAt: time.Now()}).Error
+	if err != nil {
+		return fmt.Errorf("create default host key: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,13 @@
 package repo
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ServiceMethodProcode menandakan service_prefix dicocokkan dengan processing code.
+// Nilai service_method lainnya dicocokkan dengan PAN/BIN.
+const ServiceMethodProcode = "PROCODE"
 
 type Services struct {
 	ID             int64     `json:"id"`
@@ -28,10 +35,27 @@ type TransactionHistory struct {
 	ResponseCode string     `json:"response_code"`
 	IsoReq       string     `json:"iso_req"`
 	IsoRes       string     `json:"iso_res"`
-	CreatedAt    time.Time  `gorm:"autoUpdateTime:false" json:"created_at"`
-	UpdatedAt    time.Time  `gorm:"autoUpdateTime:false" json:"updated_at"`
+	// Tag EMV utama dari bit 55 transaksi chip (hex)
+	EmvAc       string `gorm:"size:16;default:''" json:"emv_ac"`      // 9F26
+	EmvCid      string `gorm:"size:2;default:''" json:"emv_cid"`      // 9F27
+	EmvTvr      string `gorm:"size:10;default:''" json:"emv_tvr"`     // 95
+	EmvTrxDate  string `gorm:"size:6;default:''" json:"emv_trx_date"` // 9A
+	EmvCurrency string `gorm:"size:4;default:''" json:"emv_currency"` // 5F2A
+	// Status reversal otomatis ketika host tidak menjawab transaksi ini
+	ReversalStatus string     `gorm:"size:16;default:''" json:"reversal_status"`
+	ReversalRC     string     `gorm:"column:reversal_rc;size:2;default:''" json:"reversal_rc"`
+	ReversedAt     *time.Time `json:"reversed_at"`
+	CreatedAt      time.Time  `gorm:"autoUpdateTime:false" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime:false" json:"updated_at"`
 }
 
+const (
+	ReversalStatusPending  = "PENDING"
+	ReversalStatusReversed = "REVERSED"
+	ReversalStatusSAF      = "SAF"
+	ReversalStatusFailed   = "FAILED"
+)
+
 func (TransactionHistory) TableName() string {
 	return "transaction_history"
 }
@@ -46,10 +70,113 @@ func (Key) TableName() string {
 	return "key"
 }
 
+var ErrZpkNotStaged = errors.New("zpk not staged")
+
+// DefaultHostKey adalah nama upstream default, barisnya diisi dari tabel key
+// lama saat migrasi.
+const DefaultHostKey = "default"
+
+// HostKey adalah kunci zona satu upstream. ZMK diisi operator per host, ZPK dan
+// ZAK diisi dari key exchange dengan host tersebut.
+type HostKey struct {
+	ID       int64  `json:"id"`
+	Upstream string `gorm:"size:64;uniqueIndex" json:"upstream"`
+	Zmk      string `gorm:"size:64" json:"zmk"`
+	Zpk      string `gorm:"size:64" json:"zpk"`
+	Zak      string `gorm:"size:64" json:"zak"`
+	// KCV kunci aktif, dicatat saat key exchange
+	ZpkKcv string `gorm:"size:16" json:"zpk_kcv"`
+	ZakKcv string `gorm:"size:16" json:"zak_kcv"`
+	// ZPK baru dari host yang belum dikonfirmasi, dipakai mulai ZpkNextAt atau
+	// saat host mengonfirmasi, mana yang lebih dulu
+	ZpkNext    string     `gorm:"size:64" json:"zpk_next"`
+	ZpkNextKcv string     `gorm:"size:16" json:"zpk_next_kcv"`
+	ZpkNextAt  *time.Time `json:"zpk_next_at"`
+	// ZPK sebelum ZPK aktif, masih berlaku sampai ZpkPrevUntil (grace window)
+	// untuk transaksi yang sedang berjalan saat rotasi
+	ZpkPrev      string     `gorm:"size:64" json:"zpk_prev"`
+	ZpkPrevKcv   string     `gorm:"size:16" json:"zpk_prev_kcv"`
+	ZpkPrevUntil *time.Time `json:"zpk_prev_until"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime:false" json:"updated_at"`
+}
+
+// ActiveZPK mengembalikan ZPK dan KCV untuk translate PIN saat now. ZPK lama
+// tetap dipakai sampai ZPK baru dikonfirmasi host atau ZpkNextAt lewat, supaya
+// PIN tidak dikirim dengan kunci yang belum dipakai host.
+func (k HostKey) ActiveZPK(now time.Time) (string, string) {
+	if k.nextActive(now) {
+		return k.ZpkNext, k.ZpkNextKcv
+	}
+	return k.Zpk, k.ZpkKcv
+}
+
+func (k HostKey) nextActive(now time.Time) bool {
+	return k.ZpkNext != "" && k.ZpkNextAt != nil && !now.Before(*k.ZpkNextAt)
+}
+
+// GraceZPK mengembalikan ZPK sebelumnya jika masih dalam grace window saat now,
+// yaitu ZPK lama setelah ZPK baru aktif atau ZPK aktif jika ZPK tahap sudah
+// aktif karena waktunya lewat.
+func (k HostKey) GraceZPK(now time.Time) (string, string) {
+	if k.nextActive(now) {
+		return k.Zpk, k.ZpkKcv
+	}
+	if k.ZpkPrev != "" && k.ZpkPrevUntil != nil && now.Before(*k.ZpkPrevUntil) {
+		return k.ZpkPrev, k.ZpkPrevKcv
+	}
+	return "", ""
+}
+
+// StageZPK menyimpan ZPK baru yang belum dikonfirmasi host. ZPK tahap
+// sebelumnya yang sudah aktif karena waktunya lewat dijadikan ZPK aktif dulu,
+// ZPK tahap yang belum aktif diganti.
+func (k *HostKey) StageZPK(zpk, kcv string, activateAt, now time.Time, grace time.Duration) {
+	if k.nextActive(now) {
+		k.promoteZPK(*k.ZpkNextAt, grace)
+	}
+	k.ZpkNext, k.ZpkNextKcv, k.ZpkNextAt = zpk, kcv, &activateAt
+	k.UpdatedAt = now
+}
+
+// ConfirmZPK mengaktifkan ZPK tahap setelah host mengonfirmasinya, ZPK lama
+// tetap berlaku selama grace. zpk harus sama dengan ZPK tahap, konfirmasi untuk
+// key exchange yang sudah diganti ditolak dengan ErrZpkNotStaged.
+func (k *HostKey) ConfirmZPK(zpk string, now time.Time, grace time.Duration) error {
+	if k.ZpkNext == "" || k.ZpkNext != zpk {
+		if k.Zpk == zpk {
+			return nil
+		}
+		return ErrZpkNotStaged
+	}
+	activateAt := now
+	if k.nextActive(now) {
+		activateAt = *k.ZpkNextAt
+	}
+	k.promoteZPK(activateAt, grace)
+	k.UpdatedAt = now
+	return nil
+}
+
+func (k *HostKey) promoteZPK(activateAt time.Time, grace time.Duration) {
+	if k.Zpk != "" {
+		until := activateAt.Add(grace)
+		k.ZpkPrev, k.ZpkPrevKcv, k.ZpkPrevUntil = k.Zpk, k.ZpkKcv, &until
+	}
+	k.Zpk, k.ZpkKcv = k.ZpkNext, k.ZpkNextKcv
+	k.ZpkNext, k.ZpkNextKcv, k.ZpkNextAt = "", "", nil
+}
+
+func (HostKey) TableName() string {
+	return "host_key"
+}
+
 type TerminalKey struct {
 	ID        int64     `json:"id"`
 	Tid       string    `json:"tid"`
 	Tpk       string    `json:"tpk"`
+	Tak       string    `gorm:"size:64" json:"tak"`
+	TpkKcv    string    `gorm:"size:16" json:"tpk_kcv"`
+	TakKcv    string    `gorm:"size:16" json:"tak_kcv"`
 	CreatedAt time.Time `gorm:"autoCreateTime:false" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime:false" json:"updated_at"`
 }
@@ -57,3 +184,139 @@ type TerminalKey struct {
 func (TerminalKey) TableName() string {
 	return "terminal_key"
 }
+
+// KeyHistory mencatat setiap kunci yang pernah aktif beserta KCV-nya.
+type KeyHistory struct {
+	ID          int64      `json:"id"`
+	Upstream    string     `gorm:"size:64;index" json:"upstream"`
+	KeyType     string     `gorm:"size:8;index" json:"key_type"`
+	Key         string     `gorm:"size:64" json:"-"` // di bawah LMK
+	Kcv         string     `gorm:"size:16" json:"kcv"`
+	Source      string     `gorm:"size:16" json:"source"`
+	ActivatedAt time.Time  `json:"activated_at"`
+	RetiredAt   *time.Time `json:"retired_at"`
+}
+
+func (KeyHistory) TableName() string {
+	return "key_history"
+}
+
+const (
+	KeyTypeZPK = "ZPK"
+
+	KeySourceConnect   = "CONNECT"   // key exchange saat sign on
+	KeySourceHost      = "HOST"      // 0800/102 dari host
+	KeySourceScheduled = "SCHEDULED" // rotasi terjadwal
+	KeySourceManual    = "MANUAL"    // permintaan lewat admin API
+)
+
+// TerminalGroup mengelompokkan terminal dengan pengaturan kunci yang sama.
+// Terminal di grup yang punya BDK memakai DUKPT, selain itu TPK per TID dari logon.
+type TerminalGroup struct {
+	ID        int64     `json:"id"`
+	Name      string    `gorm:"size:64;uniqueIndex" json:"name"`
+	Bdk       string    `gorm:"size:64" json:"bdk"`       // BDK di bawah LMK
+	PinFormat string    `gorm:"size:8" json:"pin_format"` // ISO0 | ISO1 | ISO3 | ISO4, kosong = PIN_FORMAT_TERMINAL
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (TerminalGroup) TableName() string {
+	return "terminal_group"
+}
+
+// TerminalGroupMember memetakan TID ke grupnya, satu TID hanya di satu grup.
+type TerminalGroupMember struct {
+	Tid       string    `gorm:"primaryKey;size:16" json:"tid"`
+	GroupID   int64     `gorm:"index" json:"group_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (TerminalGroupMember) TableName() string {
+	return "terminal_group_member"
+}
+
+// StanSequence menyimpan STAN berikutnya per nama sequence.
+type StanSequence struct {
+	Name      string    `gorm:"primaryKey;size:32" json:"name"`
+	Value     int64     `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (StanSequence) TableName() string {
+	return "stan_sequence"
+}
+
+// StanMapping adalah pasangan STAN host dan STAN client untuk transaksi yang
+// sedang berjalan, disimpan agar respons host tetap bisa dipetakan setelah restart.
+type StanMapping struct {
+	StanHost   string    `gorm:"primaryKey;size:12" json:"stan_host"`
+	StanClient string    `gorm:"size:6" json:"stan_client"`
+	Tid        string    `gorm:"size:8" json:"tid"`
+	Mti        string    `gorm:"size:4" json:"mti"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+func (StanMapping) TableName() string {
+	return "stan_mapping"
+}
+
+// OrphanResponse adalah respons host yang datang tanpa request yang menunggu,
+// misalnya respons yang terlambat setelah timeout atau setelah restart.
+type OrphanResponse struct {
+	ID           int64     `json:"id"`
+	Upstream     string    `gorm:"size:64" json:"upstream"`
+	Mti          string    `gorm:"size:4" json:"mti"`
+	StanHost     string    `gorm:"size:12;index" json:"stan_host"`
+	StanClient   string    `gorm:"size:6" json:"stan_client"`
+	Tid          string    `gorm:"size:8" json:"tid"`
+	ResponseCode string    `gorm:"size:2" json:"response_code"`
+	IsoRes       string    `gorm:"type:text" json:"iso_res"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OrphanResponse) TableName() string {
+	return "orphan_response"
+}
+
+const (
+	SafStatusPending  = "PENDING"
+	SafStatusSending  = "SENDING"
+	SafStatusApproved = "APPROVED"
+	SafStatusDead     = "DEAD"
+)
+
+// SafQueue adalah antrian store-and-forward untuk reversal advice (0421) yang
+// belum mendapat 0430 approved dari host.
+type SafQueue struct {
+	ID              int64      `json:"id"`
+	TransactionID   int64      `gorm:"index" json:"transaction_id"`
+	ReversalOf      int64      `json:"reversal_of"` // id transaksi 0200 yang di-reversal otomatis
+	UpstreamName    string     `gorm:"size:64" json:"upstream_name"`
+	UpstreamAddress string     `gorm:"size:255" json:"upstream_address"`
+	Tid             string     `gorm:"size:8;index:idx_saf_tid_stan" json:"tid"`
+	StanClient      string     `gorm:"size:6;index:idx_saf_tid_stan" json:"stan_client"`
+	StanHost        string     `gorm:"size:12" json:"stan_host"`
+	IsoReq          string     `gorm:"type:text" json:"iso_req"`
+	Status          string     `gorm:"size:16;index:idx_saf_due" json:"status"`
+	Attempts        int        `json:"attempts"`
+	NextAttemptAt   time.Time  `gorm:"index:idx_saf_due" json:"next_attempt_at"`
+	LastRC          string     `gorm:"column:last_rc;size:2" json:"last_rc"`
+	LastError       string     `gorm:"size:255" json:"last_error"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (SafQueue) TableName() string {
+	return "saf_queue"
+}
+
+// TransactionFilter adalah kriteria pencarian transaction_history. STAN dan RRN
+// dicocokkan dengan nilai dari terminal maupun nilai ke host.
+type TransactionFilter struct {
+	Tid   string
+	Stan  string
+	Rrn   string
+	Limit int
+}
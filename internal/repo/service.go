@@ -3,9 +3,11 @@ package repo
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func ServiceGetAddress(ctx context.Context, db *gorm.DB, prefix string) (Services, error) {
@@ -15,6 +17,33 @@ func ServiceGetAddress(ctx context.Context, db *gorm.DB, prefix string) (Service
 	return service, result.Error
 }
 
+func ServiceGetAll(ctx context.Context, db *gorm.DB) ([]Services, error) {
+	var services []Services
+	result := db.WithContext(ctx).Find(&services)
+
+	return services, result.Error
+}
+
+// MatchService mencari service dengan service_prefix terpanjang yang cocok dengan
+// awal PAN, atau dengan awal processing code untuk service_method PROCODE.
+func MatchService(services []Services, pan, procode string) (Services, bool) {
+	var match Services
+	found := false
+	for _, service := range services {
+		value := pan
+		if service.ServiceMethod == ServiceMethodProcode {
+			value = procode
+		}
+		if !strings.HasPrefix(value, service.ServicePrefix) {
+			continue
+		}
+		if !found || len(service.ServicePrefix) > len(match.ServicePrefix) {
+			match, found = service, true
+		}
+	}
+	return match, found
+}
+
 func TransactionHistorySave(ctx context.Context, db *gorm.DB, data *TransactionHistory) (int64, error) {
 	result := db.WithContext(ctx).Select(
 		"mti",
@@ -30,6 +59,11 @@ func TransactionHistorySave(ctx context.Context, db *gorm.DB, data *TransactionH
 		"rrn_host",
 		"merchant_name",
 		"iso_req",
+		"emv_ac",
+		"emv_cid",
+		"emv_tvr",
+		"emv_trx_date",
+		"emv_currency",
 		"created_at",
 	).Create(&data)
 
@@ -55,6 +89,24 @@ func TransactionHistoryUpdateResponse(ctx context.Context, db *gorm.DB, data *Tr
 	return result.Error
 }
 
+func TransactionHistoryGetByID(ctx context.Context, db *gorm.DB, id int64) (TransactionHistory, error) {
+	var trxHistory TransactionHistory
+	result := db.WithContext(ctx).Where("id = ?", id).First(&trxHistory)
+
+	return trxHistory, result.Error
+}
+
+func TransactionHistoryUpdateReversal(ctx context.Context, db *gorm.DB, data *TransactionHistory) error {
+	result := db.WithContext(ctx).Model(&TransactionHistory{ID: data.ID}).Updates(map[string]interface{}{
+		"reversal_status": data.ReversalStatus,
+		"reversal_rc":     data.ReversalRC,
+		"reversed_at":     data.ReversedAt,
+		"updated_at":      data.UpdatedAt,
+	})
+
+	return result.Error
+}
+
 func TransactionHistoryGetDataWD(ctx context.Context, db *gorm.DB, data *TransactionHistory) (TransactionHistory, error) {
 	var trxHistory TransactionHistory
 	result := db.WithContext(ctx).
@@ -103,6 +155,94 @@ func KeyGetZPK(ctx context.Context, db *gorm.DB) (string, error) {
 	return key.Zpk, result.Error
 }
 
+// HostKeyGet mengambil kunci zona upstream. Error gorm.ErrRecordNotFound jika
+// upstream belum punya baris di host_key.
+func HostKeyGet(ctx context.Context, db *gorm.DB, upstream string) (*HostKey, error) {
+	var key HostKey
+	result := db.WithContext(ctx).Where("upstream = ?", upstream).First(&key)
+
+	return &key, result.Error
+}
+
+// HostKeyStageZPK menyimpan history.Key sebagai ZPK tahap upstream, aktif mulai
+// history.ActivatedAt kecuali dikonfirmasi lebih dulu lewat HostKeyConfirmZPK.
+// Kunci dicatat di key_history dengan KCV-nya.
+func HostKeyStageZPK(ctx context.Context, db *gorm.DB, upstream string, history *KeyHistory, grace time.Duration) error {
+	history.Upstream = upstream
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		key, err := hostKeyForUpdate(tx, upstream)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		key.StageZPK(history.Key, history.Kcv, history.ActivatedAt, now, grace)
+		if err := saveHostKeyZPK(tx, key); err != nil {
+			return err
+		}
+
+		return tx.Create(history).Error
+	})
+}
+
+// HostKeyConfirmZPK mengaktifkan ZPK tahap upstream yang sudah dikonfirmasi
+// host. History ZPK sebelumnya ditandai retired saat grace window berakhir.
+func HostKeyConfirmZPK(ctx context.Context, db *gorm.DB, upstream, zpk string, grace time.Duration) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		key, err := hostKeyForUpdate(tx, upstream)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := key.ConfirmZPK(zpk, now, grace); err != nil {
+			return err
+		}
+		if err := saveHostKeyZPK(tx, key); err != nil {
+			return err
+		}
+
+		result := tx.Model(&KeyHistory{}).
+			Where("upstream = ? AND key_type = ? AND `key` = ?", upstream, KeyTypeZPK, zpk).
+			Update("activated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&KeyHistory{}).
+			Where("upstream = ? AND key_type = ? AND `key` <> ? AND retired_at IS NULL", upstream, KeyTypeZPK, zpk).
+			Update("retired_at", now.Add(grace)).Error
+	})
+}
+
+func hostKeyForUpdate(tx *gorm.DB, upstream string) (*HostKey, error) {
+	var key HostKey
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("upstream = ?", upstream).First(&key).Error
+
+	return &key, err
+}
+
+func saveHostKeyZPK(tx *gorm.DB, key *HostKey) error {
+	return tx.Model(&HostKey{}).Where("id = ?", key.ID).Updates(map[string]any{
+		"zpk":            key.Zpk,
+		"zpk_kcv":        key.ZpkKcv,
+		"zpk_next":       key.ZpkNext,
+		"zpk_next_kcv":   key.ZpkNextKcv,
+		"zpk_next_at":    key.ZpkNextAt,
+		"zpk_prev":       key.ZpkPrev,
+		"zpk_prev_kcv":   key.ZpkPrevKcv,
+		"zpk_prev_until": key.ZpkPrevUntil,
+		"updated_at":     key.UpdatedAt,
+	}).Error
+}
+
+func HostKeyUpdateZAK(ctx context.Context, db *gorm.DB, upstream, zak, kcv string) error {
+	result := db.WithContext(ctx).Model(&HostKey{}).Where("upstream = ?", upstream).
+		Updates(map[string]any{"zak": zak, "zak_kcv": kcv, "updated_at": time.Now()})
+
+	return result.Error
+}
+
 func KeyGetTMK(ctx context.Context, db *gorm.DB) (string, error) {
 	var key Key
 	result := db.WithContext(ctx).Select("tmk").First(&key)
@@ -121,6 +261,9 @@ func TerminalKeySave(ctx context.Context, db *gorm.DB, data *TerminalKey) error
 	if count > 0 {
 		resultUpdate := db.WithContext(ctx).Model(&TerminalKey{}).Where(`tid = ?`, data.Tid).Updates(&TerminalKey{
 			Tpk:       data.Tpk,
+			Tak:       data.Tak,
+			TpkKcv:    data.TpkKcv,
+			TakKcv:    data.TakKcv,
 			UpdatedAt: time.Now(),
 		})
 
@@ -131,6 +274,9 @@ func TerminalKeySave(ctx context.Context, db *gorm.DB, data *TerminalKey) error
 		result := db.WithContext(ctx).Select(
 			"tid",
 			"tpk",
+			"tak",
+			"tpk_kcv",
+			"tak_kcv",
 			"created_at",
 		).Create(&data)
 
@@ -164,3 +310,182 @@ func TerminalKeyGetTPK(ctx context.Context, db *gorm.DB, data *TerminalKey) (str
 
 	return terminalKey.Tpk, result.Error
 }
+
+func TerminalKeyGetTAK(ctx context.Context, db *gorm.DB, tid string) (string, error) {
+	var terminalKey TerminalKey
+	result := db.WithContext(ctx).Select("tak").Where("tid = ?", tid).Find(&terminalKey)
+
+	return terminalKey.Tak, result.Error
+}
+
+// TerminalGroupGetByTid mengambil grup terminal, nil jika TID tidak terdaftar di grup manapun.
+func TerminalGroupGetByTid(ctx context.Context, db *gorm.DB, tid string) (*TerminalGroup, error) {
+	var groups []TerminalGroup
+	result := db.WithContext(ctx).
+		Joins("JOIN terminal_group_member ON terminal_group_member.group_id = terminal_group.id").
+		Where("terminal_group_member.tid = ?", tid).
+		Limit(1).
+		Find(&groups)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	return &groups[0], nil
+}
+
+// StanSequenceReserve mengunci baris sequence (SELECT ... FOR UPDATE), mengambil nilainya
+// lalu menyimpan nilai berikutnya dari next. Baris dibuat dengan nilai initial jika belum ada.
+func StanSequenceReserve(ctx context.Context, db *gorm.DB, name string, initial int64, next func(cur int64) (start, after int64)) (int64, error) {
+	var start int64
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var seq StanSequence
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).First(&seq)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			seq = StanSequence{Name: name, Value: initial}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+				return err
+			}
+			result = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).First(&seq)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var after int64
+		start, after = next(seq.Value)
+		return tx.Model(&StanSequence{}).Where("name = ?", name).
+			Updates(map[string]interface{}{"value": after, "updated_at": time.Now()}).Error
+	})
+
+	return start, err
+}
+
+func StanMappingSave(ctx context.Context, db *gorm.DB, data *StanMapping) error {
+	result := db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(data)
+
+	return result.Error
+}
+
+func StanMappingGet(ctx context.Context, db *gorm.DB, stanHost string) (StanMapping, error) {
+	var mapping StanMapping
+	result := db.WithContext(ctx).Where("stan_host = ?", stanHost).First(&mapping)
+
+	return mapping, result.Error
+}
+
+func StanMappingGetSince(ctx context.Context, db *gorm.DB, since time.Time) ([]StanMapping, error) {
+	var mappings []StanMapping
+	result := db.WithContext(ctx).Where("created_at >= ?", since).Find(&mappings)
+
+	return mappings, result.Error
+}
+
+func StanMappingDelete(ctx context.Context, db *gorm.DB, stanHost string) error {
+	result := db.WithContext(ctx).Where("stan_host = ?", stanHost).Delete(&StanMapping{})
+
+	return result.Error
+}
+
+func StanMappingDeleteBefore(ctx context.Context, db *gorm.DB, before time.Time) (int64, error) {
+	result := db.WithContext(ctx).Where("created_at < ?", before).Delete(&StanMapping{})
+
+	return result.RowsAffected, result.Error
+}
+
+func OrphanResponseSave(ctx context.Context, db *gorm.DB, data *OrphanResponse) error {
+	result := db.WithContext(ctx).Create(data)
+
+	return result.Error
+}
+
+func SafSave(ctx context.Context, db *gorm.DB, data *SafQueue) error {
+	result := db.WithContext(ctx).Create(data)
+
+	return result.Error
+}
+
+// SafGetOpen mencari antrian yang belum selesai untuk reversal terminal tertentu.
+func SafGetOpen(ctx context.Context, db *gorm.DB, tid, stanClient string) (SafQueue, error) {
+	var saf SafQueue
+	result := db.WithContext(ctx).
+		Where("tid = ? AND stan_client = ? AND status IN ?", tid, stanClient, []string{SafStatusPending, SafStatusSending}).
+		Order("id DESC").
+		First(&saf)
+
+	return saf, result.Error
+}
+
+func SafGetDue(ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]SafQueue, error) {
+	var safs []SafQueue
+	result := db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", SafStatusPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&safs)
+
+	return safs, result.Error
+}
+
+// SafClaim mengubah status PENDING menjadi SENDING, false jika sudah diambil worker lain.
+func SafClaim(ctx context.Context, db *gorm.DB, id int64) (bool, error) {
+	result := db.WithContext(ctx).Model(&SafQueue{}).
+		Where("id = ? AND status = ?", id, SafStatusPending).
+		Updates(map[string]interface{}{"status": SafStatusSending, "updated_at": time.Now()})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func SafUpdateAttempt(ctx context.Context, db *gorm.DB, data *SafQueue) error {
+	result := db.WithContext(ctx).Model(&SafQueue{ID: data.ID}).Updates(map[string]interface{}{
+		"status":          data.Status,
+		"attempts":        data.Attempts,
+		"stan_host":       data.StanHost,
+		"next_attempt_at": data.NextAttemptAt,
+		"last_rc":         data.LastRC,
+		"last_error":      data.LastError,
+		"completed_at":    data.CompletedAt,
+		"updated_at":      time.Now(),
+	})
+
+	return result.Error
+}
+
+func SafMarkApproved(ctx context.Context, db *gorm.DB, tid, stanClient string) error {
+	now := time.Now()
+	result := db.WithContext(ctx).Model(&SafQueue{}).
+		Where("tid = ? AND stan_client = ? AND status IN ?", tid, stanClient, []string{SafStatusPending, SafStatusSending}).
+		Updates(map[string]interface{}{"status": SafStatusApproved, "last_rc": "00", "completed_at": &now, "updated_at": now})
+
+	return result.Error
+}
+
+// SafResetSending mengembalikan antrian yang tertinggal di status SENDING (misal
+// proses mati saat mengirim) ke PENDING.
+func SafResetSending(ctx context.Context, db *gorm.DB) (int64, error) {
+	result := db.WithContext(ctx).Model(&SafQueue{}).
+		Where("status = ?", SafStatusSending).
+		Updates(map[string]interface{}{"status": SafStatusPending, "updated_at": time.Now()})
+
+	return result.RowsAffected, result.Error
+}
+
+func TransactionHistoryFind(ctx context.Context, db *gorm.DB, filter TransactionFilter) ([]TransactionHistory, error) {
+	query := db.WithContext(ctx).Model(&TransactionHistory{})
+	if filter.Tid != "" {
+		query = query.Where("tid = ?", filter.Tid)
+	}
+	if filter.Stan != "" {
+		query = query.Where("(stan = ? OR stan_host = ?)", filter.Stan, filter.Stan)
+	}
+	if filter.Rrn != "" {
+		query = query.Where("(rrn = ? OR rrn_host = ?)", filter.Rrn, filter.Rrn)
+	}
+
+	var trxHistory []TransactionHistory
+	result := query.Order("id DESC").Limit(filter.Limit).Find(&trxHistory)
+
+	return trxHistory, result.Error
+}
@@ -0,0 +1,31 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alfianX/danus-h2h/internal/repo"
+	"gorm.io/gorm"
+)
+
+// DBReserver menyimpan STAN berikutnya di tabel stan_sequence. Baris dikunci dalam
+// transaksi sehingga beberapa instance gateway bisa berbagi sequence yang sama.
+type DBReserver struct {
+	db   *gorm.DB
+	name string
+}
+
+func NewDBReserver(db *gorm.DB, name string) *DBReserver {
+	return &DBReserver{db: db, name: name}
+}
+
+func (r *DBReserver) Reserve(ctx context.Context, n int64, rng Range) (int64, error) {
+	start, err := repo.StanSequenceReserve(ctx, r.db, r.name, rng.Min, func(cur int64) (int64, int64) {
+		start := rng.Advance(cur, 0)
+		return start, rng.Advance(start, n)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("db reserver -> %s: %w", r.name, err)
+	}
+	return start, nil
+}
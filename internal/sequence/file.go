@@ -0,0 +1,121 @@
+package sequence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type fileState struct {
+	Stan int64 `json:"stan"`
+}
+
+// FileReserver menyimpan STAN berikutnya di file JSON (format sama dengan stan.json).
+// Setiap update ditulis ke file sementara, di-fsync lalu di-rename, jadi isi file
+// selalu utuh walaupun proses mati di tengah penulisan. File lock dipakai agar
+// beberapa instance yang berbagi file yang sama tidak mendapat STAN yang sama.
+type FileReserver struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileReserver(path string) *FileReserver {
+	return &FileReserver{path: path}
+}
+
+func (r *FileReserver) Reserve(ctx context.Context, n int64, rng Range) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lock, err := os.OpenFile(r.path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("file reserver -> open lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := lockFile(lock); err != nil {
+		return 0, fmt.Errorf("file reserver -> lock: %w", err)
+	}
+	defer unlockFile(lock)
+
+	state, err := r.read()
+	if err != nil {
+		return 0, err
+	}
+
+	start := rng.Advance(state.Stan, 0)
+	state.Stan = rng.Advance(start, n)
+	if err := r.write(state); err != nil {
+		return 0, err
+	}
+
+	return start, nil
+}
+
+func (r *FileReserver) read() (fileState, error) {
+	var state fileState
+
+	byteValue, err := os.ReadFile(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("file reserver -> read: %w", err)
+	}
+
+	if err := json.Unmarshal(byteValue, &state); err != nil {
+		return state, fmt.Errorf("file reserver -> decode %s: %w", r.path, err)
+	}
+	return state, nil
+}
+
+func (r *FileReserver) write(state fileState) error {
+	byteValue, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	byteValue = append(byteValue, '\n')
+
+	dir := filepath.Dir(r.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("file reserver -> create temp: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(byteValue); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("file reserver -> write: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("file reserver -> sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("file reserver -> close: %w", err)
+	}
+	if err := os.Rename(tmpName, r.path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("file reserver -> rename: %w", err)
+	}
+
+	// Sync direktori agar rename ikut tersimpan, tidak didukung di semua OS
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	DefaultWidth     = 12
+	DefaultMin       = 1
+	DefaultBlockSize = 1
+	maxWidth         = 18
+)
+
+// StanGenerator menghasilkan STAN unik yang sudah diformat sesuai lebar field.
+type StanGenerator interface {
+	Next(ctx context.Context) (string, error)
+}
+
+// Reserver menyimpan nilai STAN berikutnya secara durable. Reserve mengambil n nilai
+// sekaligus dan mengembalikan nilai pertama; nilai berikutnya yang disimpan sudah
+// melewati blok tersebut, jadi setelah crash sisa blok dilewati dan tidak dipakai ulang.
+type Reserver interface {
+	Reserve(ctx context.Context, n int64, r Range) (int64, error)
+}
+
+// Range adalah batas nilai STAN. Setelah Max, nilai kembali ke Min.
+type Range struct {
+	Min int64
+	Max int64
+}
+
+// Advance mengembalikan nilai ke-n setelah cur dengan aturan wrap.
+func (r Range) Advance(cur, n int64) int64 {
+	if cur < r.Min || cur > r.Max {
+		cur = r.Min
+	}
+	size := r.Max - r.Min + 1
+	return r.Min + (cur-r.Min+n%size)%size
+}
+
+type Options struct {
+	Width     int   // jumlah digit, STAN di-pad nol di kiri
+	Min       int64 // nilai awal setelah wrap
+	Max       int64 // 0 berarti nilai terbesar yang muat di Width
+	BlockSize int64 // jumlah STAN yang dipesan ke storage sekaligus
+}
+
+func (o Options) withDefaults() (Options, error) {
+	if o.Width == 0 {
+		o.Width = DefaultWidth
+	}
+	if o.Min == 0 {
+		o.Min = DefaultMin
+	}
+	if o.BlockSize == 0 {
+		o.BlockSize = DefaultBlockSize
+	}
+	if o.Width < 1 || o.Width > maxWidth {
+		return o, fmt.Errorf("stan generator -> invalid width %d", o.Width)
+	}
+
+	limit := int64(1)
+	for i := 0; i < o.Width; i++ {
+		limit *= 10
+	}
+	if o.Max == 0 {
+		o.Max = limit - 1
+	}
+	if o.Max >= limit {
+		return o, fmt.Errorf("stan generator -> max %d does not fit in %d digits", o.Max, o.Width)
+	}
+	if o.Min < 0 || o.Min > o.Max {
+		return o, fmt.Errorf("stan generator -> invalid range %d-%d", o.Min, o.Max)
+	}
+	if o.BlockSize < 1 || o.BlockSize > o.Max-o.Min+1 {
+		return o, fmt.Errorf("stan generator -> invalid block size %d", o.BlockSize)
+	}
+	return o, nil
+}
+
+type blockGenerator struct {
+	store     Reserver
+	width     int
+	rng       Range
+	blockSize int64
+
+	mu        sync.Mutex
+	next      int64
+	remaining int64
+}
+
+// NewStanGenerator membuat generator yang memesan blok STAN dari store dan
+// membagikannya dari memori, sehingga storage hanya disentuh sekali per blok.
+func NewStanGenerator(store Reserver, opts Options) (StanGenerator, error) {
+	if store == nil {
+		return nil, errors.New("stan generator -> store is nil")
+	}
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockGenerator{
+		store:     store,
+		width:     opts.Width,
+		rng:       Range{Min: opts.Min, Max: opts.Max},
+		blockSize: opts.BlockSize,
+	}, nil
+}
+
+func (g *blockGenerator) Next(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.remaining == 0 {
+		start, err := g.store.Reserve(ctx, g.blockSize, g.rng)
+		if err != nil {
+			return "", fmt.Errorf("stan generator -> reserve: %w", err)
+		}
+		g.next = start
+		g.remaining = g.blockSize
+	}
+
+	stan := g.next
+	g.next = g.rng.Advance(g.next, 1)
+	g.remaining--
+
+	return fmt.Sprintf("%0*d", g.width, stan), nil
+}
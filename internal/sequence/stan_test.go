@@ -0,0 +1,176 @@
+package sequence
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeAdvance(t *testing.T) {
+	rng := Range{Min: 1, Max: 999999}
+
+	assert.Equal(t, int64(2), rng.Advance(1, 1))
+	assert.Equal(t, int64(1), rng.Advance(999999, 1))
+	assert.Equal(t, int64(6), rng.Advance(999998, 7))
+	assert.Equal(t, int64(1), rng.Advance(0, 0), "nilai di luar range dimulai dari Min")
+}
+
+func TestOptionsValidation(t *testing.T) {
+	store := NewFileReserver(filepath.Join(t.TempDir(), "stan.json"))
+
+	_, err := NewStanGenerator(store, Options{Width: 6, Max: 1000000})
+	assert.Error(t, err)
+	_, err = NewStanGenerator(store, Options{Width: 6, Min: 10, Max: 5})
+	assert.Error(t, err)
+	_, err = NewStanGenerator(store, Options{Width: 2, BlockSize: 100})
+	assert.Error(t, err)
+	_, err = NewStanGenerator(nil, Options{})
+	assert.Error(t, err)
+}
+
+func TestFileGeneratorCompatibleWithStanJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stan.json")
+	require.NoError(t, os.WriteFile(path, []byte("{\n  \"stan\": 41\n}\n"), 0644))
+
+	gen, err := NewStanGenerator(NewFileReserver(path), Options{})
+	require.NoError(t, err)
+
+	stan, err := gen.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "000000000041", stan)
+
+	byteValue, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"stan": 42}`, string(byteValue))
+}
+
+func TestFileGeneratorWrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stan.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"stan": 998}`), 0644))
+
+	gen, err := NewStanGenerator(NewFileReserver(path), Options{Width: 3, Min: 1, BlockSize: 3})
+	require.NoError(t, err)
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		stan, err := gen.Next(context.Background())
+		require.NoError(t, err)
+		got = append(got, stan)
+	}
+	assert.Equal(t, []string{"998", "999", "001", "002"}, got)
+}
+
+func TestFileGeneratorSkipsUnusedBlockAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stan.json")
+
+	gen, err := NewStanGenerator(NewFileReserver(path), Options{Width: 6, BlockSize: 10})
+	require.NoError(t, err)
+	stan, err := gen.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "000001", stan)
+
+	// Instance baru (setelah restart) tidak boleh memakai sisa blok sebelumnya
+	gen, err = NewStanGenerator(NewFileReserver(path), Options{Width: 6, BlockSize: 10})
+	require.NoError(t, err)
+	stan, err = gen.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "000011", stan)
+}
+
+// TestFileGeneratorConcurrentInstances mensimulasikan beberapa instance gateway yang
+// berbagi satu file, masing-masing dengan beberapa goroutine.
+func TestFileGeneratorConcurrentInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stan.json")
+
+	const (
+		instances  = 4
+		goroutines = 8
+		perWorker  = 50
+	)
+
+	for _, blockSize := range []int64{1, 7} {
+		gens := make([]StanGenerator, instances)
+		for i := range gens {
+			gen, err := NewStanGenerator(NewFileReserver(path), Options{BlockSize: blockSize})
+			require.NoError(t, err)
+			gens[i] = gen
+		}
+
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			seen = make(map[string]bool)
+		)
+		for _, gen := range gens {
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(gen StanGenerator) {
+					defer wg.Done()
+					for i := 0; i < perWorker; i++ {
+						stan, err := gen.Next(context.Background())
+						if !assert.NoError(t, err) {
+							return
+						}
+						mu.Lock()
+						assert.False(t, seen[stan], "duplicate stan %s", stan)
+						seen[stan] = true
+						mu.Unlock()
+					}
+				}(gen)
+			}
+		}
+		wg.Wait()
+
+		assert.Len(t, seen, instances*goroutines*perWorker)
+	}
+}
+
+type memoryReserver struct {
+	mu    sync.Mutex
+	value int64
+	calls int
+}
+
+func (m *memoryReserver) Reserve(_ context.Context, n int64, rng Range) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	start := rng.Advance(m.value, 0)
+	m.value = rng.Advance(start, n)
+	return start, nil
+}
+
+func TestBlockGeneratorReservesOncePerBlock(t *testing.T) {
+	store := &memoryReserver{}
+	gen, err := NewStanGenerator(store, Options{BlockSize: 100})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				stan, err := gen.Next(context.Background())
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				seen[stan] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, 1000)
+	assert.Equal(t, 10, store.calls)
+}
@@ -2,13 +2,19 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/alfianX/danus-h2h/config"
+	"github.com/alfianX/danus-h2h/internal/admin"
 	"github.com/alfianX/danus-h2h/internal/handler"
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+	"github.com/alfianX/danus-h2h/pkg/tlsconf"
+	"github.com/alfianX/danus-h2h/pkg/tracing"
 	"github.com/sirupsen/logrus"
 )
 
@@ -39,11 +45,38 @@ func NewTCP(appLogger *logrus.Logger, cnf config.Config, newHandlerFunc func(con
 }
 
 func (s *TCP) Run(ctx context.Context) error {
+	shutdownTracing, err := tracing.Init(ctx, tracing.Options{
+		Endpoint:    s.config.OtelEndpoint,
+		Insecure:    s.config.OtelInsecure,
+		ServiceName: s.config.OtelServiceName,
+		SampleRatio: s.config.OtelSampleRatio,
+	})
+	if err != nil {
+		s.log.Errorf("Failed to init tracing: %v", err)
+		return err
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			s.log.Errorf("Failed to flush traces: %v", err)
+		}
+	}()
+
 	go s.handler.ConnectToHost()
 
 	cronCtx, cancelCron := context.WithCancel(context.Background())
 	defer cancelCron()
 	go s.handler.HostHealthCheck(cronCtx)
+	go s.handler.RunSAF(cronCtx)
+	go s.handler.RunKeyRotation(cronCtx)
+
+	if s.config.AdminListen != "" {
+		if err := s.runAdmin(cronCtx); err != nil {
+			s.log.Errorf("Failed to start admin API: %v", err)
+			return err
+		}
+	}
 
 	s.log.Infof("Server listen on port: %d", s.config.ListenPort)
 	serverAddress := fmt.Sprintf("0.0.0.0:%d", s.config.ListenPort)
@@ -54,7 +87,22 @@ func (s *TCP) Run(ctx context.Context) error {
 	}
 	defer listener.Close()
 
+	if s.config.TLSCertFile != "" {
+		tlsConfig, err := tlsconf.NewServerConfig(tlsconf.ServerOptions{
+			CertFile:     s.config.TLSCertFile,
+			KeyFile:      s.config.TLSKeyFile,
+			ClientCAFile: s.config.TLSClientCAFile,
+		})
+		if err != nil {
+			s.log.Errorf("Failed to load TLS config: %v", err)
+			return err
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+		s.log.Infof("TLS enabled on port %d (mutual TLS: %t)", s.config.ListenPort, s.config.TLSClientCAFile != "")
+	}
+
 	sem := make(chan struct{}, s.maxClient)
+	metrics.SetActiveClientsSource(func() int { return len(sem) })
 	waitingQueue := make(chan net.Conn, s.maxClient)
 	var wg sync.WaitGroup
 
@@ -114,3 +162,26 @@ func (s *TCP) Run(ctx context.Context) error {
 		}
 	}
 }
+
+// runAdmin menjalankan admin API di port terpisah dari listener ISO.
+func (s *TCP) runAdmin(ctx context.Context) error {
+	_, port, err := net.SplitHostPort(s.config.AdminListen)
+	if err != nil {
+		return fmt.Errorf("invalid ADMIN_LISTEN %q: %w", s.config.AdminListen, err)
+	}
+	if port == strconv.Itoa(s.config.ListenPort) {
+		return fmt.Errorf("ADMIN_LISTEN must use a different port from LISTEN (%d)", s.config.ListenPort)
+	}
+
+	server, err := admin.NewServer(s.handler, s.config.AdminToken, s.log)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := server.Run(ctx, s.config.AdminListen); err != nil {
+			s.log.Errorf("Admin API stopped: %v", err)
+		}
+	}()
+	return nil
+}
@@ -2,18 +2,23 @@ package transport
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"net"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/alfianX/danus-h2h/config"
 	"github.com/alfianX/danus-h2h/internal/handler"
+	"github.com/alfianX/danus-h2h/pkg/framer"
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewTCP(t *testing.T) {
@@ -64,51 +69,29 @@ func TestNewTCP(t *testing.T) {
 	})
 }
 
-// MockHost adalah implementasi host palsu yang menerima koneksi dari server kita.
-func MockHost(t *testing.T, hostPort int, messageToReceive []byte, messageToSend []byte) {
-	// Buat listener untuk host palsu
+// MockHost adalah host palsu yang menerima koneksi dari server kita dan
+// menahannya sampai test selesai.
+func MockHost(t *testing.T, hostPort int) {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", hostPort))
 	if err != nil {
 		t.Fatalf("failed to start mock host: %v", err)
 	}
-	defer listener.Close()
+	t.Cleanup(func() { listener.Close() })
 
-	// Tunggu koneksi dari server
-	conn, err := listener.Accept()
-	if err != nil {
-		t.Fatalf("failed to accept connection on mock host: %v", err)
-	}
-	defer conn.Close()
-
-	// 1. Terima pesan dari server
-	receivedHeader := make([]byte, 2)
-	_, err = io.ReadFull(conn, receivedHeader)
-	if err != nil {
-		t.Errorf("mock host failed to read header: %v", err)
-		return
-	}
-	// Asumsi pesan yang diterima adalah panjang header 2 byte
-	length := int(receivedHeader[0])<<8 | int(receivedHeader[1])
-	receivedMessage := make([]byte, length)
-	_, err = io.ReadFull(conn, receivedMessage)
-	if err != nil {
-		t.Errorf("mock host failed to read message body: %v", err)
-		return
-	}
-
-	// Verifikasi pesan yang diterima
-	assert.Equal(t, messageToReceive, receivedMessage, "mock host received unexpected message")
-
-	// 2. Kirim respons kembali
-	_, err = conn.Write(messageToSend)
-	if err != nil {
-		t.Errorf("mock host failed to write response: %v", err)
-	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
 }
 
 // MockNewHandler adalah stub untuk handler.NewHandler yang akan kita gunakan.
 func MockNewHandler(cnf config.Config, appLogger *logrus.Logger) (*handler.Handler, error) {
-	// Kita akan menggunakan handler sungguhan, tapi dengan mock DB.
+	// Kita akan menggunakan handler sungguhan, tapi tanpa DB dan HSM.
 	// Untuk test ini, kita bisa mengembalikan handler kosong untuk kesederhanaan.
 	return &handler.Handler{
 		Config: cnf,
@@ -118,6 +101,7 @@ func MockNewHandler(cnf config.Config, appLogger *logrus.Logger) (*handler.Handl
 }
 
 // TestServerClientE2E menguji alur lengkap dari koneksi klien hingga server merespons.
+// Handler test tidak punya lisensi, jadi request dijawab RC 15 dalam frame terminal.
 func TestServerClientE2E(t *testing.T) {
 	// Persiapan
 	clientPort := 8787
@@ -125,25 +109,14 @@ func TestServerClientE2E(t *testing.T) {
 
 	// Konfigurasi server dengan host palsu
 	cnf := config.Config{
-		ListenPort:  clientPort,
-		HostAddress: fmt.Sprintf("localhost:%d", hostPort),
+		ListenPort:        clientPort,
+		HostAddress:       fmt.Sprintf("localhost:%d", hostPort),
+		TimeoutInactivity: "5",
 	}
 	appLogger := logrus.New()
 
-	// Pesan yang akan dikirim dari klien dan diterima oleh host palsu
-	clientMessage := []byte("Hello from client")
-
-	// Pesan yang akan dikirim dari host palsu dan diterima oleh server
-	hostResponse := []byte("Hello from host")
-
-	// Persiapkan sebuah channel untuk memberi tahu bahwa test selesai
-	done := make(chan struct{})
-
-	// 1. Jalankan host palsu dalam goroutine terpisah
-	go func() {
-		MockHost(t, hostPort, clientMessage, hostResponse)
-		close(done) // Beri sinyal bahwa host palsu telah selesai
-	}()
+	// 1. Jalankan host palsu
+	MockHost(t, hostPort)
 
 	// 2. Jalankan server TCP dalam goroutine terpisah
 	ctx, cancel := context.WithCancel(context.Background())
@@ -161,25 +134,44 @@ func TestServerClientE2E(t *testing.T) {
 
 	// 3. Jalankan klien test
 	clientConn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", clientPort))
-	assert.NoError(t, err, "failed to connect to server")
+	require.NoError(t, err, "failed to connect to server")
 	defer clientConn.Close()
 
-	// Kirim pesan dari klien
-	_, err = clientConn.Write(clientMessage)
-	assert.NoError(t, err, "failed to write to server")
+	// Kirim 0200 dalam frame terminal (panjang 2 byte + TPDU)
+	request := iso8583.NewMessage(iso.TerminalSpec())
+	request.MTI("0200")
+	require.NoError(t, request.Field(3, "000000"))
+	require.NoError(t, request.Field(11, "000123"))
+	require.NoError(t, request.Field(41, "TID00001"))
+	packed, err := request.Pack()
+	require.NoError(t, err)
+	clientMessage, err := hex.DecodeString(string(packed))
+	require.NoError(t, err)
+
+	terminalFramer, err := framer.Parse("binary2+tpdu")
+	require.NoError(t, err)
+	tpdu := []byte{0x60, 0x00, 0x01, 0x00, 0x00}
+	err = terminalFramer.WriteFrame(clientConn, framer.Frame{Header: tpdu, Message: clientMessage})
+	require.NoError(t, err, "failed to write to server")
 
 	// Terima respons dari server
-	response := make([]byte, len(hostResponse))
-	_, err = io.ReadFull(clientConn, response)
-	assert.NoError(t, err, "failed to read response from server")
-
-	// Verifikasi respons
-	assert.Equal(t, hostResponse, response, "client received unexpected response")
-
-	// Tunggu sampai host palsu selesai
-	<-done
-
-	// 4. Lakukan graceful shutdown pada server
+	clientConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	frame, err := terminalFramer.ReadFrame(clientConn)
+	require.NoError(t, err, "failed to read response from server")
+
+	// Verifikasi respons, frame terminal dengan RC lisensi
+	assert.Len(t, frame.Header, framer.TPDULength)
+	response := iso8583.NewMessage(iso.TerminalSpec())
+	require.NoError(t, response.Unpack([]byte(strings.ToUpper(hex.EncodeToString(frame.Message)))))
+	rc, err := response.GetString(39)
+	require.NoError(t, err)
+	assert.Equal(t, handler.RCErrLicense, rc, "client received unexpected response")
+
+	// 4. Lakukan graceful shutdown pada server. Accept baru melihat context
+	// setelah ada koneksi masuk, jadi buka satu koneksi lagi.
 	cancel()
+	if conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", clientPort)); err == nil {
+		conn.Close()
+	}
 	wg.Wait()
 }
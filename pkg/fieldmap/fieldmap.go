@@ -0,0 +1,239 @@
+// Package fieldmap mengubah field ISO 8583 antara terminal dan host berdasarkan
+// file aturan JSON, sehingga perbedaan format per host tidak perlu ditulis di kode.
+//
+// Contoh file aturan:
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "direction": "request",
+//	      "host": ["bank_x"],
+//	      "mti": ["0200"],
+//	      "procode": ["31"],
+//	      "actions": [
+//	        {"op": "drop", "field": 43},
+//	        {"op": "set", "field": 18, "value": "6011"},
+//	        {"op": "copy", "from": 41, "field": 62},
+//	        {"op": "pad", "field": 32, "length": 11, "pad": "0"},
+//	        {"op": "format", "field": 48, "value": "{41}{42}"}
+//	      ]
+//	    }
+//	  ]
+//	}
+package fieldmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/moov-io/iso8583"
+)
+
+const (
+	DirectionRequest  = "request"  // terminal ke host
+	DirectionResponse = "response" // host ke terminal
+)
+
+const (
+	OpSet    = "set"    // isi field dengan value
+	OpCopy   = "copy"   // salin field from ke field
+	OpDrop   = "drop"   // hapus field
+	OpPad    = "pad"    // pad field sampai length dengan karakter pad
+	OpFormat = "format" // susun field dari template value, {N} diganti isi field N
+)
+
+// Rule berlaku jika semua kriteria yang diisi cocok. Kriteria kosong berarti
+// semua. Procode dicocokkan sebagai prefix.
+type Rule struct {
+	Direction string   `json:"direction"`
+	Host      []string `json:"host"`
+	MTI       []string `json:"mti"`
+	Procode   []string `json:"procode"`
+	Actions   []Action `json:"actions"`
+}
+
+type Action struct {
+	Op     string `json:"op"`
+	Field  int    `json:"field"`
+	From   int    `json:"from"`   // copy
+	Value  string `json:"value"`  // set dan format
+	Length int    `json:"length"` // pad
+	Pad    string `json:"pad"`    // pad, default "0"
+	Right  bool   `json:"right"`  // pad di kanan, default kiri
+	// Substring [Start:Start+Take] dari hasil action, Take 0 = sampai akhir
+	Start int `json:"start"`
+	Take  int `json:"take"`
+}
+
+// Engine menjalankan aturan sesuai urutan di file.
+type Engine struct {
+	Rules []Rule `json:"rules"`
+}
+
+var placeholder = regexp.MustCompile(`\{(\d+)\}`)
+
+// Load membaca file aturan JSON.
+func Load(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fieldmap -> read %s: %w", path, err)
+	}
+	return Parse(data)
+}
+
+// Parse membaca dan memvalidasi aturan JSON.
+func Parse(data []byte) (*Engine, error) {
+	var engine Engine
+	if err := json.Unmarshal(data, &engine); err != nil {
+		return nil, fmt.Errorf("fieldmap -> parse: %w", err)
+	}
+
+	for i, rule := range engine.Rules {
+		switch rule.Direction {
+		case "", DirectionRequest, DirectionResponse:
+		default:
+			return nil, fmt.Errorf("fieldmap -> rule %d: unknown direction %q", i, rule.Direction)
+		}
+		for j, action := range rule.Actions {
+			if err := action.validate(); err != nil {
+				return nil, fmt.Errorf("fieldmap -> rule %d action %d: %w", i, j, err)
+			}
+		}
+	}
+	return &engine, nil
+}
+
+func (a Action) validate() error {
+	if a.Field < 2 || a.Field > 128 {
+		return fmt.Errorf("invalid field %d", a.Field)
+	}
+	switch a.Op {
+	case OpSet, OpDrop, OpFormat:
+	case OpCopy:
+		if a.From < 2 || a.From > 128 {
+			return fmt.Errorf("invalid copy source %d", a.From)
+		}
+	case OpPad:
+		if a.Length <= 0 || len(a.Pad) > 1 {
+			return fmt.Errorf("pad needs length and a single pad character")
+		}
+	default:
+		return fmt.Errorf("unknown op %q", a.Op)
+	}
+	if a.Start < 0 || a.Take < 0 {
+		return fmt.Errorf("invalid substring %d:%d", a.Start, a.Take)
+	}
+	return nil
+}
+
+func (r Rule) match(direction, host, mti, procode string) bool {
+	if r.Direction != "" && r.Direction != direction {
+		return false
+	}
+	if len(r.Host) > 0 && !slices.Contains(r.Host, host) {
+		return false
+	}
+	if len(r.MTI) > 0 && !slices.Contains(r.MTI, mti) {
+		return false
+	}
+	if len(r.Procode) > 0 && !slices.ContainsFunc(r.Procode, func(prefix string) bool {
+		return strings.HasPrefix(procode, prefix)
+	}) {
+		return false
+	}
+	return true
+}
+
+// Apply menjalankan semua aturan yang cocok ke pesan. Nilai true berarti pesan
+// berubah dan perlu di-pack ulang.
+func (e *Engine) Apply(direction, host string, isomessage *iso8583.Message) (bool, error) {
+	if e == nil {
+		return false, nil
+	}
+
+	mti, err := isomessage.GetMTI()
+	if err != nil {
+		return false, fmt.Errorf("fieldmap -> get mti: %w", err)
+	}
+	procode, err := isomessage.GetString(3)
+	if err != nil {
+		return false, fmt.Errorf("fieldmap -> unpack procode: %w", err)
+	}
+
+	changed := false
+	for _, rule := range e.Rules {
+		if !rule.match(direction, host, mti, procode) {
+			continue
+		}
+		for _, action := range rule.Actions {
+			if err := action.apply(isomessage); err != nil {
+				return changed, fmt.Errorf("fieldmap -> %s bit %d: %w", action.Op, action.Field, err)
+			}
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func (a Action) apply(isomessage *iso8583.Message) error {
+	var value string
+	var err error
+
+	switch a.Op {
+	case OpDrop:
+		isomessage.UnsetField(a.Field)
+		return nil
+	case OpSet:
+		value = a.Value
+	case OpCopy:
+		value, err = isomessage.GetString(a.From)
+	case OpPad:
+		value, err = isomessage.GetString(a.Field)
+		value = pad(value, a.Length, a.Pad, a.Right)
+	case OpFormat:
+		value = placeholder.ReplaceAllStringFunc(a.Value, func(match string) string {
+			if err != nil {
+				return ""
+			}
+			id, _ := strconv.Atoi(match[1 : len(match)-1])
+			var field string
+			field, err = isomessage.GetString(id)
+			return field
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return isomessage.Field(a.Field, substring(value, a.Start, a.Take))
+}
+
+func pad(value string, length int, char string, right bool) string {
+	if char == "" {
+		char = "0"
+	}
+	if len(value) >= length {
+		return value
+	}
+	fill := strings.Repeat(char, length-len(value))
+	if right {
+		return value + fill
+	}
+	return fill + value
+}
+
+func substring(value string, start, take int) string {
+	if start >= len(value) {
+		return ""
+	}
+	value = value[start:]
+	if take > 0 && take < len(value) {
+		value = value[:take]
+	}
+	return value
+}
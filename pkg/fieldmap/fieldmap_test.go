@@ -0,0 +1,110 @@
+package fieldmap
+
+import (
+	"testing"
+
+	"github.com/alfianX/danus-h2h/pkg/iso"
+	"github.com/moov-io/iso8583"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `{
+  "rules": [
+    {
+      "direction": "request",
+      "host": ["bank_x"],
+      "mti": ["0200"],
+      "procode": ["31"],
+      "actions": [
+        {"op": "drop", "field": 43},
+        {"op": "set", "field": 18, "value": "6011"},
+        {"op": "copy", "from": 41, "field": 62},
+        {"op": "pad", "field": 32, "length": 11},
+        {"op": "format", "field": 48, "value": "{41}-{42}", "start": 2, "take": 10}
+      ]
+    },
+    {
+      "direction": "response",
+      "actions": [{"op": "drop", "field": 62}]
+    }
+  ]
+}`
+
+func testMessage(t *testing.T, procode string) *iso8583.Message {
+	isomessage := iso8583.NewMessage(iso.Spec87)
+	isomessage.MTI("0200")
+	for id, value := range map[int]string{
+		3:  procode,
+		32: "628",
+		41: "TID00001",
+		42: "MID000000000001",
+		43: "MERCHANT",
+	} {
+		require.NoError(t, isomessage.Field(id, value))
+	}
+	return isomessage
+}
+
+func TestApply(t *testing.T) {
+	engine, err := Parse([]byte(testRules))
+	require.NoError(t, err)
+
+	isomessage := testMessage(t, "310000")
+	changed, err := engine.Apply(DirectionRequest, "bank_x", isomessage)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	for id, want := range map[int]string{
+		18: "6011",
+		32: "00000000628",
+		43: "",
+		48: "D00001-MID",
+		62: "TID00001",
+	} {
+		value, err := isomessage.GetString(id)
+		require.NoError(t, err)
+		assert.Equal(t, want, value, "bit %d", id)
+	}
+
+	// Pesan hasil mapping tetap bisa di-pack dengan spec host
+	_, err = isomessage.Pack()
+	require.NoError(t, err)
+
+	changed, err = engine.Apply(DirectionResponse, "bank_x", isomessage)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	value, err := isomessage.GetString(62)
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+}
+
+func TestApplyNoMatch(t *testing.T) {
+	engine, err := Parse([]byte(testRules))
+	require.NoError(t, err)
+
+	for _, c := range []struct{ host, procode string }{{"bank_y", "310000"}, {"bank_x", "000000"}} {
+		changed, err := engine.Apply(DirectionRequest, c.host, testMessage(t, c.procode))
+		require.NoError(t, err)
+		assert.False(t, changed, c)
+	}
+
+	var empty *Engine
+	changed, err := empty.Apply(DirectionRequest, "bank_x", testMessage(t, "310000"))
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"rules": [{"direction": "both"}]}`,
+		`{"rules": [{"actions": [{"op": "rename", "field": 2}]}]}`,
+		`{"rules": [{"actions": [{"op": "set", "field": 1}]}]}`,
+		`{"rules": [{"actions": [{"op": "copy", "field": 62}]}]}`,
+		`{"rules": [{"actions": [{"op": "pad", "field": 32}]}]}`,
+		`{"rules": [`,
+	} {
+		_, err := Parse([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
@@ -0,0 +1,304 @@
+package framer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Jenis header panjang pesan.
+const (
+	LengthBinary2 = "binary2" // 2 byte big endian
+	LengthBinary4 = "binary4" // 4 byte big endian
+	LengthASCII4  = "ascii4"  // 4 digit desimal ASCII, misal "0123"
+	LengthBCD2    = "bcd2"    // 4 digit desimal BCD dalam 2 byte, misal 0x01 0x23
+)
+
+// Header routing setelah header panjang.
+const (
+	HeaderTPDU = "tpdu" // 5 byte: ID, destination address, source address
+	HeaderNII  = "nii"  // 2 byte NII BCD
+)
+
+// Panjang header routing dalam byte.
+const (
+	TPDULength = 5
+	NIILength  = 2
+)
+
+// DefaultMaxLength adalah batas panjang pesan binary4 jika MaxLength tidak diisi.
+const DefaultMaxLength = 1 << 20
+
+const tpduID = 0x60
+
+var (
+	ErrInvalidOptions = errors.New("invalid framer options")
+	ErrInvalidFrame   = errors.New("invalid frame")
+)
+
+// Frame adalah satu pesan tanpa header panjang dan trailer. Header berisi TPDU
+// atau NII apa adanya, kosong jika framer tidak memakai header.
+type Frame struct {
+	Header  []byte
+	Message []byte
+}
+
+// Framer membaca dan menulis pesan dari/ke koneksi terminal atau host.
+type Framer interface {
+	// ReadFrame membaca satu pesan dari r. Error baca dari r dikembalikan apa
+	// adanya (io.EOF, timeout), format yang salah dibungkus ErrInvalidFrame.
+	ReadFrame(r io.Reader) (Frame, error)
+	// Encode menyusun header panjang, header, pesan dan trailer. Header kosong
+	// diganti header default.
+	Encode(frame Frame) ([]byte, error)
+	// WriteFrame menulis hasil Encode ke w dalam satu kali Write.
+	WriteFrame(w io.Writer, frame Frame) error
+	// ReplyHeader mengembalikan header balasan untuk header request: alamat
+	// TPDU ditukar, NII dikembalikan sama.
+	ReplyHeader(request []byte) []byte
+}
+
+// Options mengatur format framing. Nilai panjang menghitung semua byte setelah
+// header panjang, yaitu header, pesan dan trailer.
+type Options struct {
+	Length    string // LengthBinary2 (default), LengthBinary4, LengthASCII4, LengthBCD2
+	Header    string // kosong, HeaderTPDU atau HeaderNII
+	Default   []byte // header default saat menulis pesan tanpa header
+	Trailer   []byte // byte penutup setelah pesan, misal ETX 0x03
+	MaxLength int    // batas nilai panjang, 0 berarti batas jenis header panjang
+}
+
+// ParseOptions membaca format framing dari konfigurasi, bagian dipisah "+":
+// jenis header panjang, lalu "tpdu" atau "nii" dengan nilai default opsional,
+// "trailer:<hex>" dan "max:<n>". Misal "binary2+tpdu", "ascii4",
+// "bcd2+nii:0003+trailer:03" atau "binary4+max:8192".
+func ParseOptions(value string) (Options, error) {
+	var opts Options
+	for i, part := range strings.Split(value, "+") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		name, arg, hasArg := strings.Cut(part, ":")
+		if i == 0 {
+			if hasArg {
+				return opts, fmt.Errorf("framer -> %w: %q", ErrInvalidOptions, part)
+			}
+			opts.Length = name
+			continue
+		}
+
+		switch name {
+		case HeaderTPDU, HeaderNII:
+			if opts.Header != "" {
+				return opts, fmt.Errorf("framer -> %w: duplicate header %q", ErrInvalidOptions, part)
+			}
+			opts.Header = name
+			if hasArg {
+				header, err := hex.DecodeString(arg)
+				if err != nil {
+					return opts, fmt.Errorf("framer -> %w: %s default %q: %v", ErrInvalidOptions, name, arg, err)
+				}
+				opts.Default = header
+			}
+		case "trailer":
+			trailer, err := hex.DecodeString(arg)
+			if err != nil || len(trailer) == 0 {
+				return opts, fmt.Errorf("framer -> %w: trailer %q", ErrInvalidOptions, arg)
+			}
+			opts.Trailer = trailer
+		case "max":
+			limit, err := strconv.Atoi(arg)
+			if err != nil || limit <= 0 {
+				return opts, fmt.Errorf("framer -> %w: max %q", ErrInvalidOptions, arg)
+			}
+			opts.MaxLength = limit
+		default:
+			return opts, fmt.Errorf("framer -> %w: unknown option %q", ErrInvalidOptions, part)
+		}
+	}
+	return opts, nil
+}
+
+// Parse membaca format framing dari konfigurasi, lihat ParseOptions.
+func Parse(value string) (Framer, error) {
+	opts, err := ParseOptions(value)
+	if err != nil {
+		return nil, err
+	}
+	return New(opts)
+}
+
+// New membuat Framer sesuai opts.
+func New(opts Options) (Framer, error) {
+	f := &lengthFramer{opts: opts}
+
+	switch opts.Length {
+	case "", LengthBinary2:
+		f.opts.Length = LengthBinary2
+		f.size, f.limit = 2, 0xFFFF
+	case LengthBinary4:
+		f.size, f.limit = 4, DefaultMaxLength
+	case LengthASCII4, LengthBCD2:
+		f.size, f.limit = 4, 9999
+		if opts.Length == LengthBCD2 {
+			f.size = 2
+		}
+	default:
+		return nil, fmt.Errorf("framer -> %w: unknown length header %q", ErrInvalidOptions, opts.Length)
+	}
+	if opts.MaxLength > 0 && opts.MaxLength < f.limit {
+		f.limit = opts.MaxLength
+	}
+
+	switch opts.Header {
+	case "":
+		if len(opts.Default) > 0 {
+			return nil, fmt.Errorf("framer -> %w: default header without tpdu or nii", ErrInvalidOptions)
+		}
+	case HeaderTPDU:
+		f.header = make([]byte, TPDULength)
+		f.header[0] = tpduID
+	case HeaderNII:
+		f.header = make([]byte, NIILength)
+	default:
+		return nil, fmt.Errorf("framer -> %w: unknown header %q", ErrInvalidOptions, opts.Header)
+	}
+	if len(opts.Default) > 0 {
+		if len(opts.Default) != len(f.header) {
+			return nil, fmt.Errorf("framer -> %w: %s default must be %d bytes", ErrInvalidOptions, opts.Header, len(f.header))
+		}
+		f.header = append([]byte(nil), opts.Default...)
+	}
+
+	if len(f.header)+len(opts.Trailer) > f.limit {
+		return nil, fmt.Errorf("framer -> %w: max length %d too small", ErrInvalidOptions, f.limit)
+	}
+	return f, nil
+}
+
+type lengthFramer struct {
+	opts   Options
+	size   int    // jumlah byte header panjang
+	limit  int    // nilai panjang terbesar
+	header []byte // header default, nil jika tanpa header
+}
+
+func (f *lengthFramer) ReadFrame(r io.Reader) (Frame, error) {
+	prefix := make([]byte, f.size)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return Frame{}, err
+	}
+	length, err := f.decodeLength(prefix)
+	if err != nil {
+		return Frame{}, err
+	}
+	overhead := len(f.header) + len(f.opts.Trailer)
+	if length > f.limit || length < overhead {
+		return Frame{}, fmt.Errorf("framer -> %w: length %d outside %d..%d", ErrInvalidFrame, length, overhead, f.limit)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return Frame{}, err
+	}
+
+	trailer := body[length-len(f.opts.Trailer):]
+	if !bytes.Equal(trailer, f.opts.Trailer) {
+		return Frame{}, fmt.Errorf("framer -> %w: trailer %X, expected %X", ErrInvalidFrame, trailer, f.opts.Trailer)
+	}
+
+	frame := Frame{Message: body[len(f.header) : length-len(f.opts.Trailer)]}
+	if len(f.header) > 0 {
+		frame.Header = body[:len(f.header)]
+	}
+	return frame, nil
+}
+
+func (f *lengthFramer) Encode(frame Frame) ([]byte, error) {
+	header := frame.Header
+	if len(header) == 0 {
+		header = f.header
+	}
+	if len(header) != len(f.header) {
+		return nil, fmt.Errorf("framer -> %w: header %X, expected %d bytes", ErrInvalidFrame, header, len(f.header))
+	}
+
+	length := len(header) + len(frame.Message) + len(f.opts.Trailer)
+	if length > f.limit {
+		return nil, fmt.Errorf("framer -> %w: length %d exceeds %d", ErrInvalidFrame, length, f.limit)
+	}
+
+	out := make([]byte, 0, f.size+length)
+	out = f.appendLength(out, length)
+	out = append(out, header...)
+	out = append(out, frame.Message...)
+	out = append(out, f.opts.Trailer...)
+	return out, nil
+}
+
+func (f *lengthFramer) WriteFrame(w io.Writer, frame Frame) error {
+	out, err := f.Encode(frame)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func (f *lengthFramer) ReplyHeader(request []byte) []byte {
+	if len(request) != len(f.header) {
+		request = f.header
+	}
+	reply := append([]byte(nil), request...)
+	if f.opts.Header == HeaderTPDU {
+		copy(reply[1:3], request[3:5])
+		copy(reply[3:5], request[1:3])
+	}
+	return reply
+}
+
+func (f *lengthFramer) decodeLength(prefix []byte) (int, error) {
+	switch f.opts.Length {
+	case LengthBinary4:
+		length := binary.BigEndian.Uint32(prefix)
+		if length > uint32(f.limit) {
+			return 0, fmt.Errorf("framer -> %w: length %d exceeds %d", ErrInvalidFrame, length, f.limit)
+		}
+		return int(length), nil
+	case LengthASCII4:
+		length := 0
+		for _, c := range prefix {
+			if c < '0' || c > '9' {
+				return 0, fmt.Errorf("framer -> %w: length header %q is not decimal", ErrInvalidFrame, prefix)
+			}
+			length = length*10 + int(c-'0')
+		}
+		return length, nil
+	case LengthBCD2:
+		length := 0
+		for _, c := range prefix {
+			if c>>4 > 9 || c&0x0F > 9 {
+				return 0, fmt.Errorf("framer -> %w: length header %X is not bcd", ErrInvalidFrame, prefix)
+			}
+			length = length*100 + int(c>>4)*10 + int(c&0x0F)
+		}
+		return length, nil
+	}
+	return int(binary.BigEndian.Uint16(prefix)), nil
+}
+
+func (f *lengthFramer) appendLength(out []byte, length int) []byte {
+	switch f.opts.Length {
+	case LengthBinary4:
+		return binary.BigEndian.AppendUint32(out, uint32(length))
+	case LengthASCII4:
+		return fmt.Appendf(out, "%04d", length)
+	case LengthBCD2:
+		digits := fmt.Sprintf("%04d", length)
+		return append(out, (digits[0]-'0')<<4|(digits[1]-'0'), (digits[2]-'0')<<4|(digits[3]-'0'))
+	}
+	return binary.BigEndian.AppendUint16(out, uint16(length))
+}
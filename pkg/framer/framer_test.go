@@ -0,0 +1,177 @@
+package framer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzFramers dipakai semua fuzz test agar setiap jenis header panjang dan
+// header routing ikut diuji.
+var fuzzFramers = []string{
+	"binary2",
+	"binary2+tpdu",
+	"binary4+nii",
+	"ascii4+trailer:03",
+	"bcd2+tpdu+trailer:0304",
+	"binary2+max:64",
+}
+
+func mustParse(t testing.TB, value string) Framer {
+	f, err := Parse(value)
+	require.NoError(t, err)
+	return f
+}
+
+func TestEncode(t *testing.T) {
+	message := []byte("0800ABC")
+	tests := []struct {
+		value  string
+		header []byte
+		want   []byte
+	}{
+		{"binary2", nil, append([]byte{0x00, 0x07}, message...)},
+		{"binary4", nil, append([]byte{0x00, 0x00, 0x00, 0x07}, message...)},
+		{"ascii4", nil, append([]byte("0007"), message...)},
+		{"bcd2", nil, append([]byte{0x00, 0x07}, message...)},
+		{"binary2+tpdu", []byte{0x60, 0x00, 0x01, 0x00, 0x02}, append([]byte{0x00, 0x0C, 0x60, 0x00, 0x01, 0x00, 0x02}, message...)},
+		{"binary2+tpdu", nil, append([]byte{0x00, 0x0C, 0x60, 0x00, 0x00, 0x00, 0x00}, message...)},
+		{"bcd2+nii:0003", nil, append([]byte{0x00, 0x09, 0x00, 0x03}, message...)},
+		{"ascii4+trailer:03", nil, append(append([]byte("0008"), message...), 0x03)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			f := mustParse(t, tt.value)
+			out, err := f.Encode(Frame{Header: tt.header, Message: message})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out)
+
+			frame, err := f.ReadFrame(bytes.NewReader(out))
+			require.NoError(t, err)
+			assert.Equal(t, message, frame.Message)
+		})
+	}
+}
+
+func TestBCDLength(t *testing.T) {
+	f := mustParse(t, "bcd2")
+	out, err := f.Encode(Frame{Message: make([]byte, 1234)})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x12, 0x34}, out[:2])
+
+	_, err = f.ReadFrame(bytes.NewReader([]byte{0x1A, 0x00}))
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		input []byte
+		err   error
+	}{
+		{"binary2", nil, io.EOF},
+		{"binary2", []byte{0x00}, io.ErrUnexpectedEOF},
+		{"binary2", []byte{0x00, 0x05, 'A'}, io.ErrUnexpectedEOF},
+		{"binary2+max:4", []byte{0x00, 0x05, 'A', 'B', 'C', 'D', 'E'}, ErrInvalidFrame},
+		{"binary4", []byte{0xFF, 0xFF, 0xFF, 0xFF}, ErrInvalidFrame},
+		{"ascii4", []byte("00X1A"), ErrInvalidFrame},
+		{"binary2+tpdu", []byte{0x00, 0x03, 0x60, 0x00, 0x00}, ErrInvalidFrame},
+		{"binary2+trailer:03", []byte{0x00, 0x02, 'A', 0x04}, ErrInvalidFrame},
+	}
+	for _, tt := range tests {
+		f := mustParse(t, tt.value)
+		_, err := f.ReadFrame(bytes.NewReader(tt.input))
+		assert.ErrorIs(t, err, tt.err, "%s % X", tt.value, tt.input)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	_, err := mustParse(t, "ascii4").Encode(Frame{Message: make([]byte, 10000)})
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+
+	_, err = mustParse(t, "binary2+tpdu").Encode(Frame{Header: []byte{0x60}, Message: []byte("A")})
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+}
+
+func TestReplyHeader(t *testing.T) {
+	tpdu := mustParse(t, "binary2+tpdu")
+	assert.Equal(t, []byte{0x60, 0x00, 0x02, 0x00, 0x01}, tpdu.ReplyHeader([]byte{0x60, 0x00, 0x01, 0x00, 0x02}))
+	assert.Equal(t, []byte{0x60, 0x00, 0x00, 0x00, 0x00}, tpdu.ReplyHeader(nil))
+
+	nii := mustParse(t, "binary2+nii:0001")
+	assert.Equal(t, []byte{0x00, 0x03}, nii.ReplyHeader([]byte{0x00, 0x03}))
+	assert.Equal(t, []byte{0x00, 0x01}, nii.ReplyHeader(nil))
+
+	assert.Empty(t, mustParse(t, "binary2").ReplyHeader(nil))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, value := range []string{
+		"binary3",
+		"binary2:1",
+		"binary2+tpdu+nii",
+		"binary2+tpdu:60",
+		"binary2+nii:zz",
+		"binary2+trailer:",
+		"binary2+max:0",
+		"binary2+tpdu+max:4",
+		"binary2+crc",
+	} {
+		_, err := Parse(value)
+		assert.True(t, errors.Is(err, ErrInvalidOptions), value)
+	}
+
+	f, err := Parse("")
+	require.NoError(t, err)
+	out, err := f.Encode(Frame{Message: []byte("A")})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x01, 'A'}, out)
+}
+
+// FuzzReadFrame memastikan input sembarang tidak membuat panic dan setiap frame
+// yang berhasil dibaca ditulis ulang menjadi byte yang sama.
+func FuzzReadFrame(f *testing.F) {
+	f.Add(uint8(0), []byte{0x00, 0x03, 'A', 'B', 'C'})
+	f.Add(uint8(1), []byte{0x00, 0x06, 0x60, 0x00, 0x01, 0x00, 0x02, 'A'})
+	f.Add(uint8(2), []byte{0x00, 0x00, 0x00, 0x03, 0x00, 0x01, 'A'})
+	f.Add(uint8(3), []byte("0002A\x03"))
+	f.Add(uint8(4), []byte{0x00, 0x08, 0x60, 0x00, 0x00, 0x00, 0x00, 'A', 0x03, 0x04})
+	f.Add(uint8(5), []byte{0xFF, 0xFF})
+
+	f.Fuzz(func(t *testing.T, kind uint8, data []byte) {
+		fr := mustParse(t, fuzzFramers[int(kind)%len(fuzzFramers)])
+		frame, err := fr.ReadFrame(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		out, err := fr.Encode(frame)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(data, out), "encoded % X is not a prefix of % X", out, data)
+	})
+}
+
+// FuzzFrameRoundTrip memastikan pesan yang bisa di-encode terbaca kembali utuh.
+func FuzzFrameRoundTrip(f *testing.F) {
+	f.Add(uint8(0), []byte("0800ABC"), []byte{})
+	f.Add(uint8(1), []byte("0200"), []byte{0x60, 0x00, 0x01, 0x00, 0x02})
+	f.Add(uint8(2), []byte{}, []byte{0x00, 0x03})
+	f.Add(uint8(3), []byte{0x03, 0x03}, []byte{})
+
+	f.Fuzz(func(t *testing.T, kind uint8, message, header []byte) {
+		fr := mustParse(t, fuzzFramers[int(kind)%len(fuzzFramers)])
+		out, err := fr.Encode(Frame{Header: header, Message: message})
+		if err != nil {
+			return
+		}
+		frame, err := fr.ReadFrame(bytes.NewReader(out))
+		require.NoError(t, err)
+		assert.Equal(t, string(message), string(frame.Message))
+		if len(header) > 0 {
+			assert.Equal(t, header, frame.Header)
+		}
+	})
+}
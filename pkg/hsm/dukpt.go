@@ -0,0 +1,94 @@
+package hsm
+
+import (
+	"crypto/des"
+	"encoding/hex"
+	"fmt"
+)
+
+const ksnLength = 10
+
+var (
+	keyMask = []byte{0xC0, 0xC0, 0xC0, 0xC0, 0, 0, 0, 0, 0xC0, 0xC0, 0xC0, 0xC0, 0, 0, 0, 0}
+	pinMask = []byte{0, 0, 0, 0, 0, 0, 0, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0xFF}
+)
+
+// DukptPinKey menurunkan PIN encryption key ANSI X9.24-1 dari BDK clear dan KSN
+// (20 hex).
+func DukptPinKey(bdk []byte, ksn string) ([]byte, error) {
+	ksnBytes, err := hex.DecodeString(ksn)
+	if err != nil || len(ksnBytes) != ksnLength {
+		return nil, fmt.Errorf("%w: ksn %q", ErrInvalidKey, ksn)
+	}
+
+	ipek, err := initialKey(bdk, ksnBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 8 byte kanan KSN dengan counter 21 bit dikosongkan
+	register := append([]byte{}, ksnBytes[2:]...)
+	register[5] &= 0xE0
+	register[6], register[7] = 0, 0
+	counter := uint32(ksnBytes[7]&0x1F)<<16 | uint32(ksnBytes[8])<<8 | uint32(ksnBytes[9])
+
+	key := ipek
+	for shift := uint32(1 << 20); shift > 0; shift >>= 1 {
+		if counter&shift == 0 {
+			continue
+		}
+		register[5] |= byte(shift >> 16)
+		register[6] |= byte(shift >> 8)
+		register[7] |= byte(shift)
+		if key, err = nonReversibleKey(key, register); err != nil {
+			return nil, err
+		}
+	}
+
+	return xor(key, pinMask), nil
+}
+
+// initialKey membuat IPEK dari BDK dan KSN dengan counter dikosongkan.
+func initialKey(bdk, ksn []byte) ([]byte, error) {
+	data := append([]byte{}, ksn[:8]...)
+	data[7] &= 0xE0
+
+	ipek := make([]byte, 16)
+	left, err := tripleDES(bdk)
+	if err != nil {
+		return nil, err
+	}
+	left.Encrypt(ipek[:8], data)
+
+	right, err := tripleDES(xor(bdk, keyMask))
+	if err != nil {
+		return nil, err
+	}
+	right.Encrypt(ipek[8:], data)
+	return ipek, nil
+}
+
+func nonReversibleKey(key, data []byte) ([]byte, error) {
+	out := make([]byte, 16)
+	left, err := nonReversibleHalf(xor(key, keyMask), data)
+	if err != nil {
+		return nil, err
+	}
+	right, err := nonReversibleHalf(key, data)
+	if err != nil {
+		return nil, err
+	}
+	copy(out, left)
+	copy(out[8:], right)
+	return out, nil
+}
+
+func nonReversibleHalf(key, data []byte) ([]byte, error) {
+	block, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	out := xor(data, key[8:])
+	block.Encrypt(out, out)
+	return xor(out, key[8:]), nil
+}
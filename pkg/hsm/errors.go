@@ -0,0 +1,79 @@
+package hsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Jenis error HSM, dicek dengan errors.Is untuk menentukan response code ke terminal.
+var (
+	ErrPinBlockFormat  = errors.New("invalid pin block format")
+	ErrMacVerification = errors.New("mac verification failure")
+	ErrKeyParity       = errors.New("key parity error")
+	ErrKeyNotFound     = errors.New("key not found")
+	ErrInvalidKey      = errors.New("invalid key")
+	ErrHsmUnavailable  = errors.New("hsm unavailable")
+	ErrHsmFailure      = errors.New("hsm failure")
+)
+
+// thalesErrors memetakan error code Thales ke jenis error. Code yang tidak ada
+// di sini dianggap ErrHsmFailure.
+var thalesErrors = map[string]error{
+	"01": ErrInvalidPinBlock, // verification failure
+	"10": ErrKeyParity,       // source key parity
+	"11": ErrKeyParity,       // destination key parity
+	"12": ErrKeyNotFound,     // user storage kosong
+	"13": ErrKeyParity,       // LMK parity
+	"20": ErrInvalidPinBlock, // PIN block tidak berisi nilai valid
+	"21": ErrKeyNotFound,     // index user storage tidak valid
+	"23": ErrPinBlockFormat,
+	"24": ErrInvalidPinBlock, // panjang PIN kurang dari 4 atau lebih dari 12
+	"26": ErrInvalidKey,      // key scheme
+	"27": ErrInvalidKey,      // panjang key tidak sesuai
+	"28": ErrInvalidKey,      // key type
+	"29": ErrInvalidKey,      // fungsi key tidak diizinkan
+}
+
+// Error adalah kegagalan command HSM beserta error code aslinya ("io" untuk
+// kegagalan koneksi).
+type Error struct {
+	Command string
+	Code    string
+	Kind    error
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("hsm -> %s: %v", e.Command, e.Err)
+	}
+	return fmt.Sprintf("hsm -> %s: error code %s (%v)", e.Command, e.Code, e.Kind)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+func thalesError(command, code string) *Error {
+	// Pada command verifikasi MAC, code 01 berarti MAC tidak cocok
+	if command == "M8" && code == "01" {
+		return &Error{Command: command, Code: code, Kind: ErrMacVerification}
+	}
+	kind, ok := thalesErrors[code]
+	if !ok {
+		kind = ErrHsmFailure
+	}
+	return &Error{Command: command, Code: code, Kind: kind}
+}
+
+// ErrorCode mengembalikan error code HSM dari err, kosong jika bukan error HSM.
+func ErrorCode(err error) string {
+	var hsmErr *Error
+	if errors.As(err, &hsmErr) {
+		return hsmErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package hsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	TypeThales   = "thales"
+	TypeSoftware = "software"
+)
+
+// HSM adalah operasi kunci dan PIN yang dibutuhkan gateway. Semua kunci yang
+// keluar masuk dalam bentuk terenkripsi LMK (skema "U" + 32 hex), kecuali TWK
+// yang terenkripsi TMK untuk dikirim ke terminal.
+type HSM interface {
+	// ImportZPK mengubah ZPK dari host (terenkripsi ZMK) menjadi ZPK di bawah LMK,
+	// beserta key check value (6 hex).
+	ImportZPK(ctx context.Context, zmk, zpk string) (key, kcv string, err error)
+	// GenerateTerminalKeys membuat kunci PIN terminal baru, dikembalikan sebagai
+	// TWK (di bawah TMK, untuk terminal), TPK (di bawah LMK, disimpan di DB) dan
+	// KCV kunci tersebut.
+	GenerateTerminalKeys(ctx context.Context, tmk string) (twk, tpk, kcv string, err error)
+	// TranslatePIN mengubah PIN block dari TPK ke ZPK, sekaligus format PIN
+	// block terminal ke format host.
+	TranslatePIN(ctx context.Context, tpk, zpk, pinBlock, pan string, formats PinFormats) (string, error)
+	// TranslatePINDukpt mengubah PIN block dari kunci DUKPT (BDK dan KSN
+	// terminal) ke ZPK. PIN block DUKPT dari terminal selalu ISO format 0.
+	TranslatePINDukpt(ctx context.Context, bdk, ksn, zpk, pinBlock, pan string, formats PinFormats) (string, error)
+	// GenerateTAK membuat kunci MAC terminal, dikembalikan di bawah TMK (untuk
+	// terminal) dan di bawah LMK (disimpan di DB) beserta KCV.
+	GenerateTAK(ctx context.Context, tmk string) (takTmk, tak, kcv string, err error)
+	// ImportZAK mengubah ZAK dari host (terenkripsi ZMK) menjadi ZAK di bawah LMK
+	// beserta KCV.
+	ImportZAK(ctx context.Context, zmk, zak string) (key, kcv string, err error)
+	// KeyCheckValue menghitung KCV (6 hex) dari kunci di bawah LMK.
+	KeyCheckValue(ctx context.Context, keyType, key string) (string, error)
+	// VerifyMAC memeriksa MAC ANSI X9.19 (ISO 9797-1 algoritma 3, padding 1) dengan TAK.
+	VerifyMAC(ctx context.Context, tak string, data []byte, mac string) error
+	// GenerateMAC membuat MAC ANSI X9.19 dengan ZAK.
+	GenerateMAC(ctx context.Context, zak string, data []byte) (string, error)
+}
+
+// Kode key type untuk KeyCheckValue, mengikuti kode command BU Thales.
+const (
+	KeyTypeZPK = "01"
+	KeyTypeTPK = "02"
+	KeyTypeTAK = "03"
+	KeyTypeZAK = "08"
+)
+
+type Options struct {
+	Type     string        // thales | software
+	Address  string        // host:port HSM Thales, beberapa alamat dipisah koma untuk failover
+	PoolSize int           // koneksi terbuka maksimal ke HSM Thales
+	Timeout  time.Duration // batas waktu tiap command
+	LMK      string        // hex LMK untuk software HSM
+}
+
+func New(opts Options) (HSM, error) {
+	switch opts.Type {
+	case TypeThales, "":
+		addresses := ParseAddresses(opts.Address)
+		if len(addresses) == 0 {
+			return nil, errors.New("hsm -> HSM_ADDRESS is required")
+		}
+		return NewThales(PoolOptions{
+			Addresses: addresses,
+			Size:      opts.PoolSize,
+			Timeout:   opts.Timeout,
+		})
+	case TypeSoftware:
+		return NewSoftware(opts.LMK)
+	default:
+		return nil, fmt.Errorf("hsm -> unknown type %q", opts.Type)
+	}
+}
+
+// AccountNumber mengambil 12 digit PAN paling kanan tanpa check digit,
+// dipakai untuk PIN block ISO format 0 dan 3.
+func AccountNumber(pan string) (string, error) {
+	if len(pan) < 13 {
+		return "", fmt.Errorf("hsm -> pan too short: %d", len(pan))
+	}
+	return pan[len(pan)-13 : len(pan)-1], nil
+}
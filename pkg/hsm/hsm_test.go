@@ -0,0 +1,292 @@
+package hsm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/des"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testLMK      = "0123456789ABCDEFFEDCBA9876543210"
+	testPAN      = "4111111111111111"
+	clearTMK     = "0123456789ABCDEF0123456789ABCDEF"
+	clearZMK     = "89ABCDEF0123456789ABCDEF01234567"
+	clearZPK     = "FEDCBA98765432100123456789ABCDEF"
+	testPINClear = "1234"
+)
+
+func encryptClear(t *testing.T, clearKey string, data []byte) string {
+	key, err := hex.DecodeString(clearKey)
+	require.NoError(t, err)
+	block, err := tripleDES(key)
+	require.NoError(t, err)
+	return encryptHex(block, data)
+}
+
+func decryptClear(t *testing.T, clearKey, data string) []byte {
+	key, err := hex.DecodeString(clearKey)
+	require.NoError(t, err)
+	block, err := tripleDES(key)
+	require.NoError(t, err)
+	encrypted, err := hex.DecodeString(data)
+	require.NoError(t, err)
+	out := make([]byte, len(encrypted))
+	for i := 0; i < len(out); i += des.BlockSize {
+		block.Decrypt(out[i:i+des.BlockSize], encrypted[i:i+des.BlockSize])
+	}
+	return out
+}
+
+func TestISO0(t *testing.T) {
+	block, err := EncodeISO0(testPINClear, "411111111111")
+	require.NoError(t, err)
+	assert.Equal(t, "041275EEEEEEEEEE", strings.ToUpper(hex.EncodeToString(block)))
+
+	pin, err := DecodeISO0(block, "411111111111")
+	require.NoError(t, err)
+	assert.Equal(t, testPINClear, pin)
+
+	_, err = DecodeISO0(block, "411111111112")
+	assert.ErrorIs(t, err, ErrInvalidPinBlock)
+
+	_, err = EncodeISO0("12", "411111111111")
+	assert.ErrorIs(t, err, ErrInvalidPinBlock)
+}
+
+func TestPinBlockFormats(t *testing.T) {
+	for _, format := range []string{PinFormatISO0, PinFormatISO1, PinFormatISO3} {
+		block, err := EncodePinBlock(format, testPINClear, testPAN)
+		require.NoError(t, err, format)
+		pin, err := DecodePinBlock(format, block, testPAN)
+		require.NoError(t, err, format)
+		assert.Equal(t, testPINClear, pin, format)
+	}
+
+	// Format 1 tanpa PAN, format 3 dengan fill A-F
+	block, err := EncodePinBlock(PinFormatISO1, testPINClear, "")
+	require.NoError(t, err)
+	assert.Equal(t, "141234", strings.ToUpper(hex.EncodeToString(block))[:6])
+	block, err = EncodePinBlock(PinFormatISO3, testPINClear, testPAN)
+	require.NoError(t, err)
+	_, err = DecodePinBlock(PinFormatISO0, block, testPAN)
+	assert.ErrorIs(t, err, ErrInvalidPinBlock)
+
+	_, err = EncodePinBlock(PinFormatISO4, testPINClear, testPAN)
+	assert.ErrorIs(t, err, ErrPinBlockFormat)
+}
+
+func TestISO4(t *testing.T) {
+	key, err := aes.NewCipher(mustHex(t, clearZPK))
+	require.NoError(t, err)
+
+	block, err := EncryptISO4(key, testPINClear, testPAN)
+	require.NoError(t, err)
+	assert.Len(t, block, 16)
+
+	pin, err := DecryptISO4(key, block, testPAN)
+	require.NoError(t, err)
+	assert.Equal(t, testPINClear, pin)
+
+	_, err = DecryptISO4(key, block, "4111111111111112")
+	assert.ErrorIs(t, err, ErrInvalidPinBlock)
+
+	// PAN field: M = panjang PAN - 12, lalu PAN dan pad 0
+	field, err := iso4PanField("1234567890123456789")
+	require.NoError(t, err)
+	assert.Equal(t, "71234567890123456789000000000000", strings.ToUpper(hex.EncodeToString(field)))
+}
+
+func TestParsePinFormat(t *testing.T) {
+	for name, want := range map[string]string{"": PinFormatISO0, "iso1": PinFormatISO1, "ISO-3": PinFormatISO3, "48": PinFormatISO4} {
+		format, err := ParsePinFormat(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, format, name)
+	}
+	_, err := ParsePinFormat("ISO2")
+	assert.Error(t, err)
+}
+
+// TestSoftwareLogonAndPinFlow menjalankan alur logon terminal, key exchange
+// dengan host dan translate PIN seperti di handler.
+func TestSoftwareLogonAndPinFlow(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSoftware(testLMK)
+	require.NoError(t, err)
+
+	tmk, err := s.WrapKey(clearTMK)
+	require.NoError(t, err)
+	zmk, err := s.WrapKey(clearZMK)
+	require.NoError(t, err)
+
+	// Logon terminal: TWK dikirim ke terminal, TPK disimpan di DB
+	twk, tpk, tpkKCV, err := s.GenerateTerminalKeys(ctx, tmk)
+	require.NoError(t, err)
+	storedKCV, err := s.KeyCheckValue(ctx, KeyTypeTPK, tpk)
+	require.NoError(t, err)
+	assert.Equal(t, tpkKCV, storedKCV)
+	assert.Len(t, twk, 32)
+	assert.True(t, strings.HasPrefix(tpk, "U"))
+	clearTPK := strings.ToUpper(hex.EncodeToString(decryptClear(t, clearTMK, twk)))
+
+	// Key exchange host: ZPK dikirim di bawah ZMK
+	zpkUnderZMK := encryptClear(t, clearZMK, mustHex(t, clearZPK))
+	zpk, kcv, err := s.ImportZPK(ctx, zmk, zpkUnderZMK)
+	require.NoError(t, err)
+	expectedKCV, err := KeyCheckValue(mustHex(t, clearZPK))
+	require.NoError(t, err)
+	assert.Equal(t, expectedKCV, kcv)
+
+	// Terminal membuat PIN block dengan TPK, host membuka dengan ZPK
+	account, err := AccountNumber(testPAN)
+	require.NoError(t, err)
+	clearBlock, err := EncodeISO0(testPINClear, account)
+	require.NoError(t, err)
+	pinBlock := encryptClear(t, clearTPK, clearBlock)
+
+	translated, err := s.TranslatePIN(ctx, tpk, zpk, pinBlock, testPAN, PinFormats{})
+	require.NoError(t, err)
+	pin, err := DecodeISO0(decryptClear(t, clearZPK, translated), account)
+	require.NoError(t, err)
+	assert.Equal(t, testPINClear, pin)
+
+	// Kunci tanpa parity ganjil ditolak sebagai masalah kunci, bukan PIN
+	badTPK, err := s.WrapKey("00000000000000000000000000000000")
+	require.NoError(t, err)
+	_, err = s.TranslatePIN(ctx, badTPK, zpk, pinBlock, testPAN, PinFormats{})
+	assert.ErrorIs(t, err, ErrKeyParity)
+
+	// PIN block dari kunci lain harus ditolak
+	wrong := encryptClear(t, clearZMK, clearBlock)
+	_, err = s.TranslatePIN(ctx, tpk, zpk, wrong, testPAN, PinFormats{})
+	assert.ErrorIs(t, err, ErrInvalidPinBlock)
+
+	// Terminal ISO 0 ke host ISO 4 (AES) lalu kembali ke ISO 3
+	translated, err = s.TranslatePIN(ctx, tpk, zpk, pinBlock, testPAN, PinFormats{Destination: PinFormatISO4})
+	require.NoError(t, err)
+	assert.Len(t, translated, 32)
+	aesZPK, err := aes.NewCipher(mustHex(t, clearZPK))
+	require.NoError(t, err)
+	pin, err = DecryptISO4(aesZPK, mustHex(t, translated), testPAN)
+	require.NoError(t, err)
+	assert.Equal(t, testPINClear, pin)
+
+	zpkAsSource, err := s.WrapKey(clearZPK)
+	require.NoError(t, err)
+	translated, err = s.TranslatePIN(ctx, zpkAsSource, zpk, translated, testPAN, PinFormats{Source: PinFormatISO4, Destination: PinFormatISO3})
+	require.NoError(t, err)
+	pin, err = DecodePinBlock(PinFormatISO3, decryptClear(t, clearZPK, translated), testPAN)
+	require.NoError(t, err)
+	assert.Equal(t, testPINClear, pin)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{Type: TypeThales})
+	assert.Error(t, err)
+	_, err = New(Options{Type: TypeSoftware, LMK: "1234"})
+	assert.Error(t, err)
+	_, err = New(Options{Type: "luna"})
+	assert.Error(t, err)
+
+	h, err := New(Options{Type: TypeSoftware, LMK: testLMK})
+	require.NoError(t, err)
+	assert.IsType(t, &Software{}, h)
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestRetailMAC(t *testing.T) {
+	key := mustHex(t, "0123456789ABCDEFFEDCBA9876543210")
+	mac, err := RetailMAC(key, []byte("Now is the time for all "))
+	require.NoError(t, err)
+	assert.Equal(t, "A1C72E74EA3FA9B6", strings.ToUpper(hex.EncodeToString(mac)))
+
+	// Blok terakhir sama dengan 3DES dari CBC single DES blok sebelumnya
+	data := []byte("1234567890ABCDEFGHIJ")
+	mac, err = RetailMAC(key, data)
+	require.NoError(t, err)
+	single, err := des.NewCipher(key[:8])
+	require.NoError(t, err)
+	chain := make([]byte, 8)
+	single.Encrypt(chain, data[:8])
+	for i := range 8 {
+		chain[i] ^= data[8+i]
+	}
+	single.Encrypt(chain, chain)
+	last := append(append([]byte{}, data[16:]...), 0, 0, 0, 0)
+	for i := range 8 {
+		last[i] ^= chain[i]
+	}
+	assert.Equal(t, encryptClear(t, "0123456789ABCDEFFEDCBA9876543210", last), strings.ToUpper(hex.EncodeToString(mac)))
+}
+
+func TestSoftwareMAC(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSoftware(testLMK)
+	require.NoError(t, err)
+	tmk, err := s.WrapKey(clearTMK)
+	require.NoError(t, err)
+
+	takTmk, tak, _, err := s.GenerateTAK(ctx, tmk)
+	require.NoError(t, err)
+	clearTAK := decryptClear(t, clearTMK, takTmk)
+
+	data := []byte("0200 message body")
+	mac, err := RetailMAC(clearTAK, data)
+	require.NoError(t, err)
+
+	assert.NoError(t, s.VerifyMAC(ctx, tak, data, hex.EncodeToString(mac)))
+	assert.ErrorIs(t, s.VerifyMAC(ctx, tak, []byte("0200 message bodY"), hex.EncodeToString(mac)), ErrMacVerification)
+
+	generated, err := s.GenerateMAC(ctx, tak, data)
+	require.NoError(t, err)
+	assert.Equal(t, strings.ToUpper(hex.EncodeToString(mac)), generated)
+}
+
+func TestDukptPinKey(t *testing.T) {
+	bdk := mustHex(t, "0123456789ABCDEFFEDCBA9876543210")
+
+	ipek, err := initialKey(bdk, mustHex(t, "FFFF9876543210E00001"))
+	require.NoError(t, err)
+	assert.Equal(t, "6AC292FAA1315B4D858AB3A3D7D5933A", strings.ToUpper(hex.EncodeToString(ipek)))
+
+	// Vektor ANSI X9.24-1: PIN 1234, PAN 4012345678909
+	pek, err := DukptPinKey(bdk, "FFFF9876543210E00001")
+	require.NoError(t, err)
+	clearBlock, err := EncodeISO0(testPINClear, "401234567890")
+	require.NoError(t, err)
+	assert.Equal(t, "1B9C1845EB993A7A", encryptClear(t, hex.EncodeToString(pek), clearBlock))
+
+	_, err = DukptPinKey(bdk, "FFFF98")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestSoftwareTranslatePINDukpt(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSoftware(testLMK)
+	require.NoError(t, err)
+
+	bdk, err := s.WrapKey("0123456789ABCDEFFEDCBA9876543210")
+	require.NoError(t, err)
+	zpk, err := s.WrapKey(clearZPK)
+	require.NoError(t, err)
+
+	translated, err := s.TranslatePINDukpt(ctx, bdk, "FFFF9876543210E00001", zpk, "1B9C1845EB993A7A", "4012345678909", PinFormats{})
+	require.NoError(t, err)
+	pin, err := DecodeISO0(decryptClear(t, clearZPK, translated), "401234567890")
+	require.NoError(t, err)
+	assert.Equal(t, testPINClear, pin)
+
+	// KSN lain menghasilkan kunci lain sehingga PIN block tidak valid
+	_, err = s.TranslatePINDukpt(ctx, bdk, "FFFF9876543210E00002", zpk, "1B9C1845EB993A7A", "4012345678909", PinFormats{})
+	assert.ErrorIs(t, err, ErrInvalidPinBlock)
+}
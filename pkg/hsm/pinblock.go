@@ -0,0 +1,286 @@
+package hsm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidPinBlock = errors.New("invalid pin block")
+
+// Format PIN block ISO 9564, nilainya mengikuti kode format Thales.
+const (
+	PinFormatISO0 = "01"
+	PinFormatISO1 = "05"
+	PinFormatISO3 = "47"
+	PinFormatISO4 = "48" // AES, PIN block 16 byte
+)
+
+var pinFormatNames = map[string]string{
+	"ISO0": PinFormatISO0,
+	"ISO1": PinFormatISO1,
+	"ISO3": PinFormatISO3,
+	"ISO4": PinFormatISO4,
+}
+
+// ParsePinFormat menerima nama format (ISO0, ISO1, ISO3, ISO4) atau kode
+// Thales-nya. Nilai kosong berarti ISO 0.
+func ParsePinFormat(name string) (string, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" {
+		return PinFormatISO0, nil
+	}
+	if format, ok := pinFormatNames[strings.ReplaceAll(name, "-", "")]; ok {
+		return format, nil
+	}
+	for _, format := range pinFormatNames {
+		if name == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("hsm -> unknown pin block format %q", name)
+}
+
+// PinFormats adalah format PIN block dari terminal (Source) dan ke host
+// (Destination). Format kosong dianggap ISO 0.
+type PinFormats struct {
+	Source      string
+	Destination string
+}
+
+func (f PinFormats) withDefaults() PinFormats {
+	if f.Source == "" {
+		f.Source = PinFormatISO0
+	}
+	if f.Destination == "" {
+		f.Destination = PinFormatISO0
+	}
+	return f
+}
+
+// EncodeISO0 membentuk PIN block clear ISO 9564 format 0 dari PIN dan 12 digit
+// account number.
+func EncodeISO0(pin, account string) ([]byte, error) {
+	field, err := encodePinField('0', pin, 16)
+	if err != nil {
+		return nil, err
+	}
+	pan, err := accountField(account)
+	if err != nil {
+		return nil, err
+	}
+	return xor(field, pan), nil
+}
+
+// DecodeISO0 mengambil PIN dari PIN block clear ISO 9564 format 0.
+func DecodeISO0(block []byte, account string) (string, error) {
+	if len(block) != 8 {
+		return "", fmt.Errorf("%w: length %d", ErrInvalidPinBlock, len(block))
+	}
+	pan, err := accountField(account)
+	if err != nil {
+		return "", err
+	}
+	return decodePinField('0', xor(block, pan))
+}
+
+// EncodePinBlock membentuk PIN block clear 8 byte format ISO 0, 1 atau 3.
+// Format 1 tidak memakai PAN.
+func EncodePinBlock(format, pin, pan string) ([]byte, error) {
+	switch format {
+	case PinFormatISO1:
+		return encodePinField('1', pin, 16)
+	case PinFormatISO0, PinFormatISO3:
+		nibble := byte('0')
+		if format == PinFormatISO3 {
+			nibble = '3'
+		}
+		field, err := encodePinField(nibble, pin, 16)
+		if err != nil {
+			return nil, err
+		}
+		panField, err := panAccountField(pan)
+		if err != nil {
+			return nil, err
+		}
+		return xor(field, panField), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPinBlockFormat, format)
+}
+
+// DecodePinBlock mengambil PIN dari PIN block clear format ISO 0, 1 atau 3.
+func DecodePinBlock(format string, block []byte, pan string) (string, error) {
+	if len(block) != 8 {
+		return "", fmt.Errorf("%w: length %d", ErrInvalidPinBlock, len(block))
+	}
+	switch format {
+	case PinFormatISO1:
+		return decodePinField('1', block)
+	case PinFormatISO0, PinFormatISO3:
+		nibble := byte('0')
+		if format == PinFormatISO3 {
+			nibble = '3'
+		}
+		panField, err := panAccountField(pan)
+		if err != nil {
+			return "", err
+		}
+		return decodePinField(nibble, xor(block, panField))
+	}
+	return "", fmt.Errorf("%w: %s", ErrPinBlockFormat, format)
+}
+
+// EncryptISO4 membentuk PIN block ISO 9564 format 4 terenkripsi dengan kunci
+// AES: E(K, E(K, PIN field) XOR PAN field).
+func EncryptISO4(key cipher.Block, pin, pan string) ([]byte, error) {
+	field, err := encodePinField('4', pin, 32)
+	if err != nil {
+		return nil, err
+	}
+	panField, err := iso4PanField(pan)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, aes.BlockSize)
+	key.Encrypt(out, field)
+	copy(out, xor(out, panField))
+	key.Encrypt(out, out)
+	return out, nil
+}
+
+// DecryptISO4 mengambil PIN dari PIN block ISO 9564 format 4.
+func DecryptISO4(key cipher.Block, block []byte, pan string) (string, error) {
+	if len(block) != aes.BlockSize {
+		return "", fmt.Errorf("%w: length %d", ErrInvalidPinBlock, len(block))
+	}
+	panField, err := iso4PanField(pan)
+	if err != nil {
+		return "", err
+	}
+
+	field := make([]byte, aes.BlockSize)
+	key.Decrypt(field, block)
+	copy(field, xor(field, panField))
+	key.Decrypt(field, field)
+	return decodePinField('4', field)
+}
+
+// encodePinField membentuk control field, panjang PIN, PIN lalu fill sesuai format:
+// F untuk format 0, acak untuk format 1, acak A-F untuk format 3, dan A diikuti
+// 8 byte acak untuk format 4.
+func encodePinField(format byte, pin string, size int) ([]byte, error) {
+	if len(pin) < 4 || len(pin) > 12 || !isDigits(pin) {
+		return nil, fmt.Errorf("%w: pin length %d", ErrInvalidPinBlock, len(pin))
+	}
+
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("hsm -> pin block fill: %w", err)
+	}
+
+	const hexDigits = "0123456789ABCDEF"
+	field := []byte(fmt.Sprintf("%c%X%s", format, len(pin), pin))
+	for i := len(field); i < size; i++ {
+		switch {
+		case format == '0':
+			field = append(field, 'F')
+		case format == '1':
+			field = append(field, hexDigits[random[i]%16])
+		case format == '3':
+			field = append(field, hexDigits[10+random[i]%6])
+		case i < 16:
+			field = append(field, 'A')
+		default:
+			field = append(field, hexDigits[random[i]%16])
+		}
+	}
+
+	out, _ := hex.DecodeString(string(field))
+	return out, nil
+}
+
+func decodePinField(format byte, block []byte) (string, error) {
+	field := strings.ToUpper(hex.EncodeToString(block))
+	if field[0] != format {
+		return "", fmt.Errorf("%w: format %c", ErrInvalidPinBlock, field[0])
+	}
+	length := int(field[1] - '0')
+	if field[1] > '9' {
+		length = int(field[1]-'A') + 10
+	}
+	if length < 4 || length > 12 {
+		return "", fmt.Errorf("%w: pin length %d", ErrInvalidPinBlock, length)
+	}
+
+	pin := field[2 : 2+length]
+	if !isDigits(pin) {
+		return "", ErrInvalidPinBlock
+	}
+
+	var fill string
+	switch format {
+	case '0':
+		fill = "F"
+	case '3':
+		fill = "ABCDEF"
+	case '4':
+		fill = "A"
+		field = field[:16]
+	}
+	if fill != "" && strings.Trim(field[2+length:], fill) != "" {
+		return "", ErrInvalidPinBlock
+	}
+	return pin, nil
+}
+
+// accountField membentuk PAN field format 0 dan 3 dari 12 digit account number.
+func accountField(account string) ([]byte, error) {
+	if len(account) != 12 || !isDigits(account) {
+		return nil, fmt.Errorf("%w: account number %q", ErrInvalidPinBlock, account)
+	}
+	out, _ := hex.DecodeString("0000" + account)
+	return out, nil
+}
+
+func panAccountField(pan string) ([]byte, error) {
+	account, err := AccountNumber(pan)
+	if err != nil {
+		return nil, err
+	}
+	return accountField(account)
+}
+
+// iso4PanField membentuk PAN field format 4: panjang PAN dikurangi 12, PAN
+// (minimal 12 digit, dipad 0 di kiri) lalu pad 0 sampai 16 byte.
+func iso4PanField(pan string) ([]byte, error) {
+	if len(pan) > 19 || !isDigits(pan) {
+		return nil, fmt.Errorf("hsm -> invalid pan for iso format 4: %q", pan)
+	}
+	padded := fmt.Sprintf("%012s", pan)
+	field := fmt.Sprintf("%d%s", len(padded)-12, padded)
+	field += strings.Repeat("0", 32-len(field))
+	out, _ := hex.DecodeString(field)
+	return out, nil
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
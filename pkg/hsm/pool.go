@@ -0,0 +1,218 @@
+package hsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	headerLength       = 4
+	defaultPoolSize    = 4
+	defaultHsmTimeout  = 5 * time.Second
+	defaultDialTimeout = 3 * time.Second
+	maxFrameLength     = 0xFFFF
+)
+
+var (
+	ErrNoHsmAvailable = errors.New("no hsm available")
+	errFrameTooLarge  = errors.New("frame too large")
+)
+
+type PoolOptions struct {
+	Addresses []string
+	Size      int           // jumlah koneksi terbuka maksimal
+	Timeout   time.Duration // batas waktu tiap command termasuk menunggu koneksi
+	Retries   int           // percobaan ulang jika koneksi gagal, default jumlah alamat
+}
+
+// Pool menyimpan koneksi persisten ke HSM. Pesan memakai prefix panjang 2 byte
+// dan header 4 karakter yang dikembalikan HSM, dipakai untuk mencocokkan respons.
+// Jika koneksi ke satu alamat gagal, koneksi baru dibuka ke alamat berikutnya.
+type Pool struct {
+	opts   PoolOptions
+	idle   chan net.Conn
+	slots  chan struct{}
+	active atomic.Uint32 // indeks alamat yang terakhir berhasil
+	seq    atomic.Uint32
+	dialer net.Dialer
+}
+
+func NewPool(opts PoolOptions) (*Pool, error) {
+	if len(opts.Addresses) == 0 {
+		return nil, errors.New("hsm -> no address")
+	}
+	if opts.Size <= 0 {
+		opts.Size = defaultPoolSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultHsmTimeout
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = len(opts.Addresses)
+	}
+
+	return &Pool{
+		opts:   opts,
+		idle:   make(chan net.Conn, opts.Size),
+		slots:  make(chan struct{}, opts.Size),
+		dialer: net.Dialer{Timeout: defaultDialTimeout},
+	}, nil
+}
+
+// ParseAddresses memecah HSM_ADDRESS yang berisi beberapa alamat dipisah koma.
+func ParseAddresses(addresses string) []string {
+	var out []string
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			out = append(out, address)
+		}
+	}
+	return out
+}
+
+// Exchange mengirim command (tanpa header) dan mengembalikan respons tanpa header.
+func (p *Pool) Exchange(ctx context.Context, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var lastErr error
+	for attempt := 0; attempt <= p.opts.Retries; attempt++ {
+		conn, err := p.get(ctx)
+		if err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w (last error: %v)", err, lastErr)
+			}
+			return nil, err
+		}
+
+		res, err := p.roundTrip(ctx, conn, command)
+		if err == nil {
+			p.put(conn)
+			return res, nil
+		}
+
+		// Koneksi tidak bisa dipakai lagi karena bisa saja masih ada respons tertinggal
+		p.discard(conn)
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+func (p *Pool) roundTrip(ctx context.Context, conn net.Conn, command string) ([]byte, error) {
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf("%0*X", headerLength, p.seq.Add(1)&0xFFFF)
+	if err := writeFrame(conn, []byte(header+command)); err != nil {
+		return nil, err
+	}
+
+	for {
+		res, err := readFrame(conn)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) < headerLength {
+			return nil, fmt.Errorf("response too short: %d", len(res))
+		}
+		// Respons dengan header lain adalah sisa command sebelumnya, dibuang
+		if string(res[:headerLength]) == header {
+			return res[headerLength:], nil
+		}
+	}
+}
+
+func (p *Pool) get(ctx context.Context) (net.Conn, error) {
+	select {
+	case conn := <-p.idle:
+		return conn, nil
+	default:
+	}
+
+	select {
+	case conn := <-p.idle:
+		return conn, nil
+	case p.slots <- struct{}{}:
+		conn, err := p.dial(ctx)
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		return conn, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %v", ErrNoHsmAvailable, ctx.Err())
+	}
+}
+
+// dial mencoba alamat mulai dari yang terakhir berhasil.
+func (p *Pool) dial(ctx context.Context) (net.Conn, error) {
+	start := int(p.active.Load())
+	var errs []error
+	for i := range p.opts.Addresses {
+		index := (start + i) % len(p.opts.Addresses)
+		conn, err := p.dialer.DialContext(ctx, "tcp", p.opts.Addresses[index])
+		if err == nil {
+			p.active.Store(uint32(index))
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("%w: %w", ErrNoHsmAvailable, errors.Join(errs...))
+}
+
+func (p *Pool) put(conn net.Conn) {
+	conn.SetDeadline(time.Time{})
+	select {
+	case p.idle <- conn:
+	default:
+		p.discard(conn)
+	}
+}
+
+func (p *Pool) discard(conn net.Conn) {
+	conn.Close()
+	<-p.slots
+}
+
+// Close menutup koneksi yang sedang idle.
+func (p *Pool) Close() {
+	for {
+		select {
+		case conn := <-p.idle:
+			p.discard(conn)
+		default:
+			return
+		}
+	}
+}
+
+func writeFrame(w io.Writer, body []byte) error {
+	if len(body) > maxFrameLength {
+		return errFrameTooLarge
+	}
+	_, err := w.Write(append([]byte{byte(len(body) >> 8), byte(len(body))}, body...))
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	body := make([]byte, int(length[0])<<8|int(length[1]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
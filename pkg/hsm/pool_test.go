@@ -0,0 +1,306 @@
+package hsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHSM menjawab setiap frame dengan fungsi reply, header dari request
+// dikembalikan seperti HSM Thales.
+type fakeHSM struct {
+	listener net.Listener
+	accepted atomic.Int32
+	reply    func(conn net.Conn, header, command string)
+	commands chan string
+}
+
+func newFakeHSM(t *testing.T, reply func(conn net.Conn, header, command string)) *fakeHSM {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeHSM{listener: listener, reply: reply, commands: make(chan string, 100)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.accepted.Add(1)
+			go func() {
+				defer conn.Close()
+				for {
+					body, err := readFrame(conn)
+					if err != nil {
+						return
+					}
+					f.commands <- string(body[headerLength:])
+					f.reply(conn, string(body[:headerLength]), string(body[headerLength:]))
+				}
+			}()
+		}
+	}()
+	return f
+}
+
+func (f *fakeHSM) address() string {
+	return f.listener.Addr().String()
+}
+
+func replyOK(payload string) func(conn net.Conn, header, command string) {
+	return func(conn net.Conn, header, command string) {
+		writeFrame(conn, []byte(header+command[:1]+string(command[1]+1)+"00"+payload))
+	}
+}
+
+func TestPoolReusesConnection(t *testing.T) {
+	hsm := newFakeHSM(t, replyOK("OK"))
+	pool, err := NewPool(PoolOptions{Addresses: []string{hsm.address()}, Size: 2})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	for i := 0; i < 5; i++ {
+		res, err := pool.Exchange(context.Background(), "NC")
+		require.NoError(t, err)
+		assert.Equal(t, "ND00OK", string(res))
+	}
+	assert.Equal(t, int32(1), hsm.accepted.Load())
+}
+
+func TestPoolLimitsConnections(t *testing.T) {
+	hsm := newFakeHSM(t, func(conn net.Conn, header, command string) {
+		time.Sleep(20 * time.Millisecond)
+		replyOK("")(conn, header, command)
+	})
+	pool, err := NewPool(PoolOptions{Addresses: []string{hsm.address()}, Size: 2})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pool.Exchange(context.Background(), "NC")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, hsm.accepted.Load(), int32(2))
+}
+
+func TestPoolPartialResponseAndStaleHeader(t *testing.T) {
+	hsm := newFakeHSM(t, func(conn net.Conn, header, command string) {
+		// Sisa respons command lain, lalu respons asli dikirim terpotong
+		writeFrame(conn, []byte("FFFFND00STALE"))
+		frame := []byte{0, byte(len(header) + 6), header[0], header[1]}
+		conn.Write(frame)
+		time.Sleep(10 * time.Millisecond)
+		conn.Write([]byte(header[2:] + "ND00OK"))
+	})
+	pool, err := NewPool(PoolOptions{Addresses: []string{hsm.address()}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	res, err := pool.Exchange(context.Background(), "NC")
+	require.NoError(t, err)
+	assert.Equal(t, "ND00OK", string(res))
+}
+
+func TestPoolTimeout(t *testing.T) {
+	hsm := newFakeHSM(t, func(conn net.Conn, header, command string) {})
+	pool, err := NewPool(PoolOptions{Addresses: []string{hsm.address()}, Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	start := time.Now()
+	_, err = pool.Exchange(context.Background(), "NC")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestPoolFailover(t *testing.T) {
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	downAddress := down.Addr().String()
+	down.Close()
+
+	hsm := newFakeHSM(t, replyOK("OK"))
+	pool, err := NewPool(PoolOptions{Addresses: []string{downAddress, hsm.address()}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	res, err := pool.Exchange(context.Background(), "NC")
+	require.NoError(t, err)
+	assert.Equal(t, "ND00OK", string(res))
+}
+
+func TestPoolRetriesBrokenConnection(t *testing.T) {
+	var calls atomic.Int32
+	hsm := newFakeHSM(t, func(conn net.Conn, header, command string) {
+		// Command pertama memutus koneksi, percobaan ulang harus di koneksi baru
+		if calls.Add(1) == 1 {
+			conn.Close()
+			return
+		}
+		replyOK("OK")(conn, header, command)
+	})
+	pool, err := NewPool(PoolOptions{Addresses: []string{hsm.address()}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	res, err := pool.Exchange(context.Background(), "NC")
+	require.NoError(t, err)
+	assert.Equal(t, "ND00OK", string(res))
+	assert.Equal(t, int32(2), hsm.accepted.Load())
+}
+
+func TestThalesTranslatePIN(t *testing.T) {
+	hsm := newFakeHSM(t, replyOK("04"+"0123456789ABCDEF"))
+	th, err := NewThales(PoolOptions{Addresses: []string{hsm.address()}})
+	require.NoError(t, err)
+	defer th.Close()
+
+	pinBlock, err := th.TranslatePIN(context.Background(), "UTPK", "UZPK", "1111222233334444", testPAN, PinFormats{})
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789ABCDEF", pinBlock)
+	assert.Equal(t, "CAUTPKUZPK1211112222333344440101111111111111", <-hsm.commands)
+}
+
+func TestThalesTranslatePINFormats(t *testing.T) {
+	hsm := newFakeHSM(t, replyOK("04"+strings.Repeat("AB", 16)+"48"))
+	th, err := NewThales(PoolOptions{Addresses: []string{hsm.address()}})
+	require.NoError(t, err)
+	defer th.Close()
+
+	pinBlock, err := th.TranslatePIN(context.Background(), "UTPK", "UZPK", "1111222233334444", testPAN, PinFormats{Source: PinFormatISO3, Destination: PinFormatISO4})
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("AB", 16), pinBlock)
+	assert.Equal(t, "CAUTPKUZPK12111122223333444447484111111111111111;", <-hsm.commands)
+
+	_, err = th.TranslatePINDukpt(context.Background(), "UBDK", "FFFF9876543210E00001", "UZPK", "1111222233334444", testPAN, PinFormats{Source: PinFormatISO4})
+	assert.ErrorIs(t, err, ErrPinBlockFormat)
+}
+
+func TestThalesErrorCode(t *testing.T) {
+	hsm := newFakeHSM(t, func(conn net.Conn, header, command string) {
+		writeFrame(conn, []byte(header+"CB24"))
+	})
+	th, err := NewThales(PoolOptions{Addresses: []string{hsm.address()}})
+	require.NoError(t, err)
+	defer th.Close()
+
+	_, err = th.TranslatePIN(context.Background(), "UTPK", "UZPK", "1111222233334444", testPAN, PinFormats{})
+	assert.ErrorContains(t, err, "error code 24")
+}
+
+func TestThalesErrorKinds(t *testing.T) {
+	err := thalesError("CA", "10")
+	assert.ErrorIs(t, err, ErrKeyParity)
+	assert.Equal(t, "10", ErrorCode(fmt.Errorf("wrapped: %w", err)))
+
+	assert.ErrorIs(t, thalesError("CA", "23"), ErrPinBlockFormat)
+	assert.ErrorIs(t, thalesError("CA", "20"), ErrInvalidPinBlock)
+	assert.ErrorIs(t, thalesError("FA", "28"), ErrInvalidKey)
+	assert.ErrorIs(t, thalesError("CA", "99"), ErrHsmFailure)
+	assert.Equal(t, "", ErrorCode(errors.New("other")))
+}
+
+func TestThalesUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	th, err := NewThales(PoolOptions{Addresses: []string{address}})
+	require.NoError(t, err)
+	_, _, err = th.ImportZPK(context.Background(), "UZMK", "ZPK")
+	assert.ErrorIs(t, err, ErrHsmUnavailable)
+	assert.Equal(t, "io", ErrorCode(err))
+}
+
+func TestThalesMAC(t *testing.T) {
+	hsm := newFakeHSM(t, func(conn net.Conn, header, command string) {
+		if strings.HasPrefix(command, "M8") {
+			writeFrame(conn, []byte(header+"M901"))
+			return
+		}
+		replyOK("0123456789ABCDEF")(conn, header, command)
+	})
+	th, err := NewThales(PoolOptions{Addresses: []string{hsm.address()}})
+	require.NoError(t, err)
+	defer th.Close()
+
+	mac, err := th.GenerateMAC(context.Background(), "UZAK", []byte{0x02, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789ABCDEF", mac)
+	assert.Equal(t, "M601131008UZAK00040200", <-hsm.commands)
+
+	err = th.VerifyMAC(context.Background(), "UTAK", []byte{0x02, 0x00}, "0123456789ABCDEF")
+	assert.ErrorIs(t, err, ErrMacVerification)
+	assert.Equal(t, "M801131003UTAK000402000123456789ABCDEF", <-hsm.commands)
+}
+
+func TestThalesKeyCheckValue(t *testing.T) {
+	hsm := newFakeHSM(t, replyOK("1A2B3C"))
+	th, err := NewThales(PoolOptions{Addresses: []string{hsm.address()}})
+	require.NoError(t, err)
+	defer th.Close()
+
+	kcv, err := th.KeyCheckValue(context.Background(), KeyTypeTPK, "UTPK")
+	require.NoError(t, err)
+	assert.Equal(t, "1A2B3C", kcv)
+	assert.Equal(t, "BU021UTPK", <-hsm.commands)
+
+	assert.Equal(t, "1A2B3C", responseKCV([]byte("UKEY1A2B3C"), 4))
+	assert.Equal(t, "", responseKCV([]byte("UKEY"), 4))
+}
+
+func TestThalesGeneratedKeysKCV(t *testing.T) {
+	twk := "U" + strings.Repeat("1", 32)
+	tpk := "U" + strings.Repeat("2", 32)
+	newThales := func(kcv string) (*Thales, *fakeHSM) {
+		hsm := newFakeHSM(t, func(conn net.Conn, header, command string) {
+			if strings.HasPrefix(command, "BU") {
+				replyOK(kcv)(conn, header, command)
+				return
+			}
+			replyOK(twk+tpk)(conn, header, command)
+		})
+		th, err := NewThales(PoolOptions{Addresses: []string{hsm.address()}})
+		require.NoError(t, err)
+		t.Cleanup(th.Close)
+		return th, hsm
+	}
+
+	th, hsm := newThales("1A2B3C")
+	_, key, kcv, err := th.GenerateTerminalKeys(context.Background(), "UTMK")
+	require.NoError(t, err)
+	assert.Equal(t, tpk, key)
+	assert.Equal(t, "1A2B3C", kcv)
+	assert.Equal(t, "HCUTMK;XU0", <-hsm.commands)
+	assert.Equal(t, "BU021"+tpk, <-hsm.commands)
+
+	_, _, kcv, err = th.GenerateTAK(context.Background(), "UTMK")
+	require.NoError(t, err)
+	assert.Equal(t, "1A2B3C", kcv)
+	assert.Equal(t, "HAUTMK", <-hsm.commands)
+	assert.Equal(t, "BU031"+tpk, <-hsm.commands)
+
+	// KCV kosong dari BU membuat pembuatan kunci gagal
+	th, _ = newThales("")
+	_, _, _, err = th.GenerateTerminalKeys(context.Background(), "UTMK")
+	assert.ErrorContains(t, err, "tpk kcv")
+	_, _, _, err = th.GenerateTAK(context.Background(), "UTMK")
+	assert.ErrorContains(t, err, "tak kcv")
+}
@@ -0,0 +1,318 @@
+package hsm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Software adalah HSM murni Go untuk pengujian dan development. Kunci dibungkus
+// 3DES-ECB dengan LMK sendiri, formatnya sama dengan skema "U" Thales sehingga
+// data di DB bisa dipakai bergantian dengan fungsi yang sama.
+type Software struct {
+	lmk cipher.Block
+}
+
+func NewSoftware(lmk string) (*Software, error) {
+	key, err := hex.DecodeString(lmk)
+	if err != nil || len(key) != 16 {
+		return nil, fmt.Errorf("hsm -> software lmk must be 32 hex characters")
+	}
+	block, err := tripleDES(key)
+	if err != nil {
+		return nil, fmt.Errorf("hsm -> software lmk: %w", err)
+	}
+	return &Software{lmk: block}, nil
+}
+
+// WrapKey membungkus kunci clear (hex) dengan LMK, dipakai untuk menyiapkan
+// TMK/ZMK di database saat pengujian.
+func (s *Software) WrapKey(clear string) (string, error) {
+	key, err := hex.DecodeString(clear)
+	if err != nil || len(key) != 16 {
+		return "", fmt.Errorf("hsm -> invalid clear key")
+	}
+	return "U" + encryptHex(s.lmk, key), nil
+}
+
+func (s *Software) ImportZPK(ctx context.Context, zmk, zpk string) (string, string, error) {
+	zmkBlock, err := s.unwrap(zmk)
+	if err != nil {
+		return "", "", fmt.Errorf("hsm -> zmk: %w", err)
+	}
+	clear, err := decryptKey(zmkBlock, zpk)
+	if err != nil {
+		return "", "", fmt.Errorf("hsm -> zpk: %w", err)
+	}
+	kcv, err := KeyCheckValue(clear)
+	if err != nil {
+		return "", "", fmt.Errorf("hsm -> zpk: %w", err)
+	}
+	return "U" + encryptHex(s.lmk, clear), kcv, nil
+}
+
+func (s *Software) GenerateTerminalKeys(ctx context.Context, tmk string) (string, string, string, error) {
+	tmkBlock, err := s.unwrap(tmk)
+	if err != nil {
+		return "", "", "", fmt.Errorf("hsm -> tmk: %w", err)
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", "", "", fmt.Errorf("hsm -> generate key: %w", err)
+	}
+	setOddParity(key)
+
+	kcv, err := KeyCheckValue(key)
+	if err != nil {
+		return "", "", "", fmt.Errorf("hsm -> generate key: %w", err)
+	}
+	return encryptHex(tmkBlock, key), "U" + encryptHex(s.lmk, key), kcv, nil
+}
+
+func (s *Software) TranslatePIN(ctx context.Context, tpk, zpk, pinBlock, pan string, formats PinFormats) (string, error) {
+	clearTPK, err := s.unwrapClear(tpk)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> tpk: %w", err)
+	}
+	return s.translate(clearTPK, zpk, pinBlock, pan, formats.withDefaults())
+}
+
+// translate membuka PIN block dengan kunci sumber (clear) lalu mengenkripsinya
+// ulang dengan ZPK dalam format tujuan. Untuk format 4 kunci 16 byte dipakai
+// sebagai kunci AES-128.
+func (s *Software) translate(source []byte, zpk, pinBlock, pan string, formats PinFormats) (string, error) {
+	clearZPK, err := s.unwrapClear(zpk)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> zpk: %w", err)
+	}
+
+	pin, err := decryptPinBlock(source, formats.Source, pinBlock, pan)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> %w", err)
+	}
+	translated, err := encryptPinBlock(clearZPK, formats.Destination, pin, pan)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> %w", err)
+	}
+	return translated, nil
+}
+
+func decryptPinBlock(key []byte, format, pinBlock, pan string) (string, error) {
+	encrypted, err := hex.DecodeString(pinBlock)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPinBlock, pinBlock)
+	}
+
+	if format == PinFormatISO4 {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return "", err
+		}
+		return DecryptISO4(block, encrypted, pan)
+	}
+
+	if len(encrypted) != des.BlockSize {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPinBlock, pinBlock)
+	}
+	block, err := tripleDES(key)
+	if err != nil {
+		return "", err
+	}
+	clear := make([]byte, des.BlockSize)
+	block.Decrypt(clear, encrypted)
+	return DecodePinBlock(format, clear, pan)
+}
+
+func encryptPinBlock(key []byte, format, pin, pan string) (string, error) {
+	if format == PinFormatISO4 {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return "", err
+		}
+		encrypted, err := EncryptISO4(block, pin, pan)
+		if err != nil {
+			return "", err
+		}
+		return strings.ToUpper(hex.EncodeToString(encrypted)), nil
+	}
+
+	clear, err := EncodePinBlock(format, pin, pan)
+	if err != nil {
+		return "", err
+	}
+	block, err := tripleDES(key)
+	if err != nil {
+		return "", err
+	}
+	return encryptHex(block, clear), nil
+}
+
+// unwrap membuka kunci di bawah LMK menjadi cipher siap pakai.
+func (s *Software) unwrap(wrapped string) (cipher.Block, error) {
+	clear, err := s.unwrapClear(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return tripleDES(clear)
+}
+
+// unwrapClear membuka kunci di bawah LMK dan memeriksa parity-nya.
+func (s *Software) unwrapClear(wrapped string) ([]byte, error) {
+	clear, err := decryptKey(s.lmk, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if !oddParity(clear) {
+		return nil, ErrKeyParity
+	}
+	return clear, nil
+}
+
+func decryptKey(kek cipher.Block, wrapped string) ([]byte, error) {
+	encrypted, err := hex.DecodeString(strings.TrimPrefix(strings.ToUpper(wrapped), "U"))
+	if err != nil || len(encrypted) != 16 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKey, wrapped)
+	}
+	clear := make([]byte, 16)
+	kek.Decrypt(clear[:8], encrypted[:8])
+	kek.Decrypt(clear[8:], encrypted[8:])
+	return clear, nil
+}
+
+func encryptHex(kek cipher.Block, data []byte) string {
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += des.BlockSize {
+		kek.Encrypt(out[i:i+des.BlockSize], data[i:i+des.BlockSize])
+	}
+	return strings.ToUpper(hex.EncodeToString(out))
+}
+
+// tripleDES membuat cipher 3DES dari kunci double length (K1 K2 K1).
+func tripleDES(key []byte) (cipher.Block, error) {
+	full := append(append([]byte{}, key...), key[:8]...)
+	return des.NewTripleDESCipher(full)
+}
+
+func setOddParity(key []byte) {
+	for i, b := range key {
+		if bits.OnesCount8(b)%2 == 0 {
+			key[i] ^= 1
+		}
+	}
+}
+
+func oddParity(key []byte) bool {
+	for _, b := range key {
+		if bits.OnesCount8(b)%2 == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Software) GenerateTAK(ctx context.Context, tmk string) (string, string, string, error) {
+	return s.GenerateTerminalKeys(ctx, tmk)
+}
+
+func (s *Software) ImportZAK(ctx context.Context, zmk, zak string) (string, string, error) {
+	return s.ImportZPK(ctx, zmk, zak)
+}
+
+func (s *Software) KeyCheckValue(ctx context.Context, keyType, key string) (string, error) {
+	clear, err := decryptKey(s.lmk, key)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> %w", err)
+	}
+	return KeyCheckValue(clear)
+}
+
+// KeyCheckValue menghitung KCV 6 hex: 3 byte pertama enkripsi blok nol dengan kunci clear.
+func KeyCheckValue(clear []byte) (string, error) {
+	block, err := tripleDES(clear)
+	if err != nil {
+		return "", err
+	}
+	return encryptHex(block, make([]byte, des.BlockSize))[:6], nil
+}
+
+func (s *Software) VerifyMAC(ctx context.Context, tak string, data []byte, mac string) error {
+	expected, err := s.GenerateMAC(ctx, tak, data)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToUpper(mac))) != 1 {
+		return ErrMacVerification
+	}
+	return nil
+}
+
+func (s *Software) GenerateMAC(ctx context.Context, key string, data []byte) (string, error) {
+	clear, err := decryptKey(s.lmk, key)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> mac key: %w", err)
+	}
+	if !oddParity(clear) {
+		return "", fmt.Errorf("hsm -> mac key: %w", ErrKeyParity)
+	}
+	mac, err := RetailMAC(clear, data)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> %w", err)
+	}
+	return strings.ToUpper(hex.EncodeToString(mac)), nil
+}
+
+// RetailMAC menghitung MAC ANSI X9.19 (ISO 9797-1 algoritma 3, padding method 1)
+// dengan kunci double length clear.
+func RetailMAC(key, data []byte) ([]byte, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("%w: mac key length %d", ErrInvalidKey, len(key))
+	}
+	k1, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	k2, err := des.NewCipher(key[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	padded := append([]byte{}, data...)
+	if len(padded) == 0 || len(padded)%des.BlockSize != 0 {
+		padded = append(padded, make([]byte, des.BlockSize-len(padded)%des.BlockSize)...)
+	}
+
+	mac := make([]byte, des.BlockSize)
+	for i := 0; i < len(padded); i += des.BlockSize {
+		for j := range mac {
+			mac[j] ^= padded[i+j]
+		}
+		k1.Encrypt(mac, mac)
+	}
+	k2.Decrypt(mac, mac)
+	k1.Encrypt(mac, mac)
+	return mac, nil
+}
+
+func (s *Software) TranslatePINDukpt(ctx context.Context, bdk, ksn, zpk, pinBlock, pan string, formats PinFormats) (string, error) {
+	formats = formats.withDefaults()
+	if formats.Source != PinFormatISO0 {
+		return "", fmt.Errorf("hsm -> dukpt %w: %s", ErrPinBlockFormat, formats.Source)
+	}
+	clearBDK, err := decryptKey(s.lmk, bdk)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> bdk: %w", err)
+	}
+	pek, err := DukptPinKey(clearBDK, ksn)
+	if err != nil {
+		return "", fmt.Errorf("hsm -> %w", err)
+	}
+	return s.translate(pek, zpk, pinBlock, pan, formats)
+}
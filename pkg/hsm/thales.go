@@ -0,0 +1,219 @@
+package hsm
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alfianX/danus-h2h/pkg/metrics"
+)
+
+// Thales mengirim command host Thales payShield lewat pool koneksi.
+type Thales struct {
+	pool *Pool
+}
+
+func NewThales(opts PoolOptions) (*Thales, error) {
+	pool, err := NewPool(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Thales{pool: pool}, nil
+}
+
+// ImportZPK memakai command FA (translate ZPK dari ZMK ke LMK), respons berisi
+// ZPK di bawah LMK diikuti KCV.
+func (t *Thales) ImportZPK(ctx context.Context, zmk, zpk string) (string, string, error) {
+	res, err := t.command(ctx, "FA"+zmk+"U"+zpk)
+	if err != nil {
+		return "", "", err
+	}
+	if len(res) < 39 {
+		return "", "", fmt.Errorf("hsm -> FA response too short: %d", len(res))
+	}
+	return string(res[:33]), string(res[33:39]), nil
+}
+
+// GenerateTerminalKeys memakai command HC (generate TMK/TPK/PVK). Respons HC
+// tidak berisi KCV, KCV TPK dihitung dengan command BU.
+func (t *Thales) GenerateTerminalKeys(ctx context.Context, tmk string) (string, string, string, error) {
+	res, err := t.command(ctx, "HC"+tmk+";XU0")
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(res) < 66 {
+		return "", "", "", fmt.Errorf("hsm -> HC response too short: %d", len(res))
+	}
+	tpk := string(res[33:66])
+	kcv, err := t.KeyCheckValue(ctx, KeyTypeTPK, tpk)
+	if err != nil {
+		return "", "", "", fmt.Errorf("hsm -> tpk kcv: %w", err)
+	}
+	return string(res[1:33]), tpk, kcv, nil
+}
+
+// TranslatePIN memakai command CA (translate PIN dari TPK ke ZPK) dengan format
+// sumber dan tujuan dari formats.
+func (t *Thales) TranslatePIN(ctx context.Context, tpk, zpk, pinBlock, pan string, formats PinFormats) (string, error) {
+	formats = formats.withDefaults()
+	account, err := thalesAccount(formats, pan)
+	if err != nil {
+		return "", err
+	}
+	res, err := t.command(ctx, "CA"+tpk+zpk+"12"+pinBlock+formats.Source+formats.Destination+account)
+	if err != nil {
+		return "", err
+	}
+	return responsePinBlock("CA", res, formats.Destination)
+}
+
+// TranslatePINDukpt memakai command CI (translate PIN dari BDK ke ZPK) dengan
+// KSN descriptor 605. Format sumber CI selalu ISO 0.
+func (t *Thales) TranslatePINDukpt(ctx context.Context, bdk, ksn, zpk, pinBlock, pan string, formats PinFormats) (string, error) {
+	formats = formats.withDefaults()
+	if formats.Source != PinFormatISO0 {
+		return "", fmt.Errorf("hsm -> dukpt %w: %s", ErrPinBlockFormat, formats.Source)
+	}
+	account, err := thalesAccount(formats, pan)
+	if err != nil {
+		return "", err
+	}
+	res, err := t.command(ctx, "CI"+bdk+zpk+"605"+ksn+pinBlock+formats.Destination+account)
+	if err != nil {
+		return "", err
+	}
+	return responsePinBlock("CI", res, formats.Destination)
+}
+
+// thalesAccount membentuk field account number: PAN lengkap diakhiri ';' jika
+// salah satu format ISO 4, selain itu 12 digit account number. Format 1 tidak
+// memakai PAN sehingga diisi nol jika PAN tidak tersedia.
+func thalesAccount(formats PinFormats, pan string) (string, error) {
+	if formats.Source == PinFormatISO4 || formats.Destination == PinFormatISO4 {
+		if len(pan) < 12 || len(pan) > 19 || !isDigits(pan) {
+			return "", fmt.Errorf("hsm -> invalid pan for iso format 4: %q", pan)
+		}
+		return pan + ";", nil
+	}
+	account, err := AccountNumber(pan)
+	if err != nil && formats.Source == PinFormatISO1 && formats.Destination == PinFormatISO1 {
+		return "000000000000", nil
+	}
+	return account, err
+}
+
+// responsePinBlock mengambil PIN block tujuan setelah 2 digit panjang PIN,
+// 32 hex untuk ISO 4 dan 16 hex untuk format lain.
+func responsePinBlock(command string, res []byte, format string) (string, error) {
+	size := 16
+	if format == PinFormatISO4 {
+		size = 32
+	}
+	if len(res) < 2+size {
+		return "", fmt.Errorf("hsm -> %s response too short: %d", command, len(res))
+	}
+	return string(res[2 : 2+size]), nil
+}
+
+// GenerateTAK memakai command HA (generate TAK), KCV TAK dihitung dengan
+// command BU.
+func (t *Thales) GenerateTAK(ctx context.Context, tmk string) (string, string, string, error) {
+	res, err := t.command(ctx, "HA"+tmk)
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(res) < 66 {
+		return "", "", "", fmt.Errorf("hsm -> HA response too short: %d", len(res))
+	}
+	tak := string(res[33:66])
+	kcv, err := t.KeyCheckValue(ctx, KeyTypeTAK, tak)
+	if err != nil {
+		return "", "", "", fmt.Errorf("hsm -> tak kcv: %w", err)
+	}
+	return string(res[1:33]), tak, kcv, nil
+}
+
+// ImportZAK memakai command MI (translate ZAK dari ZMK ke LMK).
+func (t *Thales) ImportZAK(ctx context.Context, zmk, zak string) (string, string, error) {
+	res, err := t.command(ctx, "MI"+zmk+"U"+zak)
+	if err != nil {
+		return "", "", err
+	}
+	if len(res) < 33 {
+		return "", "", fmt.Errorf("hsm -> MI response too short: %d", len(res))
+	}
+	return string(res[:33]), responseKCV(res, 33), nil
+}
+
+// KeyCheckValue memakai command BU (generate key check value) untuk kunci double length.
+func (t *Thales) KeyCheckValue(ctx context.Context, keyType, key string) (string, error) {
+	res, err := t.command(ctx, "BU"+keyType+"1"+key)
+	if err != nil {
+		return "", err
+	}
+	if len(res) < 6 {
+		return "", fmt.Errorf("hsm -> BU response too short: %d", len(res))
+	}
+	return string(res[:6]), nil
+}
+
+// responseKCV mengambil 6 hex KCV setelah kunci jika ada di respons.
+func responseKCV(res []byte, offset int) string {
+	if len(res) < offset+6 {
+		return ""
+	}
+	return string(res[offset : offset+6])
+}
+
+// VerifyMAC memakai command M8 dengan key type 003 (TAK).
+func (t *Thales) VerifyMAC(ctx context.Context, tak string, data []byte, mac string) error {
+	_, err := t.command(ctx, "M8"+macParams("003", tak, data)+mac)
+	return err
+}
+
+// GenerateMAC memakai command M6 dengan key type 008 (ZAK).
+func (t *Thales) GenerateMAC(ctx context.Context, zak string, data []byte) (string, error) {
+	res, err := t.command(ctx, "M6"+macParams("008", zak, data))
+	if err != nil {
+		return "", err
+	}
+	if len(res) < 16 {
+		return "", fmt.Errorf("hsm -> M6 response too short: %d", len(res))
+	}
+	return string(res[:16]), nil
+}
+
+// macParams menyusun parameter M6/M8: satu blok, input hex, MAC 16 hex,
+// ISO 9797-1 algoritma 3 dengan padding method 1.
+func macParams(keyType, key string, data []byte) string {
+	message := strings.ToUpper(hex.EncodeToString(data))
+	return "0" + "1" + "1" + "3" + "1" + keyType + key + fmt.Sprintf("%04X", len(message)) + message
+}
+
+func (t *Thales) Close() {
+	t.pool.Close()
+}
+
+// command mengirim command dan mengembalikan data respons setelah response code
+// dan error code jika error code "00".
+func (t *Thales) command(ctx context.Context, command string) ([]byte, error) {
+	code := command[:2]
+	defer metrics.ObserveHSM(code, time.Now())
+
+	res, err := t.pool.Exchange(ctx, command)
+	if err != nil {
+		metrics.HSMErrors.WithLabelValues(code, "io").Inc()
+		return nil, &Error{Command: code, Code: "io", Kind: ErrHsmUnavailable, Err: err}
+	}
+	if len(res) < 4 {
+		metrics.HSMErrors.WithLabelValues(code, "io").Inc()
+		return nil, &Error{Command: code, Code: "io", Kind: ErrHsmFailure, Err: fmt.Errorf("response too short: %d", len(res))}
+	}
+	if errorCode := string(res[2:4]); errorCode != "00" {
+		metrics.HSMErrors.WithLabelValues(code, errorCode).Inc()
+		return nil, thalesError(code, errorCode)
+	}
+	return res[4:], nil
+}
@@ -0,0 +1,291 @@
+package iso
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/moov-io/iso8583/field"
+)
+
+// Codec adalah pasangan packer/unpacker custom bernama untuk field Spec87Hex.
+// Pesan terminal berupa hex string dari pesan binary, sehingga panjang field
+// dan nibble padding tidak bisa diwakili prefix bawaan moov-io. Nama codec
+// dipakai di file spec JSON (key "codec"). Codec tanpa pack memakai packing
+// default moov-io, begitu juga unpack.
+type Codec struct {
+	Name   string
+	pack   func(value []byte, spec *field.Spec) ([]byte, error)
+	unpack func(packed []byte, spec *field.Spec) ([]byte, int, error)
+}
+
+func (c *Codec) Pack(value []byte, spec *field.Spec) ([]byte, error) {
+	if c.pack == nil {
+		return packDefault(value, spec)
+	}
+	return c.pack(value, spec)
+}
+
+func (c *Codec) Unpack(packed []byte, spec *field.Spec) ([]byte, int, error) {
+	if c.unpack == nil {
+		return unpackDefault(packed, spec)
+	}
+	return c.unpack(packed, spec)
+}
+
+var (
+	// CodecOddLL: LL dalam digit, nilai ganjil dipad satu nibble (PAN, bit 32).
+	CodecOddLL = &Codec{Name: "odd-ll", pack: packOddLL, unpack: unpackOddLL}
+	// CodecTrack2: seperti odd-ll, separator '=' dikirim sebagai nibble D.
+	CodecTrack2 = &Codec{Name: "track2", pack: packTrack2, unpack: unpackOddLL}
+	// CodecOddFixed: field fixed dengan panjang ganjil, diawali satu nibble pad.
+	CodecOddFixed = &Codec{Name: "odd-fixed", pack: packOddFixed, unpack: unpackOddFixed}
+	// CodecLLLBytes: LLL dalam byte diawali "0", isi dibaca sepanjang LLL digit.
+	CodecLLLBytes = &Codec{Name: "lll-bytes", pack: packLLLBytes, unpack: unpackLLLBytes(1)}
+	// CodecLLLHex: LLL dalam byte diawali "0", isi hex dua digit per byte (bit 54, 55).
+	CodecLLLHex = &Codec{Name: "lll-hex", pack: packLLLBytes, unpack: unpackLLLBytes(2)}
+	// CodecLLLBinary: LLL diawali byte nol, dipakai field dengan encoding hex.
+	CodecLLLBinary = &Codec{Name: "lll-binary", pack: packLLLBinary, unpack: unpackLLLBytes(1)}
+	// CodecNoUnpad: pack default, unpack tanpa membuang padding (STAN).
+	CodecNoUnpad = &Codec{Name: "no-unpad", unpack: unpackNoUnpad}
+	// CodecMacHex: MAC 8 byte dikirim sebagai 16 digit hex.
+	CodecMacHex = &Codec{Name: "mac-hex", unpack: unpackMacHex}
+)
+
+var codecs = map[string]*Codec{}
+
+func init() {
+	for _, c := range []*Codec{
+		CodecOddLL, CodecTrack2, CodecOddFixed, CodecLLLBytes,
+		CodecLLLHex, CodecLLLBinary, CodecNoUnpad, CodecMacHex,
+	} {
+		codecs[c.Name] = c
+	}
+}
+
+// LookupCodec mencari codec berdasarkan nama di file spec.
+func LookupCodec(name string) (*Codec, error) {
+	if c, ok := codecs[name]; ok {
+		return c, nil
+	}
+	names := make([]string, 0, len(codecs))
+	for n := range codecs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown codec %q (known: %s)", name, strings.Join(names, ", "))
+}
+
+// codecOf mencari codec yang terpasang di spec field. Field tanpa codec
+// memakai packer default moov-io.
+func codecOf(spec *field.Spec) (*Codec, bool) {
+	c, ok := spec.Unpacker.(*Codec)
+	if !ok {
+		c, ok = spec.Packer.(*Codec)
+	}
+	return c, ok
+}
+
+// packDefault sama dengan packer default moov-io.
+func packDefault(value []byte, spec *field.Spec) ([]byte, error) {
+	if spec.Pad != nil {
+		value = spec.Pad.Pad(value, spec.Length)
+	}
+
+	encodedValue, err := spec.Enc.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode content: %w", err)
+	}
+
+	lengthPrefix, err := spec.Pref.EncodeLength(spec.Length, len(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode length: %w", err)
+	}
+
+	return append(lengthPrefix, encodedValue...), nil
+}
+
+// unpackDefault sama dengan unpacker default moov-io.
+func unpackDefault(packed []byte, spec *field.Spec) ([]byte, int, error) {
+	value, read, err := unpackNoUnpad(packed, spec)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if spec.Pad != nil {
+		value = spec.Pad.Unpad(value)
+	}
+
+	return value, read, nil
+}
+
+func packOddLL(value []byte, spec *field.Spec) ([]byte, error) {
+	encodedValue, err := spec.Enc.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode content: %w", err)
+	}
+
+	// Encode the length of the encoded value
+	lengthPrefix, err := spec.Pref.EncodeLength(spec.Length, len(encodedValue))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode length: %w", err)
+	}
+
+	if len(encodedValue)%2 != 0 {
+		encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
+	}
+
+	return append(lengthPrefix, encodedValue...), nil
+}
+
+func packTrack2(value []byte, spec *field.Spec) ([]byte, error) {
+	return packOddLL([]byte(strings.ReplaceAll(string(value), "=", "D")), spec)
+}
+
+func unpackOddLL(packed []byte, spec *field.Spec) ([]byte, int, error) {
+	valueLength, prefBytes, err := spec.Pref.DecodeLength(spec.Length, packed)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode length: %w", err)
+	}
+
+	value, read, err := spec.Enc.Decode(packed[prefBytes:], valueLength)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode content: %w", err)
+	}
+
+	if valueLength%2 != 0 {
+		prefBytes = prefBytes + 1
+	}
+
+	return value, read + prefBytes, nil
+}
+
+func packOddFixed(value []byte, spec *field.Spec) ([]byte, error) {
+	if spec.Pad != nil {
+		value = spec.Pad.Pad(value, spec.Length+1)
+	}
+
+	encodedValue, err := spec.Enc.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode content: %w", err)
+	}
+
+	lengthPrefix, err := spec.Pref.EncodeLength(spec.Length+1, len(encodedValue))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode length: %w", err)
+	}
+
+	return append(lengthPrefix, encodedValue...), nil
+}
+
+func unpackOddFixed(packed []byte, spec *field.Spec) ([]byte, int, error) {
+	valueLength, prefBytes, err := spec.Pref.DecodeLength(spec.Length, packed)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode length: %w", err)
+	}
+
+	value, read, err := spec.Enc.Decode(packed[prefBytes+1:], valueLength)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode content: %w", err)
+	}
+
+	return value, read + prefBytes + 1, nil
+}
+
+func packLLLBytes(value []byte, spec *field.Spec) ([]byte, error) {
+	if spec.Pad != nil {
+		value = spec.Pad.Pad(value, spec.Length)
+	}
+
+	encodedValue, err := spec.Enc.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode content: %w", err)
+	}
+
+	// Panjang dalam byte binary, bukan jumlah digit hex
+	lengthPrefix, err := spec.Pref.EncodeLength(spec.Length, len(encodedValue)/2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode length: %w", err)
+	}
+
+	lengthPrefix = append([]byte("0"), lengthPrefix...)
+
+	return append(lengthPrefix, encodedValue...), nil
+}
+
+func packLLLBinary(value []byte, spec *field.Spec) ([]byte, error) {
+	if spec.Pad != nil {
+		value = spec.Pad.Pad(value, spec.Length)
+	}
+
+	encodedValue, err := spec.Enc.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode content: %w", err)
+	}
+
+	lengthPrefix, err := spec.Pref.EncodeLength(spec.Length, len(encodedValue)-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode length: %w", err)
+	}
+	lengthPrefix = append([]byte{0}, lengthPrefix...)
+
+	return append(lengthPrefix, encodedValue...), nil
+}
+
+// unpackLLLBytes membaca field dengan satu karakter sebelum prefix LLL. Isi
+// dibaca sebanyak LLL dikali digitsPerByte.
+func unpackLLLBytes(digitsPerByte int) func(packed []byte, spec *field.Spec) ([]byte, int, error) {
+	return func(packed []byte, spec *field.Spec) ([]byte, int, error) {
+		encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(spec.Length, packed[1:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode length: %w", err)
+		}
+
+		value, read, err := spec.Enc.Decode(packed[prefBytes+1:], encodedValueLength*digitsPerByte)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode content: %w", err)
+		}
+
+		if spec.Pad != nil {
+			value = spec.Pad.Unpad(value)
+		}
+
+		return value, read + prefBytes + 1, nil
+	}
+}
+
+func unpackNoUnpad(packed []byte, spec *field.Spec) ([]byte, int, error) {
+	valueLength, prefBytes, err := spec.Pref.DecodeLength(spec.Length, packed)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode length: %w", err)
+	}
+
+	value, read, err := spec.Enc.Decode(packed[prefBytes:], valueLength)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode content: %w", err)
+	}
+
+	return value, read + prefBytes, nil
+}
+
+func unpackMacHex(packed []byte, spec *field.Spec) ([]byte, int, error) {
+	if len(packed) < 1 {
+		return nil, 0, fmt.Errorf("failed to decode length: empty data")
+	}
+
+	valueLength, prefBytes, err := spec.Pref.DecodeLength(spec.Length, packed[1:])
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode length: %w", err)
+	}
+
+	value, read, err := spec.Enc.Decode(packed[prefBytes:], valueLength*2)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode content: %w", err)
+	}
+
+	if spec.Pad != nil {
+		value = spec.Pad.Unpad(value)
+	}
+
+	return value, read + prefBytes, nil
+}
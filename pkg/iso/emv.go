@@ -0,0 +1,189 @@
+package iso
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidTLV = errors.New("invalid tlv")
+
+// Tag EMV yang dipakai gateway dari ICC data (bit 55).
+const (
+	TagApplicationCryptogram = "9F26"
+	TagCryptogramInfo        = "9F27"
+	TagTVR                   = "95"
+	TagTransactionDate       = "9A"
+	TagCurrencyCode          = "5F2A"
+	TagPAN                   = "5A"
+	TagTrack2Equivalent      = "57"
+)
+
+// TLV adalah satu data object EMV (BER-TLV). Tag ditulis dalam hex huruf besar.
+type TLV struct {
+	Tag   string
+	Value []byte
+}
+
+// TLVList menyimpan data object sesuai urutan di pesan, karena sebagian host
+// memeriksa urutan tag.
+type TLVList []TLV
+
+// DecodeTLV membaca ICC data. Tag constructed tidak diurai, isinya disimpan
+// sebagai value.
+func DecodeTLV(data []byte) (TLVList, error) {
+	var list TLVList
+	for i := 0; i < len(data); {
+		// Padding 00/FF di antara data object diabaikan
+		if data[i] == 0x00 || data[i] == 0xFF {
+			i++
+			continue
+		}
+
+		start := i
+		if data[i]&0x1F == 0x1F {
+			for i++; i < len(data) && data[i]&0x80 != 0; i++ {
+			}
+		}
+		i++
+		if i > len(data) {
+			return nil, fmt.Errorf("%w: truncated tag at %d", ErrInvalidTLV, start)
+		}
+		tag := strings.ToUpper(hex.EncodeToString(data[start:i]))
+
+		if i >= len(data) {
+			return nil, fmt.Errorf("%w: tag %s has no length", ErrInvalidTLV, tag)
+		}
+		length := int(data[i])
+		i++
+		if length&0x80 != 0 {
+			size := length & 0x7F
+			if size == 0 || size > 2 || i+size > len(data) {
+				return nil, fmt.Errorf("%w: tag %s length", ErrInvalidTLV, tag)
+			}
+			length = 0
+			for _, b := range data[i : i+size] {
+				length = length<<8 | int(b)
+			}
+			i += size
+		}
+		if i+length > len(data) {
+			return nil, fmt.Errorf("%w: tag %s value truncated", ErrInvalidTLV, tag)
+		}
+
+		list = append(list, TLV{Tag: tag, Value: append([]byte{}, data[i:i+length]...)})
+		i += length
+	}
+	return list, nil
+}
+
+// DecodeTLVHex membaca ICC data dalam bentuk hex seperti isi bit 55 Spec87.
+func DecodeTLVHex(data string) (TLVList, error) {
+	raw, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTLV, err)
+	}
+	return DecodeTLV(raw)
+}
+
+// Encode menyusun kembali ICC data dengan panjang BER (1 sampai 3 byte).
+func (l TLVList) Encode() ([]byte, error) {
+	var out []byte
+	for _, tlv := range l {
+		tag, err := hex.DecodeString(tlv.Tag)
+		if err != nil || len(tag) == 0 {
+			return nil, fmt.Errorf("%w: tag %q", ErrInvalidTLV, tlv.Tag)
+		}
+		out = append(out, tag...)
+
+		switch length := len(tlv.Value); {
+		case length < 0x80:
+			out = append(out, byte(length))
+		case length <= 0xFF:
+			out = append(out, 0x81, byte(length))
+		case length <= 0xFFFF:
+			out = append(out, 0x82, byte(length>>8), byte(length))
+		default:
+			return nil, fmt.Errorf("%w: tag %s too long", ErrInvalidTLV, tlv.Tag)
+		}
+		out = append(out, tlv.Value...)
+	}
+	return out, nil
+}
+
+// EncodeHex menyusun ICC data dalam bentuk hex untuk bit 55.
+func (l TLVList) EncodeHex() (string, error) {
+	raw, err := l.Encode()
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(raw)), nil
+}
+
+// Get mengembalikan value tag pertama yang cocok.
+func (l TLVList) Get(tag string) ([]byte, bool) {
+	tag = strings.ToUpper(tag)
+	for _, tlv := range l {
+		if tlv.Tag == tag {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetHex mengembalikan value tag dalam hex, kosong jika tag tidak ada.
+func (l TLVList) GetHex(tag string) string {
+	value, _ := l.Get(tag)
+	return strings.ToUpper(hex.EncodeToString(value))
+}
+
+// Set mengganti value tag yang sudah ada atau menambahkannya di akhir.
+func (l TLVList) Set(tag string, value []byte) TLVList {
+	tag = strings.ToUpper(tag)
+	for i := range l {
+		if l[i].Tag == tag {
+			l[i].Value = value
+			return l
+		}
+	}
+	return append(l, TLV{Tag: tag, Value: value})
+}
+
+// Remove membuang semua data object dengan tag tersebut.
+func (l TLVList) Remove(tags ...string) TLVList {
+	out := l[:0]
+	for _, tlv := range l {
+		remove := false
+		for _, tag := range tags {
+			if strings.EqualFold(tlv.Tag, tag) {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			out = append(out, tlv)
+		}
+	}
+	return out
+}
+
+// Tags mengembalikan daftar tag sesuai urutan, dipakai untuk log tanpa membuka
+// value yang sensitif seperti 57 dan 5A.
+func (l TLVList) Tags() []string {
+	tags := make([]string, len(l))
+	for i, tlv := range l {
+		tags[i] = tlv.Tag
+	}
+	return tags
+}
+
+// Require memeriksa tag wajib ada di ICC data.
+func (l TLVList) Require(tags ...string) error {
+	for _, tag := range tags {
+		if _, ok := l.Get(tag); !ok {
+			return fmt.Errorf("%w: missing tag %s", ErrInvalidTLV, tag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package iso
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testICC = "9F2608A1B2C3D4E5F60718" + "9F270180" + "95050000000080" + "9A03250601" + "5F2A020360" + "9F7C0401020304"
+
+func TestDecodeTLV(t *testing.T) {
+	icc, err := DecodeTLVHex(testICC)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9F26", "9F27", "95", "9A", "5F2A", "9F7C"}, icc.Tags())
+	assert.Equal(t, "A1B2C3D4E5F60718", icc.GetHex(TagApplicationCryptogram))
+	assert.Equal(t, "80", icc.GetHex(TagCryptogramInfo))
+	assert.Equal(t, "0000000080", icc.GetHex(TagTVR))
+	assert.Equal(t, "250601", icc.GetHex(TagTransactionDate))
+	assert.Equal(t, "0360", icc.GetHex(TagCurrencyCode))
+	assert.NoError(t, icc.Require(TagApplicationCryptogram, TagCryptogramInfo))
+	assert.ErrorIs(t, icc.Require("9F36"), ErrInvalidTLV)
+
+	encoded, err := icc.EncodeHex()
+	require.NoError(t, err)
+	assert.Equal(t, testICC, encoded)
+}
+
+func TestDecodeTLVLongLength(t *testing.T) {
+	value := strings.Repeat("AB", 200)
+	icc, err := DecodeTLVHex("9F1081C8" + value)
+	require.NoError(t, err)
+	assert.Equal(t, value, icc.GetHex("9F10"))
+
+	encoded, err := icc.EncodeHex()
+	require.NoError(t, err)
+	assert.Equal(t, "9F1081C8"+value, encoded)
+}
+
+func TestDecodeTLVInvalid(t *testing.T) {
+	for _, data := range []string{"9F", "9F26", "9F2608A1B2", "9F2683000001", "ZZ"} {
+		_, err := DecodeTLVHex(data)
+		assert.ErrorIs(t, err, ErrInvalidTLV, data)
+	}
+}
+
+func TestTLVListEdit(t *testing.T) {
+	icc, err := DecodeTLVHex(testICC)
+	require.NoError(t, err)
+
+	icc = icc.Remove("9f7c", TagTVR)
+	icc = icc.Set(TagCryptogramInfo, []byte{0x40})
+	icc = icc.Set("9F33", []byte{0xE0, 0xF0, 0xC8})
+	assert.Equal(t, []string{"9F26", "9F27", "9A", "5F2A", "9F33"}, icc.Tags())
+	assert.Equal(t, "40", icc.GetHex(TagCryptogramInfo))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/moov-io/iso8583"
@@ -11,7 +12,7 @@ import (
 
 func IsoConvertToAscii(msg []byte) ([]byte, error) {
 	isoStr := string(msg)
-	isomessageHex := iso8583.NewMessage(Spec87Hex)
+	isomessageHex := iso8583.NewMessage(TerminalSpec())
 	err := isomessageHex.Unpack([]byte(isoStr))
 	if err != nil {
 		return nil, fmt.Errorf("ISO convert to ASCII -> fail unpack ISO!, err: %v", err)
@@ -62,7 +63,7 @@ func IsoConvertToHex(msg []byte) ([]byte, error) {
 		mti = "0410"
 	}
 
-	isomessageHex := iso8583.NewMessage(Spec87Hex)
+	isomessageHex := iso8583.NewMessage(TerminalSpec())
 	isomessage.MTI(mti)
 
 	fields := isomessage.GetFields()
@@ -332,7 +333,7 @@ func CreateIsoResLogon(msg []byte, bit48, stan string) ([]byte, error) {
 
 func CreateIsoResReversal(msg []byte) ([]byte, error) {
 	isoStr := hex.EncodeToString(msg)
-	isomessage := iso8583.NewMessage(Spec87Hex)
+	isomessage := iso8583.NewMessage(TerminalSpec())
 	err := isomessage.Unpack([]byte(isoStr))
 	if err != nil {
 		return nil, err
@@ -359,11 +360,106 @@ func CreateIsoResReversal(msg []byte) ([]byte, error) {
 	return msgSend, nil
 }
 
+// CreateIsoReversal membuat 0420 ke host dari request terminal yang tersimpan (hex spec terminal),
+// dipakai untuk reversal otomatis ketika host tidak menjawab transaksi. originalStan adalah
+// STAN host transaksi asal, diisi ke bit 90 bersama MTI, bit 7 dan bit 32/33 asal.
+func CreateIsoReversal(isoReq, originalStan, stan, rrn string) ([]byte, error) {
+	msg, err := IsoConvertToAscii([]byte(isoReq))
+	if err != nil {
+		return nil, err
+	}
+
+	isomessage := iso8583.NewMessage(Spec87)
+	err = isomessage.Unpack(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	bit90, err := OriginalDataElements(isomessage, originalStan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = isomessage.Field(90, bit90)
+	if err != nil {
+		return nil, err
+	}
+
+	err = isomessage.Field(11, stan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = isomessage.Field(37, rrn)
+	if err != nil {
+		return nil, err
+	}
+
+	// PIN block tidak dikirim pada reversal
+	isomessage.UnsetField(52)
+	isomessage.MTI("0420")
+
+	rawMessage, err := isomessage.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	return rawMessage, nil
+}
+
+// OriginalDataElements menyusun bit 90 (n 42) dari pesan asal: MTI, STAN,
+// tanggal/jam transmisi bit 7, acquirer ID bit 32 dan forwarding ID bit 33.
+// Elemen yang tidak ada di pesan asal diisi nol. stan mengganti bit 11 pesan
+// asal jika tidak kosong, karena STAN ke host berbeda dengan STAN terminal.
+func OriginalDataElements(original *iso8583.Message, stan string) (string, error) {
+	mti, err := original.GetMTI()
+	if err != nil {
+		return "", fmt.Errorf("original data elements -> get mti: %w", err)
+	}
+
+	if stan == "" {
+		stan, err = original.GetString(11)
+		if err != nil {
+			return "", fmt.Errorf("original data elements -> get stan: %w", err)
+		}
+	}
+
+	elements := []struct {
+		value  string
+		length int
+	}{
+		{mti, 4},
+		{stan, 6},
+		{"", 10},
+		{"", 11},
+		{"", 11},
+	}
+	for i, id := range []int{7, 32, 33} {
+		value, err := original.GetString(id)
+		if err != nil {
+			return "", fmt.Errorf("original data elements -> get bit %d: %w", id, err)
+		}
+		elements[i+2].value = value
+	}
+
+	// Nol di depan dibuang dulu, bit 11 spec gateway 12 digit sedangkan di bit 90 6 digit
+	var bit90 strings.Builder
+	for _, e := range elements {
+		e.value = strings.TrimLeft(e.value, "0")
+		if len(e.value) > e.length {
+			return "", fmt.Errorf("original data elements -> %q longer than %d digits", e.value, e.length)
+		}
+		bit90.WriteString(strings.Repeat("0", e.length-len(e.value)))
+		bit90.WriteString(e.value)
+	}
+	return bit90.String(), nil
+}
+
 // buildErrorResponse adalah fungsi helper untuk membuat respons error ISO 8583
 func BuildErrorResponse(msg, responseCode string, isoType int) ([]byte, error) {
 	var specIso *iso8583.MessageSpec
 	if isoType == 1 {
-		specIso = Spec87Hex
+		specIso = TerminalSpec()
 	} else if isoType == 2 {
 		specIso = Spec87
 	}
@@ -392,18 +488,12 @@ func BuildErrorResponse(msg, responseCode string, isoType int) ([]byte, error) {
 		}
 	}
 
-	responseMTI := ""
-	if len(originalMTI) == 4 {
-		mtiType := string(originalMTI[0])
-		mtiVersion := string(originalMTI[1])
-		mtiOriginator := string(originalMTI[2])
-		if mtiOriginator == "0" {
-			responseMTI = fmt.Sprintf("%s%s1%s", mtiType, mtiVersion, string(originalMTI[3]))
-		} else {
-			responseMTI = originalMTI
+	responseMTI := "0000" // Default MTI untuk pesan yang rusak total
+	if mti, err := ParseMTI(originalMTI); err == nil {
+		responseMTI = originalMTI
+		if response, err := mti.Response(); err == nil {
+			responseMTI = string(response)
 		}
-	} else {
-		responseMTI = "0000" // Default MTI untuk pesan yang rusak total
 	}
 	isomessageRes.MTI(responseMTI)
 
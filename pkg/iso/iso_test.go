@@ -0,0 +1,77 @@
+package iso
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/iso8583"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateIsoReversal(t *testing.T) {
+	isomessage := iso8583.NewMessage(Spec87)
+	isomessage.MTI("0200")
+	for id, value := range map[int]string{
+		2:  "4111111111111111",
+		3:  "310000",
+		4:  "000000010000",
+		7:  "1017093015",
+		11: "000045",
+		32: "360004",
+		41: "TID00001",
+		42: "MID000000000001",
+		52: "1122334455667788",
+	} {
+		require.NoError(t, isomessage.Field(id, value))
+	}
+	msg, err := isomessage.Pack()
+	require.NoError(t, err)
+
+	// iso_req disimpan sebagai hex dari pesan Spec87Hex terminal
+	hexMsg, err := IsoConvertToHex(msg)
+	require.NoError(t, err)
+	isoReq := strings.ToUpper(hex.EncodeToString(hexMsg))
+
+	rawMessage, err := CreateIsoReversal(isoReq, "000000000123", "000000000777", "123456000777")
+	require.NoError(t, err)
+
+	reversal := iso8583.NewMessage(Spec87)
+	require.NoError(t, reversal.Unpack(rawMessage))
+
+	mti, err := reversal.GetMTI()
+	require.NoError(t, err)
+	assert.Equal(t, "0420", mti)
+
+	stan, _ := reversal.GetString(11)
+	assert.Equal(t, "777", stan)
+	rrn, _ := reversal.GetString(37)
+	assert.Equal(t, "123456000777", rrn)
+	pan, _ := reversal.GetString(2)
+	assert.Equal(t, "4111111111111111", pan)
+	amount, _ := reversal.GetString(4)
+	assert.Equal(t, "10000", amount)
+
+	_, ok := reversal.GetFields()[52]
+	assert.False(t, ok, "PIN block tidak ikut di reversal")
+
+	// Bit 90: MTI 0200, STAN host asal, bit 7, acquirer 32 dan forwarding 33 kosong
+	bit90, _ := reversal.GetString(90)
+	assert.Equal(t, "0200"+"000123"+"1017093015"+"00000360004"+"00000000000", bit90)
+	assert.Len(t, bit90, 42)
+}
+
+func TestOriginalDataElements(t *testing.T) {
+	isomessage := iso8583.NewMessage(Spec87)
+	isomessage.MTI("0200")
+	require.NoError(t, isomessage.Field(11, "000000000045"))
+
+	// Tanpa STAN host, STAN pesan asal yang dipakai; bit 7/32/33 kosong diisi nol
+	bit90, err := OriginalDataElements(isomessage, "")
+	require.NoError(t, err)
+	assert.Equal(t, "0200000045"+strings.Repeat("0", 32), bit90)
+
+	_, err = OriginalDataElements(isomessage, "1234567")
+	assert.ErrorContains(t, err, "longer than 6 digits")
+}
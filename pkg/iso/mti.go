@@ -0,0 +1,105 @@
+package iso
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidMTI = errors.New("invalid mti")
+
+// Version adalah digit pertama MTI, versi standar ISO 8583 pesan.
+type Version byte
+
+const (
+	Version87 Version = '0'
+	Version93 Version = '1'
+	Version03 Version = '2'
+)
+
+// ParseVersion membaca versi host dari konfigurasi: "87", "93" atau tahun
+// lengkap. Versi 2003 hanya dikenali di MTI, belum ada spec untuk pesan host.
+func ParseVersion(value string) (Version, error) {
+	switch value {
+	case "", "87", "1987":
+		return Version87, nil
+	case "93", "1993":
+		return Version93, nil
+	case "03", "2003":
+		return 0, fmt.Errorf("%w: iso 8583:2003 %q has no host spec, use 87 or 93", ErrUnsupportedVersion, value)
+	}
+	return 0, fmt.Errorf("unknown iso version %q, use 87 or 93", value)
+}
+
+func (v Version) String() string {
+	switch v {
+	case Version87:
+		return "87"
+	case Version93:
+		return "93"
+	}
+	return fmt.Sprintf("version(%c)", byte(v))
+}
+
+// Kelas pesan, digit kedua MTI.
+const (
+	ClassAuthorization  byte = '1'
+	ClassFinancial      byte = '2'
+	ClassFileAction     byte = '3'
+	ClassReversal       byte = '4'
+	ClassReconciliation byte = '5'
+	ClassAdministrative byte = '6'
+	ClassFeeCollection  byte = '7'
+	ClassNetwork        byte = '8'
+)
+
+// MTI adalah message type indicator 4 digit: versi, kelas, fungsi dan origin.
+type MTI string
+
+// ParseMTI memvalidasi MTI dari pesan. Versi yang dikenal 87, 93 dan 2003.
+func ParseMTI(value string) (MTI, error) {
+	if len(value) != 4 || !isDigits(value) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidMTI, value)
+	}
+	switch Version(value[0]) {
+	case Version87, Version93, Version03:
+	default:
+		return "", fmt.Errorf("%w: %q unknown version", ErrInvalidMTI, value)
+	}
+	return MTI(value), nil
+}
+
+func (m MTI) Version() Version { return Version(m[0]) }
+func (m MTI) Class() byte      { return m[1] }
+func (m MTI) Function() byte   { return m[2] }
+func (m MTI) Origin() byte     { return m[3] }
+
+// IsNetwork true untuk pesan network management (x8xx).
+func (m MTI) IsNetwork() bool { return len(m) == 4 && m.Class() == ClassNetwork }
+
+// IsResponse true untuk fungsi response (digit ketiga ganjil), misal 0210 atau 1430.
+func (m MTI) IsResponse() bool { return len(m) == 4 && (m.Function()-'0')%2 == 1 }
+
+// IsRepeat true untuk pesan ulang (origin ganjil), misal 0421.
+func (m MTI) IsRepeat() bool { return len(m) == 4 && (m.Origin()-'0')%2 == 1 }
+
+// Response mengembalikan MTI balasan: fungsi request/advice/notification naik
+// satu, origin ulang kembali ke origin asal. 0200 -> 0210, 0421 -> 0430,
+// 1804 -> 1814.
+func (m MTI) Response() (MTI, error) {
+	if _, err := ParseMTI(string(m)); err != nil {
+		return "", err
+	}
+	if m.IsResponse() {
+		return "", fmt.Errorf("%w: %s is already a response", ErrInvalidMTI, m)
+	}
+	origin := m.Origin()
+	if m.IsRepeat() {
+		origin--
+	}
+	return MTI([]byte{m[0], m.Class(), m.Function() + 1, origin}), nil
+}
+
+// WithVersion mengganti digit versi MTI.
+func (m MTI) WithVersion(v Version) MTI {
+	return MTI(append([]byte{byte(v)}, m[1:]...))
+}
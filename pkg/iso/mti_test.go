@@ -0,0 +1,65 @@
+package iso
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMTIResponse(t *testing.T) {
+	for request, response := range map[string]string{
+		"0200": "0210",
+		"0220": "0230",
+		"0420": "0430",
+		"0421": "0430",
+		"0800": "0810",
+		"1200": "1210",
+		"1420": "1430",
+		"1804": "1814",
+		"1221": "1230",
+		"2100": "2110",
+	} {
+		mti, err := ParseMTI(request)
+		require.NoError(t, err, request)
+		got, err := mti.Response()
+		require.NoError(t, err, request)
+		assert.Equal(t, MTI(response), got, request)
+		assert.True(t, got.IsResponse(), response)
+	}
+
+	_, err := MTI("0210").Response()
+	assert.ErrorIs(t, err, ErrInvalidMTI)
+
+	for _, value := range []string{"", "020", "02A0", "9200", "02000"} {
+		_, err := ParseMTI(value)
+		assert.ErrorIs(t, err, ErrInvalidMTI, value)
+	}
+}
+
+func TestMTIParts(t *testing.T) {
+	mti := MTI("1804")
+	assert.Equal(t, Version93, mti.Version())
+	assert.True(t, mti.IsNetwork())
+	assert.False(t, mti.IsResponse())
+	assert.False(t, mti.IsRepeat())
+	assert.Equal(t, MTI("0804"), mti.WithVersion(Version87))
+	assert.True(t, MTI("0421").IsRepeat())
+	assert.False(t, MTI("0200").IsNetwork())
+}
+
+func TestParseVersion(t *testing.T) {
+	for value, want := range map[string]Version{"": Version87, "87": Version87, "1993": Version93, "93": Version93} {
+		got, err := ParseVersion(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+	_, err := ParseVersion("88")
+	assert.ErrorContains(t, err, "use 87 or 93")
+	for _, value := range []string{"03", "2003"} {
+		_, err = ParseVersion(value)
+		assert.ErrorIs(t, err, ErrUnsupportedVersion, value)
+	}
+	assert.Equal(t, "93", Version93.String())
+	assert.Equal(t, "version(2)", Version03.String())
+}
@@ -0,0 +1,292 @@
+package iso
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moov-io/iso8583"
+	"github.com/moov-io/iso8583/field"
+	"github.com/moov-io/iso8583/specs"
+)
+
+// Nama spec bawaan, bisa dipakai di konfigurasi sebagai pengganti path file JSON.
+const (
+	SpecNameAscii   = "spec87"
+	SpecNameHex     = "spec87hex"
+	SpecNameX       = "spec87x"
+	SpecNameAscii93 = "spec93"
+)
+
+var ErrInvalidSpec = errors.New("invalid iso spec")
+
+// RequiredFields adalah field yang dibaca atau diisi gateway sendiri (routing,
+// STAN, response code, TID dan network management), wajib ada di setiap spec.
+var RequiredFields = []int{0, 1, 3, 7, 11, 39, 41, 70}
+
+var (
+	specLock     sync.RWMutex
+	terminalSpec = Spec87Hex
+)
+
+// TerminalSpec mengembalikan spec kanal terminal (bentuk hex string dari
+// pesan binary EDC). Default Spec87Hex.
+func TerminalSpec() *iso8583.MessageSpec {
+	specLock.RLock()
+	defer specLock.RUnlock()
+	return terminalSpec
+}
+
+// SetTerminalSpec mengganti spec kanal terminal, dipanggil sekali saat startup.
+func SetTerminalSpec(spec *iso8583.MessageSpec) {
+	specLock.Lock()
+	defer specLock.Unlock()
+	terminalSpec = spec
+}
+
+// BuiltinSpec mencari spec bawaan berdasarkan nama.
+func BuiltinSpec(name string) (*iso8583.MessageSpec, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case SpecNameAscii:
+		return Spec87, true
+	case SpecNameHex:
+		return Spec87Hex, true
+	case SpecNameX:
+		return Spec87X, true
+	case SpecNameAscii93:
+		return Spec93, true
+	}
+	return nil, false
+}
+
+// ResolveSpec membaca spec dari nama spec bawaan atau path file JSON. Nama spec
+// bawaan selalu memakai literal Go di paket ini, itulah sumber kebenarannya.
+// File specs/*.json hanya hasil ExportSpec dari literal tersebut (dijaga sama
+// oleh TestSpecJSONGolden dan TestSpecWireFixtures) dan dipakai sebagai titik
+// awal spec host sendiri lewat path file.
+func ResolveSpec(value string) (*iso8583.MessageSpec, error) {
+	if spec, ok := BuiltinSpec(value); ok {
+		return spec, nil
+	}
+	return LoadSpec(strings.TrimSpace(value))
+}
+
+// LoadSpec membaca dan memvalidasi file spec JSON.
+func LoadSpec(path string) (*iso8583.MessageSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load spec -> %w", err)
+	}
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("load spec %s -> %w", path, err)
+	}
+	return spec, nil
+}
+
+// specFile adalah format JSON spec moov-io ditambah key "codec" per field untuk
+// packer custom (lihat Codec).
+type specFile struct {
+	Name   string     `json:"name,omitempty"`
+	Fields specFields `json:"fields"`
+}
+
+type fieldFile struct {
+	Type              string          `json:"type,omitempty"`
+	Length            int             `json:"length,omitempty"`
+	Description       string          `json:"description,omitempty"`
+	Enc               string          `json:"enc,omitempty"`
+	Prefix            string          `json:"prefix,omitempty"`
+	Padding           json.RawMessage `json:"padding,omitempty"`
+	Tag               json.RawMessage `json:"tag,omitempty"`
+	Subfields         json.RawMessage `json:"subfields,omitempty"`
+	Bitmap            json.RawMessage `json:"bitmap,omitempty"`
+	DisableAutoExpand bool            `json:"disableAutoExpand,omitempty"`
+	Codec             string          `json:"codec,omitempty"`
+}
+
+// specFields ditulis dengan urutan nomor field agar file mudah dibandingkan.
+type specFields map[string]*fieldFile
+
+func (f specFields) MarshalJSON() ([]byte, error) {
+	ids, err := f.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, id := range ids {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(f[strconv.Itoa(id)])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(buf, "%q:", strconv.Itoa(id))
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (f specFields) ids() ([]int, error) {
+	ids := make([]int, 0, len(f))
+	for key := range f {
+		id, err := strconv.Atoi(key)
+		if err != nil || id < 0 || id > 192 {
+			return nil, fmt.Errorf("%w: invalid field number %q", ErrInvalidSpec, key)
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// ParseSpec membaca spec dari JSON. Key yang tidak dikenal, codec yang tidak
+// terdaftar dan field wajib yang hilang ditolak.
+func ParseSpec(data []byte) (*iso8583.MessageSpec, error) {
+	var file specFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse spec -> %w: %v", ErrInvalidSpec, err)
+	}
+	ids, err := file.Fields.ids()
+	if err != nil {
+		return nil, fmt.Errorf("parse spec -> %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("parse spec -> %w: no fields", ErrInvalidSpec)
+	}
+
+	spec, err := specs.Builder.ImportJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse spec -> %w: %v", ErrInvalidSpec, err)
+	}
+
+	for _, id := range ids {
+		name := file.Fields[strconv.Itoa(id)].Codec
+		if name == "" {
+			continue
+		}
+		codec, err := LookupCodec(name)
+		if err != nil {
+			return nil, fmt.Errorf("parse spec -> %w: field %d: %v", ErrInvalidSpec, id, err)
+		}
+		fieldSpec := spec.Fields[id].Spec()
+		fieldSpec.Packer = codec
+		fieldSpec.Unpacker = codec
+	}
+
+	if err := ValidateSpec(spec); err != nil {
+		return nil, fmt.Errorf("parse spec -> %w", err)
+	}
+	return spec, nil
+}
+
+// ValidateSpec memastikan spec bisa dipakai gateway: field wajib ada, MTI 4
+// digit, bit 1 berupa bitmap dan setiap field punya encoding serta prefix.
+func ValidateSpec(spec *iso8583.MessageSpec) error {
+	if spec == nil || len(spec.Fields) == 0 {
+		return fmt.Errorf("%w: no fields", ErrInvalidSpec)
+	}
+
+	for _, id := range RequiredFields {
+		if _, ok := spec.Fields[id]; !ok {
+			return fmt.Errorf("%w: missing field %d", ErrInvalidSpec, id)
+		}
+	}
+	if length := spec.Fields[0].Spec().Length; length != 4 {
+		return fmt.Errorf("%w: field 0 (MTI) length %d, expected 4", ErrInvalidSpec, length)
+	}
+	if _, ok := spec.Fields[1].(*field.Bitmap); !ok {
+		return fmt.Errorf("%w: field 1 must be a Bitmap", ErrInvalidSpec)
+	}
+
+	for id, f := range spec.Fields {
+		fieldSpec := f.Spec()
+		if fieldSpec == nil {
+			return fmt.Errorf("%w: field %d has no spec", ErrInvalidSpec, id)
+		}
+		if fieldSpec.Enc == nil || fieldSpec.Pref == nil {
+			return fmt.Errorf("%w: field %d needs enc and prefix", ErrInvalidSpec, id)
+		}
+		if _, bitmap := f.(*field.Bitmap); !bitmap && fieldSpec.Length <= 0 {
+			return fmt.Errorf("%w: field %d length must be positive", ErrInvalidSpec, id)
+		}
+	}
+	return nil
+}
+
+// ExportSpec menulis spec ke JSON dengan format yang dibaca ParseSpec. Field
+// dengan packer custom harus memakai Codec agar bisa ditulis.
+func ExportSpec(spec *iso8583.MessageSpec) ([]byte, error) {
+	raw, err := specs.Builder.ExportJSON(spec)
+	if err != nil {
+		return nil, fmt.Errorf("export spec -> %w", err)
+	}
+
+	var file specFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("export spec -> %w", err)
+	}
+	for id, f := range spec.Fields {
+		fieldSpec := f.Spec()
+		if codec, ok := codecOf(fieldSpec); ok {
+			file.Fields[strconv.Itoa(id)].Codec = codec.Name
+			continue
+		}
+		if fieldSpec.Packer != nil || fieldSpec.Unpacker != nil {
+			return nil, fmt.Errorf("export spec -> field %d: custom packer is not a registered codec", id)
+		}
+	}
+
+	compact, err := json.Marshal(file)
+	if err != nil {
+		return nil, fmt.Errorf("export spec -> %w", err)
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact, "", "\t"); err != nil {
+		return nil, fmt.Errorf("export spec -> %w", err)
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// Convert memindahkan isi pesan dari satu spec ke spec lain, misal dari
+// format host ke Spec87 internal. Pesan dikembalikan apa adanya jika spec sama.
+func Convert(msg []byte, from, to *iso8583.MessageSpec) ([]byte, error) {
+	if from == to {
+		return msg, nil
+	}
+
+	source := iso8583.NewMessage(from)
+	if err := source.Unpack(msg); err != nil {
+		return nil, fmt.Errorf("convert iso -> unpack: %w", err)
+	}
+
+	target := iso8583.NewMessage(to)
+	for id := range source.GetFields() {
+		value, err := source.GetString(id)
+		if err != nil {
+			return nil, fmt.Errorf("convert iso -> get bit %d: %w", id, err)
+		}
+		if err := target.Field(id, value); err != nil {
+			return nil, fmt.Errorf("convert iso -> set bit %d: %w", id, err)
+		}
+	}
+
+	packed, err := target.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("convert iso -> pack: %w", err)
+	}
+	return packed, nil
+}
@@ -0,0 +1,68 @@
+package iso
+
+import (
+	"github.com/moov-io/iso8583"
+	"github.com/moov-io/iso8583/encoding"
+	"github.com/moov-io/iso8583/field"
+	"github.com/moov-io/iso8583/prefix"
+)
+
+// Spec93 adalah layout ISO 8583:1993 dalam ASCII untuk host versi 93. Field
+// yang tidak berubah sejak 1987 memakai definisi Spec87.
+var Spec93 *iso8583.MessageSpec = &iso8583.MessageSpec{
+	Name:   "ISO 8583:1993 ASCII",
+	Fields: spec93Fields(),
+}
+
+func spec93Fields() map[int]field.Field {
+	fields := make(map[int]field.Field, len(Spec87.Fields))
+	for id, f := range Spec87.Fields {
+		fields[id] = f
+	}
+
+	fields[12] = field.NewString(&field.Spec{
+		Length:      12,
+		Description: "Date and Time, Local Transaction (YYMMDDhhmmss)",
+		Enc:         encoding.ASCII,
+		Pref:        prefix.ASCII.Fixed,
+	})
+	fields[13] = field.NewString(&field.Spec{
+		Length:      4,
+		Description: "Date, Effective (YYMM)",
+		Enc:         encoding.ASCII,
+		Pref:        prefix.ASCII.Fixed,
+	})
+	fields[22] = field.NewString(&field.Spec{
+		Length:      12,
+		Description: "Point of Service Data Code",
+		Enc:         encoding.ASCII,
+		Pref:        prefix.ASCII.Fixed,
+	})
+	fields[24] = field.NewString(&field.Spec{
+		Length:      3,
+		Description: "Function Code",
+		Enc:         encoding.ASCII,
+		Pref:        prefix.ASCII.Fixed,
+	})
+	fields[25] = field.NewString(&field.Spec{
+		Length:      4,
+		Description: "Message Reason Code",
+		Enc:         encoding.ASCII,
+		Pref:        prefix.ASCII.Fixed,
+	})
+	fields[39] = field.NewString(&field.Spec{
+		Length:      3,
+		Description: "Action Code",
+		Enc:         encoding.ASCII,
+		Pref:        prefix.ASCII.Fixed,
+	})
+	fields[56] = field.NewString(&field.Spec{
+		Length:      35,
+		Description: "Original Data Elements",
+		Enc:         encoding.ASCII,
+		Pref:        prefix.ASCII.LL,
+	})
+	delete(fields, 90)
+
+	return fields
+}
@@ -441,5 +441,11 @@ var Spec87X *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
 		}),
+		128: field.NewString(&field.Spec{
+			Length:      8,
+			Description: "Message Authentication Code (MAC)",
+			Enc:         encoding.BytesToASCIIHex,
+			Pref:        prefix.Hex.Fixed,
+		}),
 	},
 }
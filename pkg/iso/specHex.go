@@ -1,9 +1,6 @@
 package iso
 
 import (
-	"fmt"
-	"strings"
-
 	"github.com/moov-io/iso8583"
 	"github.com/moov-io/iso8583/encoding"
 	"github.com/moov-io/iso8583/field"
@@ -30,54 +27,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LL,
 			Pad:         padding.Right('F'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				// if spec.Pad != nil {
-				// 	value = spec.Pad.Pad(value, spec.Length)
-				// }
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				if len(encodedValue)%2 != 0 {
-					encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if valueLength%2 != 0 {
-					prefBytes = prefBytes + 1
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecOddLL,
+			Unpacker:    CodecOddLL,
 		}),
 		3: field.NewString(&field.Spec{
 			Length:      6,
@@ -137,25 +88,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecNoUnpad,
+			Unpacker:    CodecNoUnpad,
 		}),
 		12: field.NewString(&field.Spec{
 			Length:      6,
@@ -205,46 +139,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		20: field.NewString(&field.Spec{
 			Length:      3,
@@ -252,46 +148,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		21: field.NewString(&field.Spec{
 			Length:      3,
@@ -299,46 +157,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		22: field.NewString(&field.Spec{
 			Length:      3,
@@ -346,46 +166,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		23: field.NewString(&field.Spec{
 			Length:      3,
@@ -393,46 +175,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		24: field.NewString(&field.Spec{
 			Length:      3,
@@ -440,46 +184,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		25: field.NewString(&field.Spec{
 			Length:      2,
@@ -499,46 +205,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		28: field.NewString(&field.Spec{
 			Length:      8,
@@ -570,57 +238,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LL,
 			Pad:         padding.Right('F'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				// if spec.Pad != nil {
-				// 	value = spec.Pad.Pad(value, spec.Length)
-				// }
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				// if len(encodedValue) == maxLength {
-				// 	encodedValue = spec.Pad.Pad(encodedValue, maxLength+1)
-				// }
-				if len(encodedValue)%2 != 0 {
-					encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if valueLength%2 != 0 {
-					prefBytes = prefBytes + 1
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecOddLL,
+			Unpacker:    CodecOddLL,
 		}),
 		33: field.NewString(&field.Spec{
 			Length:      11,
@@ -628,54 +247,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LL,
 			Pad:         padding.Right('F'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				// if spec.Pad != nil {
-				// 	value = spec.Pad.Pad(value, spec.Length)
-				// }
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				if len(encodedValue)%2 != 0 {
-					encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if valueLength%2 != 0 {
-					prefBytes = prefBytes + 1
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecOddLL,
+			Unpacker:    CodecOddLL,
 		}),
 		34: field.NewString(&field.Spec{
 			Length:      28,
@@ -683,54 +256,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LL,
 			Pad:         padding.Right('F'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				// if spec.Pad != nil {
-				// 	value = spec.Pad.Pad(value, spec.Length)
-				// }
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				if len(encodedValue)%2 != 0 {
-					encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if valueLength%2 != 0 {
-					prefBytes = prefBytes + 1
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecOddLL,
+			Unpacker:    CodecOddLL,
 		}),
 		35: field.NewString(&field.Spec{
 			Length:      37,
@@ -738,58 +265,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LL,
 			Pad:         padding.Right('F'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				// if spec.Pad != nil {
-				// 	value = spec.Pad.Pad(value, spec.Length)
-				// }
-				if strings.Contains(string(value), "=") {
-					newVal := strings.ReplaceAll(string(value), "=", "D")
-					value = []byte(newVal)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				if len(encodedValue)%2 != 0 {
-					encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if valueLength%2 != 0 {
-					prefBytes = prefBytes + 1
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecTrack2,
+			Unpacker:    CodecTrack2,
 		}),
 		36: field.NewString(&field.Spec{
 			Length:      104,
@@ -821,46 +298,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		41: field.NewString(&field.Spec{
 			Length:      8,
@@ -893,210 +332,32 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LL,
 			Pad:         padding.Right('F'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				// if spec.Pad != nil {
-				// 	value = spec.Pad.Pad(value, spec.Length)
-				// }
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				if len(encodedValue)%2 != 0 {
-					encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if valueLength%2 != 0 {
-					prefBytes = prefBytes + 1
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecOddLL,
+			Unpacker:    CodecOddLL,
 		}),
 		46: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Additional data (ISO)",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		47: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Additional data (National)",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		48: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Additional data (Private)",
 			Enc:         encoding.BytesToASCIIHex,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		49: field.NewString(&field.Spec{
 			Length:      3,
@@ -1110,46 +371,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		51: field.NewString(&field.Spec{
 			Length:      3,
@@ -1157,46 +380,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		52: field.NewString(&field.Spec{
 			Length:      16,
@@ -1215,549 +400,88 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Description: "Additional Amounts",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength * 2
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLHex,
+			Unpacker:    CodecLLLHex,
 		}),
 		55: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "ICC Data – EMV Having Multiple Tags",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength * 2
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLHex,
+			Unpacker:    CodecLLLHex,
 		}),
 		56: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (ISO)",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		57: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (National)",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		58: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (National)",
 			Enc:         encoding.BytesToASCIIHex,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)-1)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-				zero := make([]byte, 1)
-				lengthPrefix = append(zero, lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBinary,
+			Unpacker:    CodecLLLBinary,
 		}),
 		59: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (National)",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		60: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (National)",
 			Enc:         encoding.BytesToASCIIHex,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		61: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (Private)",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		62: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (Private)",
 			Enc:         encoding.BytesToASCIIHex,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		63: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Reserved (Private)",
 			Enc:         encoding.BytesToASCIIHex,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		64: field.NewString(&field.Spec{
 			Length:      8,
 			Description: "Message Authentication Code (MAC)",
 			Enc:         encoding.ASCII,
 			Pref:        prefix.Hex.Fixed,
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength * 2
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecMacHex,
+			Unpacker:    CodecMacHex,
 		}),
 		70: field.NewString(&field.Spec{
 			Length:      3,
@@ -1765,46 +489,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.Fixed,
 			Pad:         padding.Left('0'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length+1)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length + 1
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecOddFixed,
+			Unpacker:    CodecOddFixed,
 		}),
 		90: field.NewString(&field.Spec{
 			Length:      42,
@@ -1824,54 +510,8 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Enc:         encoding.ASCII,
 			Pref:        prefix.ASCII.LL,
 			Pad:         padding.Right('F'),
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				// if spec.Pad != nil {
-				// 	value = spec.Pad.Pad(value, spec.Length)
-				// }
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue))
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				if len(encodedValue)%2 != 0 {
-					encodedValue = spec.Pad.Pad(encodedValue, len(encodedValue)+1)
-				}
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if valueLength%2 != 0 {
-					prefBytes = prefBytes + 1
-				}
-
-				return value, read + prefBytes, nil
-			}),
+			Packer:      CodecOddLL,
+			Unpacker:    CodecOddLL,
 		}),
 		102: field.NewString(&field.Spec{
 			Length:      28,
@@ -1890,104 +530,24 @@ var Spec87Hex *iso8583.MessageSpec = &iso8583.MessageSpec{
 			Description: "Original Data Elements",
 			Enc:         encoding.BytesToASCIIHex,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
 		}),
 		127: field.NewString(&field.Spec{
 			Length:      999,
 			Description: "Destination Institution Identification Code",
 			Enc:         encoding.BytesToASCIIHex,
 			Pref:        prefix.ASCII.LLL,
-			Packer: field.PackerFunc(func(value []byte, spec *field.Spec) ([]byte, error) {
-				if spec.Pad != nil {
-					value = spec.Pad.Pad(value, spec.Length)
-				}
-
-				encodedValue, err := spec.Enc.Encode(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode content: %w", err)
-				}
-
-				// Encode the length of the packed data, not the length of the value
-				maxLength := spec.Length
-
-				// Encode the length of the encoded value
-				lengthPrefix, err := spec.Pref.EncodeLength(maxLength, len(encodedValue)/2)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode length: %w", err)
-				}
-
-				lengthPrefix = append([]byte("0"), lengthPrefix...)
-
-				return append(lengthPrefix, encodedValue...), nil
-			}),
-			Unpacker: field.UnpackerFunc(func(packedFieldValue []byte, spec *field.Spec) ([]byte, int, error) {
-				maxEncodedValueLength := spec.Length
-
-				encodedValueLength, prefBytes, err := spec.Pref.DecodeLength(maxEncodedValueLength, packedFieldValue[1:])
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode length: %w", err)
-				}
-
-				// for BCD encoding, the length of the packed data is twice the length of the encoded value
-				valueLength := encodedValueLength
-
-				// Decode the packed data length
-				value, read, err := spec.Enc.Decode(packedFieldValue[prefBytes+1:], valueLength)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to decode content: %w", err)
-				}
-
-				if spec.Pad != nil {
-					value = spec.Pad.Unpad(value)
-				}
-
-				return value, read + prefBytes + 1, nil
-			}),
+			Packer:      CodecLLLBytes,
+			Unpacker:    CodecLLLBytes,
+		}),
+		128: field.NewString(&field.Spec{
+			Length:      8,
+			Description: "Message Authentication Code (MAC)",
+			Enc:         encoding.ASCII,
+			Pref:        prefix.Hex.Fixed,
+			Packer:      CodecMacHex,
+			Unpacker:    CodecMacHex,
 		}),
 	},
 }
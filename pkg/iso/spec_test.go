@@ -0,0 +1,232 @@
+package iso
+
+import (
+	"encoding/hex"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/iso8583"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateSpecs = flag.Bool("update", false, "tulis ulang specs/*.json dari spec bawaan")
+
+var builtinSpecs = map[string]*iso8583.MessageSpec{
+	SpecNameAscii:   Spec87,
+	SpecNameHex:     Spec87Hex,
+	SpecNameX:       Spec87X,
+	SpecNameAscii93: Spec93,
+}
+
+func TestSpecJSONGolden(t *testing.T) {
+	for name, builtin := range builtinSpecs {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("specs", name+".json")
+			exported, err := ExportSpec(builtin)
+			require.NoError(t, err)
+			if *updateSpecs {
+				require.NoError(t, os.WriteFile(path, exported, 0o644))
+			}
+
+			golden, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, string(golden), string(exported), "jalankan go test ./pkg/iso -run Golden -update")
+
+			loaded, err := LoadSpec(path)
+			require.NoError(t, err)
+			reexported, err := ExportSpec(loaded)
+			require.NoError(t, err)
+			assert.Equal(t, string(golden), string(reexported))
+
+			// Setiap field di-pack dan di-unpack sama persis oleh spec bawaan dan spec JSON
+			packed := 0
+			for id, f := range builtin.Fields {
+				if id < 2 {
+					continue
+				}
+				value := sampleValue(f.Spec().Length)
+				want := iso8583.NewMessage(builtin)
+				want.MTI("0200")
+				if want.Field(id, value) != nil {
+					continue
+				}
+				wantRaw, err := want.Pack()
+				if err != nil {
+					continue
+				}
+				packed++
+
+				got := iso8583.NewMessage(loaded)
+				got.MTI("0200")
+				require.NoError(t, got.Field(id, value), "field %d", id)
+				gotRaw, err := got.Pack()
+				require.NoError(t, err, "field %d", id)
+				assert.Equal(t, string(wantRaw), string(gotRaw), "field %d", id)
+
+				wantBack := iso8583.NewMessage(builtin)
+				gotBack := iso8583.NewMessage(loaded)
+				// Beberapa codec bawaan tidak simetris untuk semua nilai (misal bit 58),
+				// yang penting hasilnya sama dengan spec bawaan
+				wantErr := wantBack.Unpack(wantRaw)
+				gotErr := gotBack.Unpack(gotRaw)
+				assert.Equal(t, wantErr == nil, gotErr == nil, "field %d", id)
+				if wantErr != nil {
+					continue
+				}
+				wantValue, _ := wantBack.GetString(id)
+				gotValue, _ := gotBack.GetString(id)
+				assert.Equal(t, wantValue, gotValue, "field %d", id)
+			}
+			assert.Greater(t, packed, len(builtin.Fields)/2)
+		})
+	}
+}
+
+func sampleValue(length int) string {
+	if length > 12 || length == 0 {
+		length = 12
+	}
+	return strings.Repeat("1", length)
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("specs", SpecNameHex+".json"))
+	require.NoError(t, err)
+	valid := string(golden)
+
+	tests := map[string]struct {
+		data    string
+		message string
+	}{
+		"json rusak":        {`{"fields":`, "unexpected EOF"},
+		"key tidak dikenal": {strings.Replace(valid, `"length": 19`, `"lenght": 19`, 1), `unknown field "lenght"`},
+		"codec tidak dikenal": {strings.Replace(valid, `"codec": "track2"`, `"codec": "track3"`, 1),
+			`field 35: unknown codec "track3"`},
+		"prefix tidak dikenal": {strings.Replace(valid, `"prefix": "ASCII.LL"`, `"prefix": "ASCII.LLLLL"`, 1),
+			"error importing field: 2"},
+		"nomor field": {`{"fields":{"x":{"type":"String"}}}`, `invalid field number "x"`},
+		"tanpa field": {`{"fields":{}}`, "no fields"},
+		"field wajib": {removeField(t, valid, "41"), "missing field 41"},
+		"panjang mti": {strings.Replace(valid, `"length": 4,`, `"length": 6,`, 1), "field 0 (MTI) length 6"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSpec([]byte(tt.data))
+			require.ErrorIs(t, err, ErrInvalidSpec)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+
+	_, err = LoadSpec(filepath.Join("specs", "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// removeField menghapus satu field dari spec JSON yang ditulis ExportSpec.
+func removeField(t *testing.T, data, id string) string {
+	start := strings.Index(data, "\t\t\""+id+"\": {")
+	require.GreaterOrEqual(t, start, 0)
+	end := strings.Index(data[start:], "\n\t\t}")
+	require.Greater(t, end, 0)
+	return data[:start] + strings.TrimPrefix(data[start+end+len("\n\t\t}"):], ",\n")
+}
+
+func TestResolveSpec(t *testing.T) {
+	spec, err := ResolveSpec("Spec87Hex")
+	require.NoError(t, err)
+	assert.Same(t, Spec87Hex, spec)
+
+	spec, err = ResolveSpec(filepath.Join("specs", SpecNameAscii+".json"))
+	require.NoError(t, err)
+	assert.NotSame(t, Spec87, spec)
+	assert.NoError(t, ValidateSpec(spec))
+}
+
+func TestConvert(t *testing.T) {
+	isomessage := iso8583.NewMessage(Spec87)
+	isomessage.MTI("0200")
+	for id, value := range map[int]string{
+		2:  "4111111111111111",
+		3:  "000000",
+		4:  "000000010000",
+		11: "000045",
+		35: "4111111111111111=2512101",
+		41: "TID00001",
+	} {
+		require.NoError(t, isomessage.Field(id, value))
+	}
+	msg, err := isomessage.Pack()
+	require.NoError(t, err)
+
+	same, err := Convert(msg, Spec87, Spec87)
+	require.NoError(t, err)
+	assert.Equal(t, msg, same)
+
+	hexMsg, err := Convert(msg, Spec87, Spec87Hex)
+	require.NoError(t, err)
+	assert.NotEqual(t, string(msg), string(hexMsg))
+
+	back, err := Convert(hexMsg, Spec87Hex, Spec87)
+	require.NoError(t, err)
+	result := iso8583.NewMessage(Spec87)
+	require.NoError(t, result.Unpack(back))
+	pan, _ := result.GetString(2)
+	assert.Equal(t, "4111111111111111", pan)
+	tid, _ := result.GetString(41)
+	assert.Equal(t, "TID00001", tid)
+
+	_, err = Convert([]byte("0200"), Spec87, Spec87Hex)
+	assert.Error(t, err)
+}
+
+// wireFixtures adalah pesan 0200 yang di-pack oleh spec bawaan sebelum spec
+// bisa dibaca dari JSON. Spec bawaan dan file specs/*.json harus menghasilkan
+// byte yang sama persis.
+var wireFixtures = map[string]string{
+	SpecNameAscii: "30323030343030303030303032303030313230313136343131313131313131313131313131313234343131313131313131313131313131313D32353132313031303132333435363738394142434445463032343946303230363030303030303031303030303946323730313331333133323332333333333334333433353335333633363337333733383338",
+	SpecNameHex:   "30323030343030303030303032303030313230313136343131313131313131313131313131313234343131313131313131313131313131314432353132313031303132333435363738394142434445463030313239463032303630303030303030313030303039463237303131313232333334343535363637373838",
+	SpecNameX:     "30323030343030303030303032303030313230313136343131313131313131313131313131313234343131313131313131313131313131313D32353132313031303132333435363738394142434445463032343946303230363030303030303031303030303946323730313331333133323332333333333334333433353335333633363337333733383338",
+}
+
+var wireFixtureFields = map[int]string{
+	2:  "4111111111111111",
+	35: "4111111111111111=2512101",
+	52: "0123456789ABCDEF",
+	55: "9F02060000000100009F2701",
+	64: "1122334455667788",
+}
+
+func TestSpecWireFixtures(t *testing.T) {
+	for name, fixture := range wireFixtures {
+		want, err := hex.DecodeString(fixture)
+		require.NoError(t, err)
+
+		loaded, err := LoadSpec(filepath.Join("specs", name+".json"))
+		require.NoError(t, err)
+		for source, spec := range map[string]*iso8583.MessageSpec{"bawaan": builtinSpecs[name], "json": loaded} {
+			t.Run(name+"/"+source, func(t *testing.T) {
+				isomessage := iso8583.NewMessage(spec)
+				isomessage.MTI("0200")
+				for id, value := range wireFixtureFields {
+					require.NoError(t, isomessage.Field(id, value), "field %d", id)
+				}
+				got, err := isomessage.Pack()
+				require.NoError(t, err)
+				assert.Equal(t, fixture, strings.ToUpper(hex.EncodeToString(got)))
+
+				back := iso8583.NewMessage(spec)
+				require.NoError(t, back.Unpack(want))
+				// Bit 64 tidak dicek di sini, unpack BytesToASCIIHex bawaan moov-io
+				// tidak simetris dengan pack-nya
+				for _, id := range []int{2, 52, 55} {
+					value, err := back.GetString(id)
+					require.NoError(t, err, "field %d", id)
+					assert.Equal(t, wireFixtureFields[id], value, "field %d", id)
+				}
+			})
+		}
+	}
+}
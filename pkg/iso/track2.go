@@ -0,0 +1,170 @@
+package iso
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/iso8583"
+)
+
+var (
+	ErrInvalidTrack2 = errors.New("invalid track 2")
+	ErrInvalidPAN    = errors.New("invalid pan")
+	ErrCardExpired   = errors.New("card expired")
+)
+
+// Track2 adalah isi track 2 (ISO 7813): PAN, separator, expiry YYMM, service
+// code lalu discretionary data.
+type Track2 struct {
+	PAN           string
+	Expiry        string
+	ServiceCode   string
+	Discretionary string
+}
+
+// ParseTrack2 membaca track 2 dari bit 35. Separator boleh '=' atau 'D' (bentuk
+// nibble), start/end sentinel dan padding F di akhir diabaikan.
+func ParseTrack2(data string) (Track2, error) {
+	data = strings.TrimSuffix(strings.TrimPrefix(strings.ToUpper(data), ";"), "?")
+
+	sep := strings.IndexAny(data, "=D")
+	if sep < 0 {
+		return Track2{}, fmt.Errorf("%w: separator not found", ErrInvalidTrack2)
+	}
+	pan, rest := data[:sep], data[sep+1:]
+	if len(pan) < 12 || len(pan) > 19 || !isDigits(pan) {
+		return Track2{}, fmt.Errorf("%w: pan length %d", ErrInvalidTrack2, len(pan))
+	}
+
+	track := Track2{PAN: pan}
+	// Expiry dan service code boleh kosong, ditandai separator tambahan
+	if strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, "D") {
+		rest = rest[1:]
+	} else {
+		if len(rest) < 4 || !isDigits(rest[:4]) {
+			return Track2{}, fmt.Errorf("%w: expiry", ErrInvalidTrack2)
+		}
+		track.Expiry, rest = rest[:4], rest[4:]
+	}
+	if strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, "D") {
+		rest = rest[1:]
+	} else {
+		if len(rest) < 3 || !isDigits(rest[:3]) {
+			return Track2{}, fmt.Errorf("%w: service code", ErrInvalidTrack2)
+		}
+		track.ServiceCode, rest = rest[:3], rest[3:]
+	}
+	track.Discretionary = strings.TrimRight(rest, "F")
+
+	return track, nil
+}
+
+// Card adalah data kartu dari request: PAN dan expiry dari bit 2 dan 14, atau
+// dari track 2 jika terminal hanya mengirim bit 35.
+type Card struct {
+	PAN         string
+	Expiry      string // YYMM
+	ServiceCode string
+}
+
+// ReadCard mengambil data kartu dari pesan. PAN bit 2 harus sama dengan PAN
+// track 2 jika keduanya dikirim.
+func ReadCard(isomessage *iso8583.Message) (Card, error) {
+	var card Card
+	var err error
+
+	card.PAN, err = isomessage.GetString(2)
+	if err != nil {
+		return card, fmt.Errorf("unpack pan: %w", err)
+	}
+	card.Expiry, err = isomessage.GetString(14)
+	if err != nil {
+		return card, fmt.Errorf("unpack expiry: %w", err)
+	}
+
+	data, err := isomessage.GetString(35)
+	if err != nil {
+		return card, fmt.Errorf("unpack track 2: %w", err)
+	}
+	if data == "" {
+		return card, nil
+	}
+
+	track, err := ParseTrack2(data)
+	if err != nil {
+		return card, err
+	}
+	if card.PAN != "" && card.PAN != track.PAN {
+		return card, fmt.Errorf("%w: pan differs from track 2", ErrInvalidPAN)
+	}
+	card.PAN, card.ServiceCode = track.PAN, track.ServiceCode
+	if card.Expiry == "" {
+		card.Expiry = track.Expiry
+	}
+	return card, nil
+}
+
+// Validate memeriksa check digit Luhn PAN dan masa berlaku kartu. Expiry kosong
+// (misal transaksi tanpa kartu) tidak diperiksa.
+func (c Card) Validate(now time.Time) error {
+	if !Luhn(c.PAN) {
+		return fmt.Errorf("%w: luhn check failed", ErrInvalidPAN)
+	}
+	if c.Expiry == "" {
+		return nil
+	}
+	expired, err := Expired(c.Expiry, now)
+	if err != nil {
+		return err
+	}
+	if expired {
+		return fmt.Errorf("%w: %s", ErrCardExpired, c.Expiry)
+	}
+	return nil
+}
+
+// Luhn memeriksa check digit PAN (ISO/IEC 7812).
+func Luhn(pan string) bool {
+	if len(pan) < 12 || len(pan) > 19 || !isDigits(pan) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(pan); i++ {
+		digit := int(pan[len(pan)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// Expired mengembalikan true jika expiry YYMM sudah lewat. Kartu masih berlaku
+// sampai akhir bulan expiry.
+func Expired(expiry string, now time.Time) (bool, error) {
+	if len(expiry) != 4 || !isDigits(expiry) {
+		return false, fmt.Errorf("%w: expiry %q", ErrInvalidTrack2, expiry)
+	}
+	month := int(expiry[2]-'0')*10 + int(expiry[3]-'0')
+	if month < 1 || month > 12 {
+		return false, fmt.Errorf("%w: expiry month %q", ErrInvalidTrack2, expiry)
+	}
+	year := 2000 + int(expiry[0]-'0')*10 + int(expiry[1]-'0')
+
+	end := time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, now.Location())
+	return !now.Before(end), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
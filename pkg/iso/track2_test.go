@@ -0,0 +1,76 @@
+package iso
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/iso8583"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrack2(t *testing.T) {
+	track, err := ParseTrack2(";4111111111111111=25121011234500000?")
+	require.NoError(t, err)
+	assert.Equal(t, Track2{PAN: "4111111111111111", Expiry: "2512", ServiceCode: "101", Discretionary: "1234500000"}, track)
+
+	// Bentuk nibble dari chip: separator D dan padding F
+	track, err = ParseTrack2("4111111111111111D2512201123F")
+	require.NoError(t, err)
+	assert.Equal(t, Track2{PAN: "4111111111111111", Expiry: "2512", ServiceCode: "201", Discretionary: "123"}, track)
+
+	// Expiry kosong ditandai separator tambahan
+	track, err = ParseTrack2("4111111111111111==101")
+	require.NoError(t, err)
+	assert.Equal(t, "", track.Expiry)
+	assert.Equal(t, "101", track.ServiceCode)
+
+	for _, data := range []string{"4111111111111111", "41111=2512101", "4111111111111111=25"} {
+		_, err = ParseTrack2(data)
+		assert.ErrorIs(t, err, ErrInvalidTrack2, data)
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	assert.True(t, Luhn("4111111111111111"))
+	assert.True(t, Luhn("4012345678909"))
+	assert.False(t, Luhn("4111111111111112"))
+	assert.False(t, Luhn("41111111111A1111"))
+	assert.False(t, Luhn(""))
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2025, 12, 31, 23, 59, 0, 0, time.UTC)
+	expired, err := Expired("2512", now)
+	require.NoError(t, err)
+	assert.False(t, expired, "berlaku sampai akhir bulan")
+
+	expired, err = Expired("2511", now)
+	require.NoError(t, err)
+	assert.True(t, expired)
+
+	_, err = Expired("2513", now)
+	assert.ErrorIs(t, err, ErrInvalidTrack2)
+}
+
+func TestReadCard(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	isomessage := iso8583.NewMessage(Spec87)
+	require.NoError(t, isomessage.Field(35, "4111111111111111D2512101"))
+	card, err := ReadCard(isomessage)
+	require.NoError(t, err)
+	assert.Equal(t, Card{PAN: "4111111111111111", Expiry: "2512", ServiceCode: "101"}, card)
+	assert.NoError(t, card.Validate(now))
+	assert.ErrorIs(t, card.Validate(now.AddDate(1, 0, 0)), ErrCardExpired)
+
+	require.NoError(t, isomessage.Field(2, "4111111111111112"))
+	_, err = ReadCard(isomessage)
+	assert.ErrorIs(t, err, ErrInvalidPAN)
+
+	isomessage = iso8583.NewMessage(Spec87)
+	require.NoError(t, isomessage.Field(2, "4111111111111112"))
+	card, err = ReadCard(isomessage)
+	require.NoError(t, err)
+	assert.ErrorIs(t, card.Validate(now), ErrInvalidPAN)
+}
@@ -0,0 +1,410 @@
+package iso
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/iso8583"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported iso version")
+
+// VersionSpec mengembalikan spec ASCII untuk pesan host versi v.
+func VersionSpec(v Version) (*iso8583.MessageSpec, error) {
+	switch v {
+	case Version87:
+		return Spec87, nil
+	case Version93:
+		return Spec93, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, v)
+}
+
+// networkFunctionCodes memetakan bit 70 (1987) ke function code bit 24 pesan
+// 18xx (1993).
+var networkFunctionCodes = map[string]string{
+	"001": "801", // sign on
+	"002": "802", // sign off
+	"102": "811", // key change
+	"201": "821", // cut over
+	"301": "831", // echo test
+}
+
+// actionCodes memetakan response code 1987 (bit 39 an2) ke action code 1993
+// (bit 39 n3).
+var actionCodes = [][2]string{
+	{"00", "000"},
+	{"01", "107"},
+	{"03", "109"},
+	{"04", "200"},
+	{"05", "100"},
+	{"12", "902"},
+	{"13", "110"},
+	{"14", "111"},
+	{"30", "904"},
+	{"41", "208"},
+	{"43", "209"},
+	{"51", "116"},
+	{"54", "101"},
+	{"55", "117"},
+	{"57", "119"},
+	{"58", "120"},
+	{"61", "121"},
+	{"63", "122"},
+	{"65", "123"},
+	{"68", "911"},
+	{"75", "106"},
+	{"91", "907"},
+	{"94", "913"},
+	{"96", "909"},
+}
+
+// ToVersion mengubah pesan Spec87 internal ke pesan versi v dengan spec
+// VersionSpec(v). now dipakai untuk melengkapi tahun pada tanggal 1993.
+func ToVersion(msg []byte, v Version, now time.Time) ([]byte, error) {
+	if v == Version87 {
+		return msg, nil
+	}
+	spec, err := VersionSpec(v)
+	if err != nil {
+		return nil, fmt.Errorf("iso version -> %w", err)
+	}
+
+	mti, fields, err := messageFields(msg, Spec87)
+	if err != nil {
+		return nil, fmt.Errorf("iso version -> %w", err)
+	}
+	if mti.Version() != Version87 {
+		return nil, fmt.Errorf("iso version -> %w: %s is not a 1987 mti", ErrInvalidMTI, mti)
+	}
+
+	if err := fieldsTo93(mti, fields, now); err != nil {
+		return nil, fmt.Errorf("iso version -> %s to %s: %w", mti, v, err)
+	}
+
+	packed, err := packFields(spec, mtiTo93(mti).WithVersion(v), fields)
+	if err != nil {
+		return nil, fmt.Errorf("iso version -> %w", err)
+	}
+	return packed, nil
+}
+
+// FromVersion mengubah pesan host versi v kembali ke pesan Spec87 internal.
+func FromVersion(msg []byte, v Version) ([]byte, error) {
+	if v == Version87 {
+		return msg, nil
+	}
+	spec, err := VersionSpec(v)
+	if err != nil {
+		return nil, fmt.Errorf("iso version -> %w", err)
+	}
+
+	mti, fields, err := messageFields(msg, spec)
+	if err != nil {
+		return nil, fmt.Errorf("iso version -> %w", err)
+	}
+	if mti.Version() != v {
+		return nil, fmt.Errorf("iso version -> %w: %s is not a %s mti", ErrInvalidMTI, mti, v)
+	}
+
+	if err := fieldsFrom93(mti, fields); err != nil {
+		return nil, fmt.Errorf("iso version -> %s to 87: %w", mti, err)
+	}
+
+	packed, err := packFields(Spec87, mtiFrom93(mti).WithVersion(Version87), fields)
+	if err != nil {
+		return nil, fmt.Errorf("iso version -> %w", err)
+	}
+	return packed, nil
+}
+
+func fieldsTo93(mti MTI, fields map[int]string, now time.Time) error {
+	// Bit 24 terminal diganti function code, bit 25 (kondisi POS) tidak punya
+	// padanan di 1993
+	delete(fields, 25)
+	if mti.IsNetwork() {
+		code, ok := networkFunctionCodes[fields[70]]
+		if !ok {
+			return fmt.Errorf("no function code for network management code %q", fields[70])
+		}
+		fields[24] = code
+		delete(fields, 70)
+	} else {
+		fields[24] = string(mti.Class()) + "00"
+	}
+
+	if local, ok := fields[12]; ok {
+		date, ok := fields[13]
+		if !ok {
+			date = now.Format("0102")
+		}
+		fields[12] = localYear(date, now) + date + local
+	}
+	delete(fields, 13)
+
+	if mode, ok := fields[22]; ok {
+		fields[22] = posDataCode(mode)
+	}
+
+	if rc, ok := fields[39]; ok {
+		fields[39] = actionCode(mti, rc)
+	}
+
+	delete(fields, 56)
+	if original, ok := fields[90]; ok {
+		value, err := originalDataTo93(original, now)
+		if err != nil {
+			return err
+		}
+		fields[56] = value
+		delete(fields, 90)
+	}
+	return nil
+}
+
+func fieldsFrom93(mti MTI, fields map[int]string) error {
+	delete(fields, 25)
+	if mti.IsNetwork() {
+		code, ok := networkManagementCode(fields[24])
+		if !ok {
+			return fmt.Errorf("no network management code for function code %q", fields[24])
+		}
+		fields[70] = code
+	}
+	delete(fields, 24)
+
+	// Bit 13 1993 adalah tanggal efektif kartu, bukan tanggal transaksi
+	delete(fields, 13)
+	if local, ok := fields[12]; ok {
+		if len(local) != 12 {
+			return fmt.Errorf("bit 12 %q is not YYMMDDhhmmss", local)
+		}
+		fields[13] = local[2:6]
+		fields[12] = local[6:]
+	}
+
+	if code, ok := fields[22]; ok {
+		fields[22] = posEntryMode(code)
+	}
+
+	if action, ok := fields[39]; ok {
+		fields[39] = responseCode(action)
+	}
+
+	if original, ok := fields[56]; ok {
+		value, err := originalDataFrom93(original)
+		if err != nil {
+			return err
+		}
+		fields[90] = value
+		delete(fields, 56)
+	}
+	return nil
+}
+
+// mtiTo93 memindahkan network management ke origin "other" seperti dipakai
+// host 1993 (0800 -> 1804, 0810 -> 1814). Kelas lain cukup ganti versi.
+func mtiTo93(mti MTI) MTI {
+	if mti.IsNetwork() && mti.Origin() < '2' {
+		return MTI([]byte{mti[0], mti.Class(), mti.Function(), mti.Origin() + 4})
+	}
+	return mti
+}
+
+func mtiFrom93(mti MTI) MTI {
+	if mti.IsNetwork() && (mti.Origin() == '4' || mti.Origin() == '5') {
+		return MTI([]byte{mti[0], mti.Class(), mti.Function(), mti.Origin() - 4})
+	}
+	return mti
+}
+
+// localYear menebak tahun dua digit dari tanggal MMDD. Tanggal lebih dari enam
+// bulan ke depan dianggap tahun lalu, misal transaksi Desember yang dikirim Januari.
+func localYear(mmdd string, now time.Time) string {
+	year := now.Year()
+	if month, err := strconv.Atoi(mmdd[:min(2, len(mmdd))]); err == nil {
+		diff := month - int(now.Month())
+		if diff > 6 {
+			year--
+		} else if diff < -6 {
+			year++
+		}
+	}
+	return fmt.Sprintf("%02d", year%100)
+}
+
+// posDataCode mengubah POS entry mode 1987 (n3) ke POS data code 1993 (an12).
+// Hanya kemampuan baca kartu (posisi 1), kemampuan PIN (posisi 2) dan cara
+// baca kartu (posisi 7) yang diisi.
+func posDataCode(mode string) string {
+	var input byte = '0'
+	if len(mode) >= 2 {
+		switch mode[:2] {
+		case "01":
+			input = '6'
+		case "02", "80", "90":
+			input = '2'
+		case "05", "07", "95":
+			input = '5'
+		}
+	}
+	var pin byte = '0'
+	if len(mode) >= 3 && mode[2] == '1' {
+		pin = '1'
+	}
+	return string([]byte{input, pin}) + "0000" + string(input) + "00000"
+}
+
+// posEntryMode kebalikan posDataCode.
+func posEntryMode(code string) string {
+	if len(code) != 12 {
+		return "000"
+	}
+	mode := "00"
+	switch code[6] {
+	case '1', '6':
+		mode = "01"
+	case '2':
+		mode = "02"
+	case '5':
+		mode = "05"
+	}
+	pin := "2"
+	if code[1] == '1' {
+		pin = "1"
+	}
+	return mode + pin
+}
+
+func actionCode(mti MTI, rc string) string {
+	if rc == "00" {
+		switch mti.Class() {
+		case ClassNetwork:
+			return "800"
+		case ClassReversal:
+			return "400"
+		}
+	}
+	for _, pair := range actionCodes {
+		if pair[0] == rc {
+			return pair[1]
+		}
+	}
+	if strings.HasPrefix(rc, "9") {
+		return "909"
+	}
+	return "100"
+}
+
+func responseCode(action string) string {
+	for _, pair := range actionCodes {
+		if pair[1] == action {
+			return pair[0]
+		}
+	}
+	switch action {
+	case "400", "800":
+		return "00"
+	}
+	if action == "" {
+		return ""
+	}
+	// Action code lain mengikuti kelompok digit pertama
+	switch action[0] {
+	case '0':
+		return "00"
+	case '1':
+		return "05"
+	case '2':
+		return "04"
+	case '3':
+		return "30"
+	}
+	return "96"
+}
+
+func networkManagementCode(function string) (string, bool) {
+	for code, f := range networkFunctionCodes {
+		if f == function {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// originalDataTo93 mengubah bit 90 1987 (MTI, STAN, tanggal transmisi MMDDhhmmss,
+// acquirer, forwarding) ke bit 56 1993 (MTI, STAN, YYMMDDhhmmss, LL acquirer).
+func originalDataTo93(original string, now time.Time) (string, error) {
+	if len(original) != 42 || !isDigits(original) {
+		return "", fmt.Errorf("bit 90 %q is not n42", original)
+	}
+	mti := MTI(original[:4]).WithVersion(Version93)
+	acquirer := strings.TrimLeft(original[20:31], "0")
+	if acquirer == "" {
+		acquirer = "0"
+	}
+	datetime := original[10:20]
+	return fmt.Sprintf("%s%s%s%s%02d%s", mti, original[4:10], localYear(datetime[:4], now), datetime, len(acquirer), acquirer), nil
+}
+
+func originalDataFrom93(original string) (string, error) {
+	if len(original) < 22 || !isDigits(original) {
+		return "", fmt.Errorf("bit 56 %q is too short", original)
+	}
+	acquirer := ""
+	if rest := original[22:]; len(rest) >= 2 {
+		length, _ := strconv.Atoi(rest[:2])
+		if length > len(rest)-2 || length > 11 {
+			return "", fmt.Errorf("bit 56 %q has invalid acquirer length", original)
+		}
+		acquirer = rest[2 : 2+length]
+	}
+	mti := MTI(original[:4]).WithVersion(Version87)
+	return fmt.Sprintf("%s%s%s%011s%011s", mti, original[4:10], original[12:22], acquirer, ""), nil
+}
+
+// messageFields membaca MTI dan semua field data pesan sebagai string.
+func messageFields(msg []byte, spec *iso8583.MessageSpec) (MTI, map[int]string, error) {
+	isomessage := iso8583.NewMessage(spec)
+	if err := isomessage.Unpack(msg); err != nil {
+		return "", nil, fmt.Errorf("unpack: %w", err)
+	}
+	value, err := isomessage.GetMTI()
+	if err != nil {
+		return "", nil, fmt.Errorf("get mti: %w", err)
+	}
+	mti, err := ParseMTI(value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	fields := make(map[int]string)
+	for id := range isomessage.GetFields() {
+		if id < 2 {
+			continue
+		}
+		fields[id], err = isomessage.GetString(id)
+		if err != nil {
+			return "", nil, fmt.Errorf("get bit %d: %w", id, err)
+		}
+	}
+	return mti, fields, nil
+}
+
+func packFields(spec *iso8583.MessageSpec, mti MTI, fields map[int]string) ([]byte, error) {
+	isomessage := iso8583.NewMessage(spec)
+	isomessage.MTI(string(mti))
+	for id, value := range fields {
+		if err := isomessage.Field(id, value); err != nil {
+			return nil, fmt.Errorf("set bit %d: %w", id, err)
+		}
+	}
+	packed, err := isomessage.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("pack: %w", err)
+	}
+	return packed, nil
+}
@@ -0,0 +1,135 @@
+package iso
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/iso8583"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pack87(t *testing.T, mti string, fields map[int]string) []byte {
+	isomessage := iso8583.NewMessage(Spec87)
+	isomessage.MTI(mti)
+	for id, value := range fields {
+		require.NoError(t, isomessage.Field(id, value))
+	}
+	msg, err := isomessage.Pack()
+	require.NoError(t, err)
+	return msg
+}
+
+func unpackFields(t *testing.T, spec *iso8583.MessageSpec, msg []byte) (string, map[int]string) {
+	mti, fields, err := messageFields(msg, spec)
+	require.NoError(t, err)
+	return string(mti), fields
+}
+
+func TestToVersion93Financial(t *testing.T) {
+	now := time.Date(2026, 1, 3, 10, 0, 0, 0, time.UTC)
+	msg := pack87(t, "0200", map[int]string{
+		3:  "000000",
+		4:  "000000010000",
+		11: "000045",
+		12: "235959",
+		13: "1231",
+		22: "051",
+		24: "001",
+		25: "00",
+		41: "TID00001",
+	})
+
+	out, err := ToVersion(msg, Version93, now)
+	require.NoError(t, err)
+	mti, fields := unpackFields(t, Spec93, out)
+	assert.Equal(t, "1200", mti)
+	assert.Equal(t, "200", fields[24])
+	// Transaksi 31 Desember yang dikirim awal Januari memakai tahun lalu
+	assert.Equal(t, "251231235959", fields[12])
+	assert.Equal(t, "510000500000", fields[22])
+	assert.NotContains(t, fields, 13)
+	assert.NotContains(t, fields, 25)
+
+	// Balasan host 93 kembali ke 0210 dengan response code 2 digit
+	response := iso8583.NewMessage(Spec93)
+	response.MTI("1210")
+	for id, value := range map[int]string{3: "000000", 11: "000045", 12: "251231235959", 24: "200", 39: "116", 41: "TID00001"} {
+		require.NoError(t, response.Field(id, value))
+	}
+	raw, err := response.Pack()
+	require.NoError(t, err)
+
+	back, err := FromVersion(raw, Version93)
+	require.NoError(t, err)
+	mti, fields = unpackFields(t, Spec87, back)
+	assert.Equal(t, "0210", mti)
+	assert.Equal(t, "51", fields[39])
+	assert.Equal(t, "235959", fields[12])
+	assert.Equal(t, "1231", fields[13])
+	assert.NotContains(t, fields, 24)
+}
+
+func TestToVersion93Network(t *testing.T) {
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	msg := pack87(t, "0810", map[int]string{7: "1017101010", 11: "000000000123", 39: "00", 70: "301"})
+
+	out, err := ToVersion(msg, Version93, now)
+	require.NoError(t, err)
+	mti, fields := unpackFields(t, Spec93, out)
+	assert.Equal(t, "1814", mti)
+	assert.Equal(t, "831", fields[24])
+	assert.Equal(t, "800", fields[39])
+	assert.NotContains(t, fields, 70)
+
+	back, err := FromVersion(out, Version93)
+	require.NoError(t, err)
+	assert.Equal(t, string(msg), string(back))
+
+	_, err = ToVersion(pack87(t, "0800", map[int]string{11: "1", 70: "999"}), Version93, now)
+	assert.ErrorContains(t, err, `network management code "999"`)
+}
+
+func TestToVersion93OriginalData(t *testing.T) {
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	original := "0200" + "000045" + "1017101010" + "00000000628" + "00000000000"
+	msg := pack87(t, "0420", map[int]string{11: "000046", 39: "00", 90: original})
+
+	out, err := ToVersion(msg, Version93, now)
+	require.NoError(t, err)
+	mti, fields := unpackFields(t, Spec93, out)
+	assert.Equal(t, "1420", mti)
+	assert.Equal(t, "400", fields[24])
+	assert.Equal(t, "400", fields[39])
+	assert.Equal(t, "1200"+"000045"+"261017101010"+"03628", fields[56])
+
+	back, err := FromVersion(out, Version93)
+	require.NoError(t, err)
+	_, fields = unpackFields(t, Spec87, back)
+	assert.Equal(t, original, fields[90])
+	assert.Equal(t, "00", fields[39])
+}
+
+func TestVersionErrors(t *testing.T) {
+	msg := pack87(t, "0200", map[int]string{11: "1"})
+	same, err := ToVersion(msg, Version87, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, msg, same)
+
+	_, err = ToVersion(msg, Version03, time.Now())
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	// Pesan 1987 dari host yang dikonfigurasi 93 ditolak
+	_, err = FromVersion(msg, Version93)
+	assert.Error(t, err)
+}
+
+func TestActionCodes(t *testing.T) {
+	for _, pair := range actionCodes {
+		assert.Equal(t, pair[0], responseCode(pair[1]), pair[1])
+		assert.Equal(t, pair[1], actionCode("0210", pair[0]), pair[0])
+	}
+	assert.Equal(t, "05", responseCode("183"))
+	assert.Equal(t, "96", responseCode("999"))
+	assert.Equal(t, "100", actionCode("0210", "XY"))
+}
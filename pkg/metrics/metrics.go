@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "danus"
+
+var (
+	Registry = prometheus.NewRegistry()
+
+	Transactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_total",
+		Help:      "Responses sent to terminals by MTI, processing code and response code.",
+	}, []string{"mti", "procode", "rc"})
+
+	HostLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "host_request_duration_seconds",
+		Help:      "Round trip time of requests forwarded to the host.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"upstream", "mti"})
+
+	HostUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "host_session_up",
+		Help:      "1 when the host session is connected, 0 otherwise.",
+	}, []string{"upstream", "session"})
+
+	HSMLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "hsm_command_duration_seconds",
+		Help:      "Duration of HSM commands.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"command"})
+
+	HSMErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hsm_errors_total",
+		Help:      "HSM command failures by command and error code (io for connection errors).",
+	}, []string{"command", "code"})
+
+	KeyCheckFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "key_check_failures_total",
+		Help:      "Keys rejected because the key check value did not match.",
+	}, []string{"key"})
+
+	sourcesLock   sync.RWMutex
+	activeClients func() int
+	inflight      func() int
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Transactions,
+		HostLatency,
+		HostUp,
+		HSMLatency,
+		HSMErrors,
+		KeyCheckFailures,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_clients",
+			Help:      "Terminal connections currently being served.",
+		}, func() float64 { return read(&activeClients) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "inflight_requests",
+			Help:      "Requests waiting for a host response.",
+		}, func() float64 { return read(&inflight) }),
+	)
+}
+
+func read(source *func() int) float64 {
+	sourcesLock.RLock()
+	defer sourcesLock.RUnlock()
+
+	if *source == nil {
+		return 0
+	}
+	return float64((*source)())
+}
+
+// SetActiveClientsSource mendaftarkan fungsi untuk membaca jumlah koneksi terminal aktif.
+func SetActiveClientsSource(fn func() int) {
+	sourcesLock.Lock()
+	activeClients = fn
+	sourcesLock.Unlock()
+}
+
+// SetInflightSource mendaftarkan fungsi untuk membaca jumlah request yang menunggu host.
+func SetInflightSource(fn func() int) {
+	sourcesLock.Lock()
+	inflight = fn
+	sourcesLock.Unlock()
+}
+
+func ObserveHSM(command string, start time.Time) {
+	HSMLatency.WithLabelValues(command).Observe(time.Since(start).Seconds())
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
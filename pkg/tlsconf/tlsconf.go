@@ -0,0 +1,249 @@
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileState dipakai untuk mendeteksi perubahan file sertifikat tanpa restart.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFiles(paths ...string) ([]fileState, error) {
+	states := make([]fileState, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, fileState{modTime: info.ModTime(), size: info.Size()})
+	}
+	return states, nil
+}
+
+func sameStates(a, b []fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
+
+// CertReloader memuat pasangan sertifikat/key dan memuat ulang otomatis
+// ketika file berubah di disk.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.Mutex
+	states   []fileState
+	cert     *tls.Certificate
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Get(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Get mengembalikan sertifikat terbaru. Jika file baru gagal dimuat (misal sedang
+// ditulis), sertifikat lama tetap dipakai.
+func (r *CertReloader) Get() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states, err := statFiles(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("cert reloader -> stat: %w", err)
+	}
+	if r.cert != nil && sameStates(states, r.states) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("cert reloader -> load key pair: %w", err)
+	}
+
+	r.cert = &cert
+	r.states = states
+	return r.cert, nil
+}
+
+// CAReloader memuat CA bundle (PEM) dan memuat ulang ketika file berubah.
+type CAReloader struct {
+	caFile string
+	mu     sync.Mutex
+	states []fileState
+	pool   *x509.CertPool
+}
+
+func NewCAReloader(caFile string) (*CAReloader, error) {
+	r := &CAReloader{caFile: caFile}
+	if _, err := r.Get(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CAReloader) Get() (*x509.CertPool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states, err := statFiles(r.caFile)
+	if err != nil {
+		if r.pool != nil {
+			return r.pool, nil
+		}
+		return nil, fmt.Errorf("ca reloader -> stat: %w", err)
+	}
+	if r.pool != nil && sameStates(states, r.states) {
+		return r.pool, nil
+	}
+
+	pem, err := os.ReadFile(r.caFile)
+	if err != nil {
+		if r.pool != nil {
+			return r.pool, nil
+		}
+		return nil, fmt.Errorf("ca reloader -> read: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		if r.pool != nil {
+			return r.pool, nil
+		}
+		return nil, errors.New("ca reloader -> no certificate found in " + r.caFile)
+	}
+
+	r.pool = pool
+	r.states = states
+	return r.pool, nil
+}
+
+type ServerOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // jika diisi, terminal wajib mengirim sertifikat yang ditandatangani CA ini
+}
+
+// NewServerConfig membuat konfigurasi TLS listener. Sertifikat dan CA dibaca ulang
+// setiap handshake jika filenya berubah, jadi pergantian sertifikat tidak perlu restart.
+func NewServerConfig(opts ServerOptions) (*tls.Config, error) {
+	certs, err := NewCertReloader(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientCAs *CAReloader
+	if opts.ClientCAFile != "" {
+		clientCAs, err = NewCAReloader(opts.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, err := certs.Get()
+			if err != nil {
+				return nil, err
+			}
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+
+			if clientCAs != nil {
+				pool, err := clientCAs.Get()
+				if err != nil {
+					return nil, err
+				}
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return cfg, nil
+		},
+	}, nil
+}
+
+type ClientOptions struct {
+	CAFile     string // CA yang di-pin untuk host, jika kosong dipakai CA sistem
+	CertFile   string // sertifikat client jika host meminta mutual TLS
+	KeyFile    string
+	ServerName string
+}
+
+// Client membuat konfigurasi TLS untuk koneksi keluar ke host.
+type Client struct {
+	serverName string
+	rootCAs    *CAReloader
+	certs      *CertReloader
+}
+
+func NewClient(opts ClientOptions) (*Client, error) {
+	c := &Client{serverName: opts.ServerName}
+
+	var err error
+	if opts.CAFile != "" {
+		c.rootCAs, err = NewCAReloader(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		c.certs, err = NewCertReloader(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Config dipanggil setiap kali dial agar CA dan sertifikat terbaru yang dipakai.
+func (c *Client) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.serverName,
+	}
+
+	if c.rootCAs != nil {
+		pool, err := c.rootCAs.Get()
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.certs != nil {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.certs.Get()
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,240 @@
+package tlsconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue membuat sertifikat leaf yang ditandatangani CA, hasilnya PEM cert dan key.
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// startServer menjalankan listener TLS yang membalas "OK" untuk setiap koneksi.
+func startServer(t *testing.T, cfg *tls.Config) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn.Write([]byte("OK"))
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func dial(addr string, cfg *tls.Config) (*x509.Certificate, error) {
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Dengan TLS 1.3 penolakan sertifikat client baru terlihat saat membaca
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "test-ca")
+	serverCert, serverKey := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, 20, x509.ExtKeyUsageClientAuth)
+
+	now := time.Now()
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem, now)
+	writeFile(t, filepath.Join(dir, "server.pem"), serverCert, now)
+	writeFile(t, filepath.Join(dir, "server.key"), serverKey, now)
+	writeFile(t, filepath.Join(dir, "client.pem"), clientCert, now)
+	writeFile(t, filepath.Join(dir, "client.key"), clientKey, now)
+
+	serverCfg, err := NewServerConfig(ServerOptions{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	})
+	require.NoError(t, err)
+	addr := startServer(t, serverCfg)
+
+	t.Run("ValidClientCertificate", func(t *testing.T) {
+		client, err := NewClient(ClientOptions{
+			CAFile:   filepath.Join(dir, "ca.pem"),
+			CertFile: filepath.Join(dir, "client.pem"),
+			KeyFile:  filepath.Join(dir, "client.key"),
+		})
+		require.NoError(t, err)
+		cfg, err := client.Config()
+		require.NoError(t, err)
+		cfg.ServerName = "localhost"
+
+		peer, err := dial(addr, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), peer.SerialNumber.Int64())
+	})
+
+	t.Run("MissingClientCertificate", func(t *testing.T) {
+		client, err := NewClient(ClientOptions{CAFile: filepath.Join(dir, "ca.pem"), ServerName: "localhost"})
+		require.NoError(t, err)
+		cfg, err := client.Config()
+		require.NoError(t, err)
+
+		_, err = dial(addr, cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("PinnedCARejectsOtherCA", func(t *testing.T) {
+		other := newTestCA(t, "other-ca")
+		writeFile(t, filepath.Join(dir, "other.pem"), other.pem, now)
+
+		client, err := NewClient(ClientOptions{
+			CAFile:     filepath.Join(dir, "other.pem"),
+			CertFile:   filepath.Join(dir, "client.pem"),
+			KeyFile:    filepath.Join(dir, "client.key"),
+			ServerName: "localhost",
+		})
+		require.NoError(t, err)
+		cfg, err := client.Config()
+		require.NoError(t, err)
+
+		_, err = dial(addr, cfg)
+		assert.Error(t, err)
+	})
+}
+
+func TestServerCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "test-ca")
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+
+	now := time.Now()
+	cert, key := ca.issue(t, 100, x509.ExtKeyUsageServerAuth)
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem, now)
+	writeFile(t, certFile, cert, now)
+	writeFile(t, keyFile, key, now)
+
+	serverCfg, err := NewServerConfig(ServerOptions{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	addr := startServer(t, serverCfg)
+
+	client, err := NewClient(ClientOptions{CAFile: filepath.Join(dir, "ca.pem"), ServerName: "localhost"})
+	require.NoError(t, err)
+	cfg, err := client.Config()
+	require.NoError(t, err)
+
+	peer, err := dial(addr, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), peer.SerialNumber.Int64())
+
+	// Ganti sertifikat di disk tanpa restart listener
+	later := now.Add(time.Minute)
+	cert, key = ca.issue(t, 200, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, later)
+	writeFile(t, keyFile, key, later)
+
+	peer, err = dial(addr, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), peer.SerialNumber.Int64())
+}
+
+func TestCertReloaderKeepsOldCertificateOnBrokenFile(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "test-ca")
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+
+	now := time.Now()
+	cert, key := ca.issue(t, 300, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, now)
+	writeFile(t, keyFile, key, now)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	writeFile(t, certFile, []byte("not a certificate"), now.Add(time.Minute))
+
+	current, err := reloader.Get()
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(current.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), leaf.SerialNumber.Int64())
+}
@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/alfianX/danus-h2h"
+
+type Options struct {
+	Endpoint    string // host:port OTLP/HTTP collector, tracing mati jika kosong
+	Insecure    bool
+	ServiceName string
+	SampleRatio float64
+}
+
+// Init memasang tracer provider global dengan exporter OTLP. Fungsi yang
+// dikembalikan harus dipanggil saat shutdown agar span yang tersisa terkirim.
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing -> create exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing -> resource: %w", err)
+	}
+
+	provider := NewProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// NewProvider membuat tracer provider yang memakai trace ID dari TID+STAN
+// untuk span yang dimulai lewat StartTransaction.
+func NewProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append(opts, sdktrace.WithIDGenerator(idGenerator{}))
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// TraceID diturunkan dari TID dan STAN terminal sehingga trace transaksi bisa
+// dicari langsung dari data di struk atau log terminal.
+func TraceID(tid, stan string) trace.TraceID {
+	var id trace.TraceID
+	sum := sha256.Sum256([]byte(tid + "|" + stan))
+	copy(id[:], sum[:len(id)])
+	return id
+}
+
+type traceIDKey struct{}
+
+// StartTransaction memulai root span transaksi dengan trace ID dari TID+STAN.
+func StartTransaction(ctx context.Context, tid, stan, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	ctx = context.WithValue(ctx, traceIDKey{}, TraceID(tid, stan))
+	opts = append(opts, trace.WithNewRoot())
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// Start memulai span anak dari span yang ada di ctx.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// LogFields mengembalikan field logrus trace_id untuk korelasi log dan trace.
+func LogFields(ctx context.Context) logrus.Fields {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if spanContext.HasTraceID() {
+		return logrus.Fields{"trace_id": spanContext.TraceID().String()}
+	}
+	if id, ok := ctx.Value(traceIDKey{}).(trace.TraceID); ok {
+		return logrus.Fields{"trace_id": id.String()}
+	}
+	return logrus.Fields{}
+}
+
+// idGenerator memakai trace ID dari StartTransaction jika ada, selain itu acak.
+type idGenerator struct{}
+
+func (idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	traceID, ok := ctx.Value(traceIDKey{}).(trace.TraceID)
+	if !ok {
+		rand.Read(traceID[:])
+	}
+	return traceID, newSpanID()
+}
+
+func (idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	return newSpanID()
+}
+
+func newSpanID() trace.SpanID {
+	var id trace.SpanID
+	rand.Read(id[:])
+	return id
+}
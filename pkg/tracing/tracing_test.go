@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceID(t *testing.T) {
+	assert.Equal(t, TraceID("TID00001", "000045"), TraceID("TID00001", "000045"))
+	assert.NotEqual(t, TraceID("TID00001", "000045"), TraceID("TID00001", "000046"))
+	assert.NotEqual(t, TraceID("TID00001", "000045"), TraceID("TID00002", "000045"))
+	assert.True(t, TraceID("", "").IsValid())
+}
+
+func TestStartTransaction(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := NewProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	ctx, root := StartTransaction(context.Background(), "TID00001", "000045", "terminal.request")
+	_, child := Start(ctx, "host.request")
+	child.End()
+	root.End()
+
+	// Span berikutnya dengan ctx baru tidak memakai trace ID transaksi
+	_, other := Start(context.Background(), "saf")
+	other.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	want := TraceID("TID00001", "000045")
+	assert.Equal(t, want, spans[0].SpanContext().TraceID())
+	assert.Equal(t, want, spans[1].SpanContext().TraceID())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.NotEqual(t, want, spans[2].SpanContext().TraceID())
+
+	assert.Equal(t, want.String(), LogFields(ctx)["trace_id"])
+	assert.Empty(t, LogFields(context.Background()))
+}
+
+func TestInitDisabled(t *testing.T) {
+	shutdown, err := Init(context.Background(), Options{})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}